@@ -0,0 +1,528 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"agate/internal/debug"
+	"agate/pkg/app"
+	"agate/pkg/config"
+	"agate/pkg/control"
+	"agate/pkg/git"
+	"agate/pkg/hooks"
+	"agate/pkg/session"
+	"agate/pkg/tmux"
+
+	"github.com/spf13/cobra"
+)
+
+// newSessionCommands returns the subcommands that manage sessions without the TUI
+func newSessionCommands() []*cobra.Command {
+	return []*cobra.Command{
+		newLsCommand(),
+		newNewCommand(),
+		newRmCommand(),
+		newAttachCommand(),
+		newPruneCommand(),
+		newRunCommand(),
+		newRPCCommand(),
+		newTmuxCommand(),
+	}
+}
+
+// openSessionManager loads the persisted sessions the same way the TUI does
+// and runs the user's hooks for the sessions created and deleted. The
+// returned function waits for the hooks and must be called before exiting.
+func openSessionManager() (*session.Manager, func(), error) {
+	return openSessionManagerAt("", false)
+}
+
+// openSessionManagerAt is openSessionManager for the repository containing
+// repoDir, or the working directory when repoDir is empty. With stateHooks,
+// the hooks about agents' states run too, for commands that probe sessions
+// while no agate UI does.
+func openSessionManagerAt(repoDir string, stateHooks bool) (*session.Manager, func(), error) {
+	sessionManager, err := newSessionManagerAt(repoDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := sessionManager.RestoreSessions(); err != nil {
+		return nil, nil, fmt.Errorf("failed to load sessions: %w", err)
+	}
+
+	hookRunner, err := hooks.LoadRunner()
+	if err != nil {
+		debug.DebugLog("Failed to load hooks: %v", err)
+	}
+	if !stateHooks {
+		hookRunner.SkipStateHooks()
+	}
+	stopHooks := hookRunner.Start(sessionManager)
+	return sessionManager, func() {
+		stopHooks()
+		hookRunner.Wait()
+	}, nil
+}
+
+// inspectSessionManager loads the persisted sessions without reconnecting
+// to, starting or migrating anything, for commands that only report
+func inspectSessionManager() (*session.Manager, error) {
+	sessionManager, err := newSessionManagerAt("")
+	if err != nil {
+		return nil, err
+	}
+	if err := sessionManager.InspectSessions(); err != nil {
+		return nil, fmt.Errorf("failed to load sessions: %w", err)
+	}
+	return sessionManager, nil
+}
+
+// newSessionManagerAt creates a session manager for the repository
+// containing repoDir, or the working directory when repoDir is empty,
+// without loading sessions
+func newSessionManagerAt(repoDir string) (*session.Manager, error) {
+	if err := checkTmuxInstalled(); err != nil {
+		return nil, err
+	}
+	configureTmuxServer()
+
+	var worktreeManager *git.WorktreeManager
+	var err error
+	if repoDir == "" {
+		worktreeManager, err = git.NewWorktreeManager()
+	} else {
+		worktreeManager, err = git.NewWorktreeManagerAt(repoDir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize worktree manager: %w", err)
+	}
+
+	// Load user-defined agents before restoring sessions that may use them
+	if err := app.LoadAgents(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load user-defined agents: %v\n", err)
+	}
+
+	return session.NewManager(worktreeManager), nil
+}
+
+// agateRunning reports whether an agate UI serves the control socket
+func agateRunning() bool {
+	socketPath, err := control.SocketPath()
+	if err != nil {
+		return false
+	}
+	client, err := control.Dial(socketPath)
+	if err != nil {
+		return false
+	}
+	client.Close()
+	return true
+}
+
+// resolveAgent returns the agent to launch, the default one when name is empty
+func resolveAgent(name string) (string, error) {
+	if name == "" {
+		name, _ = config.GetDefaultAgent()
+	}
+	if name == "" {
+		return "", fmt.Errorf("no default agent configured, pass --agent")
+	}
+	if !app.IsValidAgent(name) {
+		return "", fmt.Errorf("unknown agent %q, expected one of: %s", name, strings.Join(app.GetAgentNames(), ", "))
+	}
+	return name, nil
+}
+
+func newLsCommand() *cobra.Command {
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:          "ls",
+		Short:        "List agent sessions",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			sessionManager, err := inspectSessionManager()
+			if err != nil {
+				return err
+			}
+
+			// Probe the agents so states are current rather than as persisted
+			sessionManager.ProbeSessions()
+			listings := control.ListSessions(sessionManager)
+
+			out := cmd.OutOrStdout()
+			if asJSON {
+				encoder := json.NewEncoder(out)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(listings)
+			}
+
+			writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(writer, "NAME\tREPO\tBRANCH\tAGENT\tSTATE\tPATH")
+			for _, listing := range listings {
+				name := listing.Name
+				if listing.Active {
+					name += "*"
+				}
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n",
+					name, listing.Repo, listing.Branch, listing.Agent, listing.State, listing.Path)
+			}
+			return writer.Flush()
+		},
+	}
+
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print sessions as JSON")
+	return cmd
+}
+
+func newNewCommand() *cobra.Command {
+	var branch, agentName string
+
+	cmd := &cobra.Command{
+		Use:   "new",
+		Short: "Start an agent session, creating the branch's worktree if needed",
+		Long: `Start an agent session in the current repository.
+
+Without --branch the session runs in the main worktree. A branch that already
+has a worktree gets another session there; otherwise a new worktree and branch
+are created. The agent defaults to the one Agate was last launched with.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			sessionManager, closeManager, err := openSessionManager()
+			if err != nil {
+				return err
+			}
+			defer closeManager()
+			agentName, err := resolveAgent(agentName)
+			if err != nil {
+				return err
+			}
+
+			worktree, err := sessionManager.GetWorktreeManager().WorktreeForBranch(branch)
+			if err != nil {
+				return err
+			}
+
+			sess, err := sessionManager.CreateSession(worktree, agentName)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), sess.GetTmuxSessionName())
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&branch, "branch", "b", "", "Branch to run the agent on (default: the main worktree)")
+	cmd.Flags().StringVarP(&agentName, "agent", "a", "", "Agent to launch (default: the last agent used)")
+	return cmd
+}
+
+func newRmCommand() *cobra.Command {
+	var removeWorktree bool
+
+	cmd := &cobra.Command{
+		Use:   "rm <session>",
+		Short: "Delete a session, keeping its worktree unless --worktree is given",
+		Long: `Delete a session. Its worktree is kept; with --worktree, a linked worktree
+and its branch are deleted too once no other session uses them.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sessionManager, closeManager, err := openSessionManager()
+			if err != nil {
+				return err
+			}
+			defer closeManager()
+
+			sess, err := sessionManager.FindSession(args[0])
+			if err != nil {
+				return err
+			}
+			if err := sessionManager.DeleteSession(sess.GetID(), removeWorktree); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Deleted %s\n", sess.GetTmuxSessionName())
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&removeWorktree, "worktree", false, "Also delete the session's worktree and branch once no other session uses them")
+	return cmd
+}
+
+func newAttachCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:          "attach <session>",
+		Short:        "Attach the terminal to a session's agent, resuming it if stopped",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(_ *cobra.Command, args []string) error {
+			sessionManager, closeManager, err := openSessionManager()
+			if err != nil {
+				return err
+			}
+			defer closeManager()
+
+			sess, err := sessionManager.FindSession(args[0])
+			if err != nil {
+				return err
+			}
+			if _, err := sessionManager.ResumeSession(sess.GetID()); err != nil {
+				return err
+			}
+
+			// Make it the session the TUI opens on next time
+			if _, err := sessionManager.SwitchToSession(sess.GetID()); err != nil {
+				return err
+			}
+
+			tmuxSession, ok := sess.GetTmuxSession().(*tmux.TmuxSession)
+			if !ok {
+				return fmt.Errorf("session %s runs on a PTY and can only be attached from the agate running it", sess.GetID())
+			}
+
+			// Inside a client of the session's tmux server, switch it rather than nesting
+			var attach *exec.Cmd
+			if server := tmuxSession.Server(); server.IsInside() {
+				attach = server.Command("switch-client", "-t", sess.GetTmuxSessionName())
+			} else {
+				attach = tmuxSession.AttachCommand()
+			}
+			attach.Stdin = os.Stdin
+			attach.Stdout = os.Stdout
+			attach.Stderr = os.Stderr
+			return attach.Run()
+		},
+	}
+}
+
+func newPruneCommand() *cobra.Command {
+	var defaultServer bool
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Forget sessions whose worktree is gone and kill untracked agate tmux sessions",
+		Long: `Forget sessions whose worktree is gone and kill agate tmux sessions that no
+session refers to on Agate's tmux server. With --default-server, untracked
+agate sessions left on your default tmux server by earlier versions are
+killed too.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			sessionManager, closeManager, err := openSessionManager()
+			if err != nil {
+				return err
+			}
+			defer closeManager()
+
+			out := cmd.OutOrStdout()
+			for _, sess := range sessionManager.ListSessions() {
+				// Sessions can't be resumed without their worktree
+				if _, err := os.Stat(sess.Worktree.Path); err == nil || !os.IsNotExist(err) {
+					continue
+				}
+				if err := sessionManager.DeleteSession(sess.GetID(), false); err != nil {
+					return err
+				}
+				fmt.Fprintf(out, "Deleted %s (worktree %s is gone)\n", sess.GetTmuxSessionName(), sess.Worktree.Path)
+			}
+
+			killed, err := sessionManager.KillUntrackedTmuxSessions(defaultServer)
+			for _, name := range killed {
+				fmt.Fprintf(out, "Killed untracked tmux session %s\n", name)
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().BoolVar(&defaultServer, "default-server", false, "Also kill untracked agate sessions on the default tmux server")
+	return cmd
+}
+
+func newRunCommand() *cobra.Command {
+	var repoDir, branch, agentName string
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "run [flags] <prompt>",
+		Short: "Start an agent on a new worktree and hand it a task",
+		Long: `Start an agent session on a branch's worktree, wait for the agent's input
+prompt and send it the task, then print the session ID. The session keeps
+running and shows up in the UI.
+
+The worktree and branch are created unless the branch already has one. A
+prompt of "-" is read from standard input.
+
+Example:
+  agate run --repo . --agent claude --branch fix-login "Fix the login redirect bug"`,
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			prompt := strings.Join(args, " ")
+			if prompt == "-" {
+				input, err := io.ReadAll(cmd.InOrStdin())
+				if err != nil {
+					return fmt.Errorf("failed to read prompt: %w", err)
+				}
+				prompt = strings.TrimRight(string(input), "\n")
+			}
+			if strings.TrimSpace(prompt) == "" {
+				return fmt.Errorf("the prompt is empty")
+			}
+
+			repoPath, err := filepath.Abs(repoDir)
+			if err != nil {
+				return err
+			}
+			// A running agate UI monitors the new session too and runs its state hooks
+			sessionManager, closeManager, err := openSessionManagerAt(repoPath, !agateRunning())
+			if err != nil {
+				return err
+			}
+			defer closeManager()
+			worktreeManager := sessionManager.GetWorktreeManager()
+			if !worktreeManager.IsGitRepo() {
+				return fmt.Errorf("%s is not a git repository", repoPath)
+			}
+
+			agentName, err := resolveAgent(agentName)
+			if err != nil {
+				return err
+			}
+			if branch == "" {
+				branch = git.GenerateRandomBranchName()
+			}
+
+			worktree, err := worktreeManager.WorktreeForBranch(branch)
+			if err != nil {
+				return err
+			}
+			sess, err := sessionManager.CreateSession(worktree, agentName)
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+			defer cancel()
+			if err := sessionManager.WaitForPrompt(ctx, sess.GetID()); err != nil {
+				return fmt.Errorf("session %s: %w", sess.GetID(), err)
+			}
+			if err := sessionManager.SendPrompt(sess.GetID(), prompt); err != nil {
+				return fmt.Errorf("session %s: %w", sess.GetID(), err)
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout(), sess.GetID())
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&repoDir, "repo", ".", "Repository to work in")
+	cmd.Flags().StringVarP(&branch, "branch", "b", "", "Branch to run the agent on (default: a new random branch)")
+	cmd.Flags().StringVarP(&agentName, "agent", "a", "", "Agent to launch (default: the last agent used)")
+	cmd.Flags().DurationVar(&timeout, "timeout", 2*time.Minute, "How long to wait for the agent's prompt")
+	return cmd
+}
+
+func newRPCCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "rpc <method> [params]",
+		Short: "Call the running Agate's control API",
+		Long: `Call a method of the control API served by a running Agate on
+~/.agate/control/agate.sock and print its result. Params are given as a JSON object.
+
+Methods:
+  sessions.list
+  sessions.create   {"branch": "fix-login", "agent": "claude"}
+  sessions.delete   {"session": "fix-login/claude", "remove_worktree": false}
+  sessions.send     {"session": "fix-login/claude", "text": "Run the tests", "submit": true}
+  sessions.capture  {"session": "fix-login/claude", "escapes": false}
+  events.subscribe  prints session events as JSON lines until interrupted`,
+		Args:         cobra.RangeArgs(1, 2),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			socketPath, err := control.SocketPath()
+			if err != nil {
+				return err
+			}
+			client, err := control.Dial(socketPath)
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+
+			out := cmd.OutOrStdout()
+			if args[0] == control.MethodSubscribe {
+				events, err := client.Subscribe()
+				if err != nil {
+					return err
+				}
+				encoder := json.NewEncoder(out)
+				for event := range events {
+					if err := encoder.Encode(event); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+
+			var params any
+			if len(args) > 1 {
+				params = json.RawMessage(args[1])
+				if !json.Valid(params.(json.RawMessage)) {
+					return fmt.Errorf("params are not valid JSON")
+				}
+			}
+			var result json.RawMessage
+			if err := client.Call(args[0], params, &result); err != nil {
+				return err
+			}
+
+			var indented bytes.Buffer
+			if err := json.Indent(&indented, result, "", "  "); err != nil {
+				return err
+			}
+			fmt.Fprintln(out, indented.String())
+			return nil
+		},
+	}
+}
+
+func newTmuxCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "tmux [args...]",
+		Short: "Run tmux against Agate's tmux server",
+		Long: `Run a tmux command against the tmux server Agate starts its sessions on,
+which is separate from your default tmux server, e.g. agate tmux ls.`,
+		DisableFlagParsing: true,
+		SilenceUsage:       true,
+		RunE: func(_ *cobra.Command, args []string) error {
+			if err := checkTmuxInstalled(); err != nil {
+				return err
+			}
+			configureTmuxServer()
+
+			tmuxCmd := tmux.CurrentServer().Command(args...)
+			tmuxCmd.Stdin = os.Stdin
+			tmuxCmd.Stdout = os.Stdout
+			tmuxCmd.Stderr = os.Stderr
+			if err := tmuxCmd.Run(); err != nil {
+				if exitErr, ok := err.(*exec.ExitError); ok {
+					os.Exit(exitErr.ExitCode())
+				}
+				return err
+			}
+			return nil
+		},
+	}
+}
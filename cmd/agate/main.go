@@ -4,6 +4,7 @@ import (
 	_ "embed"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -12,12 +13,14 @@ import (
 	"agate/pkg/app"
 	"agate/pkg/common"
 	"agate/pkg/config"
+	"agate/pkg/control"
 	"agate/pkg/git"
 	"agate/pkg/gui/components"
 	"agate/pkg/gui/layout"
 	"agate/pkg/gui/overlays"
 	"agate/pkg/gui/panes"
 	"agate/pkg/gui/theme"
+	"agate/pkg/hooks"
 	"agate/pkg/overlay"
 	"agate/pkg/session"
 	"agate/pkg/tmux"
@@ -39,6 +42,13 @@ const (
 	modePreview sessionMode = iota // Read-only preview
 )
 
+// sessionSyncInterval is how often sessions started or deleted by other agate
+// processes, such as `agate run`, are picked up
+const sessionSyncInterval = 2 * time.Second
+
+// hookQuitTimeout is how long quitting waits for running hooks
+const hookQuitTimeout = 10 * time.Second
+
 // focusState is now defined in layout package
 
 // Focus state constants are now defined in layout package
@@ -67,12 +77,24 @@ type model struct {
 	showSessionConfirm  bool                                 // Whether showing session deletion confirmation
 	repoDialog          *overlays.RepoDialog                 // Repository search dialog
 	showRepoDialog      bool                                 // Whether showing repository dialog
+	searchDialog        *overlays.SearchDialog               // Search of a preview pane or all sessions
+	showSearchDialog    bool                                 // Whether showing the search dialog
+	composer            *overlays.ComposerDialog             // Prompt composer
+	showComposer        bool                                 // Whether showing the prompt composer
+	queueDialog         *overlays.QueueDialog                // Prompt queue of a session
+	showQueueDialog     bool                                 // Whether showing the prompt queue
 	welcomeOverlay      *overlays.WelcomeOverlay             // Welcome overlay for first-time users
 	showWelcomeOverlay  bool                                 // Whether showing welcome overlay
 	debugLogger         *debug.DebugLogger                   // Debug logger for development
 	debugOverlay        *overlays.DebugOverlay               // Debug overlay for development
 	showDebugOverlay    bool                                 // Whether showing debug overlay
 	loadingState        *tmux.LoadingState                   // Loading state manager with spinner and stopwatch
+	monitor             *session.Monitor                     // Background monitor for inactive sessions
+	sessionEvents       <-chan session.Event                 // Changes announced by the session manager
+	paneWatcher         *tmux.OutputWatcher                  // Announces output of the previewed session
+	refreshScheduler    *tmux.RefreshScheduler               // Paces captures of the previewed session
+	restarting          bool                                 // Whether a session started from the dialog is still restarting
+	attachOnRestart     bool                                 // Whether to attach once that restart is done
 
 	// Panes using the new Pane interface
 	repoPane  components.Pane // Repos & worktrees pane (will be extracted from WorktreeList)
@@ -89,8 +111,14 @@ func initialModel(subprocess string) model {
 		fmt.Printf("Warning: failed to initialize worktree manager: %v\n", err)
 	}
 
-	// Create session manager
+	// Load user-defined agents before restoring sessions that may use them
+	if err := app.LoadAgents(); err != nil {
+		fmt.Printf("Warning: failed to load user-defined agents: %v\n", err)
+	}
+
+	// Create session manager, sending queued prompts for as long as the TUI runs
 	sessionManager := session.NewManager(worktreeManager)
+	sessionManager.EnableQueueDelivery()
 
 	// Load existing sessions from persistence
 	if err := sessionManager.RestoreSessions(); err != nil {
@@ -98,6 +126,9 @@ func initialModel(subprocess string) model {
 		// Don't fail startup if session restoration fails
 	}
 
+	// Panes follow the session manager through its events
+	sessionEvents, _ := sessionManager.Subscribe()
+
 	// No automatic main session creation - users must explicitly create agents
 
 	// Get agent configuration based on subprocess name
@@ -142,7 +173,9 @@ func initialModel(subprocess string) model {
 	debug.DebugLog("Debug logger initialized successfully")
 
 	// Initialize debug overlay
+	refreshScheduler := tmux.NewRefreshScheduler()
 	debugOverlay := overlays.NewDebugOverlay(debugLogger)
+	debugOverlay.SetRefreshScheduler(refreshScheduler)
 
 	// Set up debug logging for git package (always enabled now)
 	git.DebugLog = debug.DebugLog
@@ -178,6 +211,10 @@ func initialModel(subprocess string) model {
 		debugOverlay:        debugOverlay,
 		showDebugOverlay:    false,
 		loadingState:        loadingState,
+		monitor:             session.NewMonitor(sessionManager),
+		sessionEvents:       sessionEvents,
+		paneWatcher:         tmux.NewOutputWatcher(),
+		refreshScheduler:    refreshScheduler,
 
 		// Initialize panes
 		repoPane:  repoPane,
@@ -237,7 +274,7 @@ func (m model) switchToPane(targetPane layout.FocusState) (model, tea.Cmd) {
 }
 
 // getCurrentTmuxSession returns the active tmux session from the session manager
-func (m *model) getCurrentTmuxSession() *tmux.TmuxSession {
+func (m *model) getCurrentTmuxSession() tmux.Backend {
 	if m.sessionManager == nil {
 		return nil
 	}
@@ -245,11 +282,11 @@ func (m *model) getCurrentTmuxSession() *tmux.TmuxSession {
 	if activeSession == nil {
 		return nil
 	}
-	return activeSession.TmuxSession
+	return activeSession.GetTmuxSession()
 }
 
 // getCurrentShellTmuxSession returns the active shell tmux session from the session manager
-func (m *model) getCurrentShellTmuxSession() *tmux.TmuxSession {
+func (m *model) getCurrentShellTmuxSession() tmux.Backend {
 	if m.sessionManager == nil {
 		return nil
 	}
@@ -260,6 +297,21 @@ func (m *model) getCurrentShellTmuxSession() *tmux.TmuxSession {
 	return activeSession.ShellTmuxSession
 }
 
+// focusedSearchPane returns the focused pane if it is a preview that can search
+func (m *model) focusedSearchPane() panes.SearchablePane {
+	var pane components.Pane
+	switch m.focused {
+	case layout.FocusTmux:
+		pane = m.tmuxPane
+	case layout.FocusShell:
+		pane = m.shellPane
+	}
+	if searchable, ok := pane.(panes.SearchablePane); ok {
+		return searchable
+	}
+	return nil
+}
+
 // switchToSessionForWorktree switches to the session associated with the given worktree
 func (m *model) switchToSessionForWorktree(worktree *git.WorktreeInfo) {
 	if m.sessionManager == nil || worktree == nil {
@@ -274,15 +326,15 @@ func (m *model) switchToSessionForWorktree(worktree *git.WorktreeInfo) {
 	}
 
 	// Switch to this session
-	m.sessionManager.SwitchToSession(sess.WorktreeKey)
+	m.sessionManager.SwitchToSession(sess.GetID())
 
 	// Update global agent state
-	app.SetCurrentAgent(sess.Agent)
+	app.SetCurrentAgent(sess.GetAgent())
 
 	// Update tmux pane with the session
 	if m.tmuxPane != nil {
 		if tmuxPane, ok := m.tmuxPane.(*panes.AgentTmuxPane); ok {
-			tmuxPane.SetSession(sess.TmuxSession)
+			tmuxPane.SetSession(sess.GetTmuxSession())
 		}
 	}
 
@@ -293,7 +345,69 @@ func (m *model) switchToSessionForWorktree(worktree *git.WorktreeInfo) {
 		}
 	}
 
-	debug.DebugLog("Switched to session %s with agent %s", sess.ID, sess.Agent.Name)
+	debug.DebugLog("Switched to session %s with agent %s", sess.GetID(), sess.GetAgent().Name)
+}
+
+// showStartedSession switches to a session whose agent was just started and
+// focuses its preview
+func (m *model) showStartedSession(newSession *session.Session) tea.Cmd {
+	// Switch to the new session
+	m.sessionManager.SwitchToSession(newSession.GetID())
+
+	// Update agent based on new session
+	app.SetCurrentAgent(newSession.GetAgent())
+
+	// Update tmux pane with new session
+	if m.tmuxPane != nil {
+		if tmuxPane, ok := m.tmuxPane.(*panes.AgentTmuxPane); ok {
+			tmuxPane.SetSession(newSession.GetTmuxSession())
+		}
+	}
+
+	// Update shell pane with new session
+	if m.shellPane != nil {
+		if shellPane, ok := m.shellPane.(*panes.ShellTmuxPane); ok {
+			shellPane.SetSession(newSession.ShellTmuxSession)
+		}
+	}
+
+	// Switch focus to tmux pane
+	m.focused = layout.FocusTmux
+	// Update footer focus
+	m.footer.SetFocus(layout.FocusTmux.String())
+	m.shortcutOverlay.SetFocus(layout.FocusTmux.String())
+
+	// Start monitoring the new session
+	if tmuxSession := newSession.GetTmuxSession(); tmuxSession != nil {
+		return waitForTmuxOutput(tmuxSession)
+	}
+	return nil
+}
+
+// restartSession replaces a session's agent off the UI goroutine, as stopping
+// the old agent and starting the new one take a while
+func restartSession(sessionMgr *session.Manager, sessionID, agentName string) tea.Cmd {
+	return func() tea.Msg {
+		sess, err := sessionMgr.RestartSession(sessionID, agentName)
+		return sessionRestartedMsg{session: sess, err: err}
+	}
+}
+
+// resumeSession relaunches a stopped session off the UI goroutine
+func resumeSession(sessionMgr *session.Manager, sessionID string) tea.Cmd {
+	return func() tea.Msg {
+		sess, err := sessionMgr.ResumeSession(sessionID)
+		return sessionResumedMsg{session: sess, err: err}
+	}
+}
+
+// resumeIfStopped relaunches a stopped session so it can be attached to
+func (m *model) resumeIfStopped(sess *session.Session) error {
+	if m.sessionManager == nil || sess.GetState() != session.StateStopped {
+		return nil
+	}
+	_, err := m.sessionManager.ResumeSession(sess.GetID())
+	return err
 }
 
 // updateGitPane updates the Git pane based on the currently selected worktree/repo
@@ -334,9 +448,54 @@ func (m model) Init() tea.Cmd {
 		startInitialMainSession(m.sessionManager, m.subprocess),
 		tea.EnterAltScreen,
 		m.loadingState.TickCmd(),
+		scheduleMonitorSweep(m.monitor),
+		waitForSessionEvent(m.sessionEvents),
+		scheduleSessionSync(m.sessionManager),
 	)
 }
 
+// scheduleSessionSync reloads persisted sessions after sessionSyncInterval.
+// Changes reach the UI as session events.
+func scheduleSessionSync(sessionMgr *session.Manager) tea.Cmd {
+	return tea.Tick(sessionSyncInterval, func(time.Time) tea.Msg {
+		if err := sessionMgr.SyncPersistedSessions(); err != nil {
+			debug.DebugLog("Failed to sync persisted sessions: %v", err)
+		}
+		return sessionSyncedMsg{}
+	})
+}
+
+// waitForSessionEvent delivers the next session manager event to Update
+func waitForSessionEvent(events <-chan session.Event) tea.Cmd {
+	return func() tea.Msg {
+		event, ok := <-events
+		if !ok {
+			return nil
+		}
+		return sessionEventMsg{event: event}
+	}
+}
+
+// scheduleMonitorSweep waits for the monitor's interval before the next background sweep
+func scheduleMonitorSweep(monitor *session.Monitor) tea.Cmd {
+	return tea.Tick(monitor.Interval, func(time.Time) tea.Msg {
+		return monitorTickMsg{}
+	})
+}
+
+// probeSessions probes a batch of background sessions off the UI goroutine,
+// and checks whether the active session's agent still runs, which its
+// preview refreshes leave out
+func probeSessions(monitor *session.Monitor, batch []tmux.Backend, active tmux.Backend) tea.Cmd {
+	return func() tea.Msg {
+		msg := monitorResultsMsg{results: monitor.ProbeAll(batch)}
+		if active != nil {
+			msg.active = &session.MonitorResult{SessionName: active.GetSessionName(), Probe: session.ProbePaneStatus(active)}
+		}
+		return msg
+	}
+}
+
 func startInitialMainSession(sessionMgr *session.Manager, agentName string) tea.Cmd {
 	return func() tea.Msg {
 		// Only create main session if we're in a git repository
@@ -360,8 +519,12 @@ func startInitialMainSession(sessionMgr *session.Manager, agentName string) tea.
 		existingSession := sessionMgr.GetMainSession(mainWorktree.RepoName)
 		if existingSession != nil {
 			debug.DebugLog("Main session already exists for repo: %s", mainWorktree.RepoName)
+			// The main session is what agate was launched for, so bring it back if it stopped
+			if _, err := sessionMgr.ResumeSession(existingSession.GetID()); err != nil {
+				debug.DebugLog("Failed to resume main session: %v", err)
+			}
 			// Switch to existing main session
-			sessionMgr.SwitchToSession(existingSession.WorktreeKey)
+			sessionMgr.SwitchToSession(existingSession.GetID())
 			return tmuxSessionStartedMsg{session: existingSession}
 		}
 
@@ -373,29 +536,64 @@ func startInitialMainSession(sessionMgr *session.Manager, agentName string) tea.
 		}
 
 		// Set as active session
-		sessionMgr.SwitchToSession(sess.WorktreeKey)
+		sessionMgr.SwitchToSession(sess.GetID())
 
 		debug.DebugLog("Created main session for repo: %s", mainWorktree.RepoName)
 		return tmuxSessionStartedMsg{session: sess}
 	}
 }
 
-func waitForTmuxOutput(session *tmux.TmuxSession) tea.Cmd {
+func waitForTmuxOutput(tmuxSession tmux.Backend) tea.Cmd {
 	return func() tea.Msg {
+		sessionName := tmuxSession.GetSessionName()
+
 		// Capture tmux pane content with ANSI codes preserved
-		content, err := session.CapturePaneContent()
+		content, err := tmuxSession.CapturePaneContent()
 		if err != nil {
-			return tmuxOutputMsg{content: ""}
+			return tmuxOutputMsg{sessionName: sessionName, probe: session.ProbeTmuxSession(tmuxSession, "", false)}
 		}
 
-		// Check if output has changed
-		updated, hasPrompt := session.HasUpdated()
+		// Check if output has changed; the monitor's sweeps check whether the agent still runs
+		updated, _ := tmuxSession.HasContentUpdated(content)
+		probe := session.ProbePaneContent(tmuxSession, content, updated)
 		if !updated {
-			return tmuxOutputMsg{content: ""}
+			return tmuxOutputMsg{sessionName: sessionName, probe: probe}
 		}
 
 		// Return the raw content with ANSI codes
-		return tmuxOutputMsg{content: content, hasPrompt: hasPrompt}
+		return tmuxOutputMsg{content: content, sessionName: sessionName, probe: probe}
+	}
+}
+
+// scheduleTmuxOutput captures the active session again when the refresh
+// scheduler says so. tmux announces new output through a control-mode client
+// and PTY sessions through their emulator; without either the scheduler polls.
+func (m *model) scheduleTmuxOutput() tea.Cmd {
+	currentTmux := m.getCurrentTmuxSession()
+	if currentTmux == nil || !m.refreshScheduler.Schedule() {
+		return nil
+	}
+
+	var changes <-chan struct{}
+	switch backend := currentTmux.(type) {
+	case *tmux.TmuxSession:
+		if m.paneWatcher.Watch(backend) {
+			changes = m.paneWatcher.Changes()
+		}
+	case *tmux.PtySession:
+		changes = backend.Changes()
+	}
+
+	sessionManager := m.sessionManager
+	scheduler := m.refreshScheduler
+	return func() tea.Msg {
+		scheduler.Wait(changes)
+		if activeSession := sessionManager.GetActiveSession(); activeSession != nil {
+			if tmuxSession := activeSession.GetTmuxSession(); tmuxSession != nil {
+				return waitForTmuxOutput(tmuxSession)()
+			}
+		}
+		return nil
 	}
 }
 
@@ -421,13 +619,39 @@ type tmuxSessionStartedMsg struct {
 	session *session.Session
 }
 
+// sessionRestartedMsg reports a session whose agent was replaced by restartSession
+type sessionRestartedMsg struct {
+	session *session.Session
+	err     error
+}
+
+// sessionResumedMsg reports a session relaunched by resumeSession
+type sessionResumedMsg struct {
+	session *session.Session
+	err     error
+}
+
 type tmuxOutputMsg struct {
-	content   string
-	hasPrompt bool
+	content     string
+	sessionName string        // tmux session the content was captured from
+	probe       session.Probe // Snapshot used to update the session's lifecycle state
 }
 
 type tmuxDetachedMsg struct{}
 
+type monitorTickMsg struct{}
+
+type monitorResultsMsg struct {
+	results []session.MonitorResult
+	active  *session.MonitorResult // Status of the active session's agent
+}
+
+type sessionEventMsg struct {
+	event session.Event
+}
+
+type sessionSyncedMsg struct{}
+
 type autoAttachMsg struct{}
 
 type initializationCompleteMsg struct{}
@@ -496,6 +720,12 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.gitPane.SetSize(gitWidth, gitHeight)
 		}
 
+		// Update ShellPane size, which the search view needs
+		if m.shellPane != nil {
+			shellWidth, shellHeight := m.layout.GetShellDimensions()
+			m.shellPane.SetSize(shellWidth, shellHeight)
+		}
+
 		// Update Git pane content after all components are sized
 		// This ensures the worktree list has proper dimensions and selection
 		m.updateGitPane()
@@ -505,13 +735,13 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		activeSession := msg.session
 
 		// Set the current agent based on the session's agent
-		app.SetCurrentAgent(activeSession.Agent)
+		app.SetCurrentAgent(activeSession.GetAgent())
 
 		// Initialize loading state for tmux pane
 		if m.tmuxPane != nil {
 			if tmuxPane, ok := m.tmuxPane.(*panes.AgentTmuxPane); ok {
 				tmuxPane.SetLoading(true)
-				tmuxPane.SetSession(activeSession.TmuxSession)
+				tmuxPane.SetSession(activeSession.GetTmuxSession())
 			}
 		}
 
@@ -530,9 +760,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.updateGitPane()
 
 		// Set initial tmux session size using layout
-		if m.ready && activeSession.TmuxSession != nil {
+		tmuxSession := activeSession.GetTmuxSession()
+		if m.ready && tmuxSession != nil {
 			if contentWidth, contentHeight := m.layout.GetTmuxDimensions(); contentWidth > 0 && contentHeight > 0 {
-				if err := activeSession.TmuxSession.SetDetachedSize(contentWidth, contentHeight); err != nil {
+				if err := tmuxSession.SetDetachedSize(contentWidth, contentHeight); err != nil {
 					debug.DebugLog("Failed to set tmux session initial size to %dx%d: %v", contentWidth, contentHeight, err)
 					// Continue - tmux will use default size
 				}
@@ -541,15 +772,23 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		// Start monitoring tmux output and set up loading timeout
 		return m, tea.Batch(
-			waitForTmuxOutput(activeSession.TmuxSession),
+			waitForTmuxOutput(tmuxSession),
 			tea.Tick(3*time.Second, func(time.Time) tea.Msg {
 				return loadingTimeoutMsg{}
 			}),
 		)
 
 	case tmuxOutputMsg:
+		// Advance the session's lifecycle state; the agents list redraws on the resulting event
+		if m.sessionManager != nil {
+			if sess, changed := m.sessionManager.ApplyProbe(msg.sessionName, msg.probe); changed {
+				debug.DebugLog("Session %s is now %s", sess.GetID(), sess.GetState())
+			}
+		}
+
 		// Update tmux pane content
 		if msg.content != "" {
+			m.refreshScheduler.Activity()
 			if m.tmuxPane != nil {
 				if tmuxPane, ok := m.tmuxPane.(*panes.AgentTmuxPane); ok {
 					tmuxPane.SetContent(msg.content)
@@ -568,13 +807,53 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.updateGitPane()
 		}
 
-		// Continue monitoring (increased frequency for better responsiveness)
-		return m, tea.Tick(time.Millisecond*100, func(time.Time) tea.Msg {
-			if currentTmux := m.getCurrentTmuxSession(); currentTmux != nil {
-				return waitForTmuxOutput(currentTmux)()
+		// Continue monitoring once the session produces more output, keeping
+		// scrollback held in the preview in place as the output pushes it back
+		if tmuxPane, ok := m.tmuxPane.(*panes.AgentTmuxPane); ok && msg.content != "" && !tmuxPane.FollowingTail() {
+			return m, tea.Batch(m.scheduleTmuxOutput(), tmuxPane.CaptureScrollback())
+		}
+		return m, m.scheduleTmuxOutput()
+
+	case monitorTickMsg:
+		// Probe the next batch of background sessions
+		batch := m.monitor.NextBatch()
+		var active tmux.Backend
+		if m.sessionManager != nil {
+			if activeSession := m.sessionManager.GetActiveSession(); activeSession != nil && !activeSession.GetState().IsFinal() {
+				active = activeSession.GetTmuxSession()
 			}
-			return nil
-		})
+		}
+		if len(batch) == 0 && active == nil {
+			return m, scheduleMonitorSweep(m.monitor)
+		}
+		return m, probeSessions(m.monitor, batch, active)
+
+	case monitorResultsMsg:
+		// Update background session states and unseen badges
+		m.monitor.Apply(msg.results)
+		if msg.active != nil && m.sessionManager != nil {
+			if sess, changed := m.sessionManager.ApplyProbe(msg.active.SessionName, msg.active.Probe); changed {
+				debug.DebugLog("Session %s is now %s", sess.GetID(), sess.GetState())
+			}
+		}
+		return m, scheduleMonitorSweep(m.monitor)
+
+	case sessionSyncedMsg:
+		return m, scheduleSessionSync(m.sessionManager)
+
+	case sessionEventMsg:
+		// Keep panes in step with the session manager
+		if agentsPane, ok := m.repoPane.(*panes.AgentsPane); ok {
+			agentsPane.HandleSessionEvent(msg.event)
+		}
+		if msg.event.Type == session.EventSessionDeleted {
+			// The selected worktree may have gone with the session
+			m.updateGitPane()
+		}
+		if msg.event.Type == session.EventQueueChanged && m.showQueueDialog && m.queueDialog != nil {
+			m.queueDialog.Refresh()
+		}
+		return m, waitForSessionEvent(m.sessionEvents)
 
 	case autoAttachMsg:
 		// Auto-attach to the tmux session after it's ready
@@ -616,6 +895,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Left content is now handled by WorktreeList directly
 		// ASCII art will be displayed by WorktreeList
 
+		// Output typed while attached should show up at once
+		m.refreshScheduler.Activity()
+
 		// Update footer back to preview mode
 		m.footer.SetMode("preview")
 		m.shortcutOverlay.SetMode("preview")
@@ -640,6 +922,12 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			tea.WindowSize(), // Trigger complete UI layout recalculation
 		)
 
+	case panes.TypeErrorMsg:
+		if tmuxPane, ok := m.tmuxPane.(*panes.AgentTmuxPane); ok {
+			tmuxPane.SetInsertMode(false)
+		}
+		m.err = msg.Err
+
 	case errMsg:
 		m.err = msg.error
 		// Left content error will be displayed by WorktreeList directly
@@ -674,53 +962,70 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if agentName == "" {
 				agentName = m.subprocess // Fallback to subprocess if not provided
 			}
+			if msg.RestartSessionID != "" {
+				// Replace the session's agent, keeping its worktree and shell; the
+				// old agent is stopped and the new one started off the UI goroutine
+				m.restarting = true
+				cmds = append(cmds, restartSession(m.sessionManager, msg.RestartSessionID, agentName))
+				return m, combineCmds(cmds...)
+			}
+
 			// Create or get session for this worktree using session manager
-			newSession, err := m.sessionManager.GetOrCreateSession(msg.Worktree, agentName)
+			var newSession *session.Session
+			var err error
+			if msg.AddAgent {
+				// Adding an agent always starts another session next to the existing ones
+				newSession, err = m.sessionManager.CreateSession(msg.Worktree, agentName)
+			} else {
+				newSession, err = m.sessionManager.GetOrCreateSession(msg.Worktree, agentName)
+			}
 			if err == nil {
-				// Switch to the new session
-				m.sessionManager.SwitchToSession(newSession.WorktreeKey)
-
-				// Update agent based on new session
-				app.SetCurrentAgent(newSession.Agent)
-
-				// Update tmux pane with new session
-				if m.tmuxPane != nil {
-					if tmuxPane, ok := m.tmuxPane.(*panes.AgentTmuxPane); ok {
-						tmuxPane.SetSession(newSession.TmuxSession)
-					}
-				}
-
-				// Update shell pane with new session
-				if m.shellPane != nil {
-					if shellPane, ok := m.shellPane.(*panes.ShellTmuxPane); ok {
-						shellPane.SetSession(newSession.ShellTmuxSession)
-					}
-				}
-
-				// Switch focus to tmux pane
-				m.focused = layout.FocusTmux
-				// Update footer focus
-				m.footer.SetFocus(layout.FocusTmux.String())
-				m.shortcutOverlay.SetFocus(layout.FocusTmux.String())
-
-				// Start monitoring the new session
-				if newSession.TmuxSession != nil {
-					cmds = append(cmds, waitForTmuxOutput(newSession.TmuxSession))
-				}
+				cmds = append(cmds, m.showStartedSession(newSession))
 			} else {
 				debug.DebugLog("Failed to create session for worktree: %v", err)
 			}
 		}
 		return m, combineCmds(cmds...)
 
+	case sessionRestartedMsg:
+		attach := m.attachOnRestart
+		m.restarting = false
+		m.attachOnRestart = false
+		if msg.err != nil {
+			debug.DebugLog("Failed to restart session: %v", msg.err)
+			m.err = msg.err
+			// Nothing to attach to, so the dialog goes away with the error
+			m.showSessionDialog = false
+			m.worktreeDialog = nil
+			return m, nil
+		}
+		cmd := m.showStartedSession(msg.session)
+		if attach {
+			cmd = combineCmds(cmd, func() tea.Msg { return autoAttachMsg{} })
+		}
+		return m, cmd
+
+	case sessionResumedMsg:
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		m.sessionManager.SwitchToSession(msg.session.GetID())
+		m.switchToSessionForWorktree(msg.session.Worktree)
+		return m, nil
+
 	case overlays.WorktreeInitializationCompleteMsg:
+		if !m.showSessionDialog {
+			// The dialog was cancelled or its restart failed
+			return m, nil
+		}
 		// Initialization complete - close dialog and auto-attach
 		m.showSessionDialog = false
 		m.worktreeDialog = nil
-
-		// Refresh the agents pane to show the new session
-		if agentsPane, ok := m.repoPane.(*panes.AgentsPane); ok {
-			agentsPane.Refresh()
+		if m.restarting {
+			// Attach once the new agent is up rather than to the old one
+			m.attachOnRestart = true
+			return m, tea.ClearScreen
 		}
 
 		// Auto-attach to the tmux session
@@ -760,18 +1065,23 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case panes.AttachToSessionMsg:
 		// User wants to attach to a tmux session from the agents pane
-		if msg.Session != nil && msg.Session.TmuxSession != nil {
+		if msg.Session != nil && msg.Session.GetTmuxSession() != nil {
+			// Stopped sessions are relaunched before attaching
+			if err := m.resumeIfStopped(msg.Session); err != nil {
+				return m, func() tea.Msg { return errMsg{err} }
+			}
+
 			// Switch to this session first
 			if m.sessionManager != nil {
-				m.sessionManager.SwitchToSession(msg.Session.WorktreeKey)
+				m.sessionManager.SwitchToSession(msg.Session.GetID())
 
 				// Update agent based on session
-				app.SetCurrentAgent(msg.Session.Agent)
+				app.SetCurrentAgent(msg.Session.GetAgent())
 
 				// Update tmux pane with the session
 				if m.tmuxPane != nil {
 					if tmuxPane, ok := m.tmuxPane.(*panes.AgentTmuxPane); ok {
-						tmuxPane.SetSession(msg.Session.TmuxSession)
+						tmuxPane.SetSession(msg.Session.GetTmuxSession())
 					}
 				}
 
@@ -793,7 +1103,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.shortcutOverlay.SetMode("attached")
 
 			// Attach to the tmux session
-			detachCh, err := msg.Session.TmuxSession.Attach()
+			detachCh, err := msg.Session.GetTmuxSession().Attach()
 			if err != nil {
 				return m, func() tea.Msg { return errMsg{err} }
 			}
@@ -842,25 +1152,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.showSessionConfirm = false
 		m.sessionConfirm = nil
 
-		// Remove session from session manager
-		if m.sessionManager != nil && msg.Session != nil {
-			err := m.sessionManager.DeleteSession(msg.Session.WorktreeKey)
-			if err != nil {
-				debug.DebugLog("Failed to delete session: %v", err)
-				m.err = fmt.Errorf("failed to delete session: %v", err)
-			} else {
-				// Refresh worktree list
-				if m.repoPane != nil {
-					if repoPane, ok := m.repoPane.(*panes.AgentsPane); ok {
-						if err := repoPane.Refresh(); err != nil {
-							debug.DebugLog("Failed to refresh repo pane after session deletion: %v", err)
-						}
-					}
-				}
-				// Update Git pane
-				m.updateGitPane()
-			}
-		}
+		// The dialog already removed the session; panes update on the deletion event
 		return m, nil
 
 	case overlays.SessionDeletionErrorMsg:
@@ -909,6 +1201,91 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.repoDialog = nil
 		return m, nil
 
+	// Search messages
+	case overlays.SearchSubmittedMsg:
+		m.showSearchDialog = false
+		m.searchDialog = nil
+		if pane := m.focusedSearchPane(); pane != nil {
+			return m, pane.StartSearch(msg.Pattern, -1)
+		}
+		return m, nil
+
+	case overlays.SearchResultSelectedMsg:
+		// Show the match in its session's pane
+		m.showSearchDialog = false
+		m.searchDialog = nil
+		sess := m.sessionManager.GetSession(msg.SessionID)
+		if sess == nil {
+			return m, nil
+		}
+		if _, err := m.sessionManager.SwitchToSession(sess.GetID()); err != nil {
+			return m, func() tea.Msg { return errMsg{err} }
+		}
+		m.switchToSessionForWorktree(sess.Worktree)
+		target := layout.FocusTmux
+		if msg.Shell {
+			target = layout.FocusShell
+		}
+		m, cmd := m.switchToPane(target)
+		if pane := m.focusedSearchPane(); pane != nil {
+			return m, combineCmds(cmd, pane.StartSearch(msg.Pattern, msg.Line))
+		}
+		return m, cmd
+
+	case overlays.SearchResultsMsg:
+		if m.showSearchDialog && m.searchDialog != nil {
+			model, cmd := m.searchDialog.Update(msg)
+			m.searchDialog = model.(*overlays.SearchDialog)
+			return m, cmd
+		}
+		return m, nil
+
+	case overlays.SearchCancelledMsg:
+		m.showSearchDialog = false
+		m.searchDialog = nil
+		return m, nil
+
+	// Composer messages
+	case overlays.ComposerDeliveredMsg:
+		if msg.Delivery.Err != nil {
+			debug.DebugLog("Failed to deliver prompt to %s: %v", msg.Delivery.Title, msg.Delivery.Err)
+		}
+		if m.showComposer && m.composer != nil {
+			model, cmd := m.composer.Update(msg)
+			m.composer = model.(*overlays.ComposerDialog)
+			return m, cmd
+		}
+		if msg.Delivery.Err != nil {
+			// The composer was closed while the prompt waited for the agent
+			m.err = fmt.Errorf("failed to deliver prompt to %s: %w", msg.Delivery.Title, msg.Delivery.Err)
+		}
+		return m, nil
+
+	case overlays.QueueClosedMsg:
+		m.showQueueDialog = false
+		m.queueDialog = nil
+		return m, nil
+
+	case overlays.ComposerClosedMsg:
+		m.showComposer = false
+		m.composer = nil
+		if repoPane, ok := m.repoPane.(*panes.AgentsPane); ok {
+			// Sessions that got a broadcast are done with their marks
+			repoPane.Unmark(msg.Sent...)
+		}
+		return m, nil
+
+	case panes.SearchMsg:
+		if msg.Err != nil {
+			debug.DebugLog("Failed to capture history of %s for a search: %v", msg.SessionName, msg.Err)
+		}
+		for _, pane := range []components.Pane{m.tmuxPane, m.shellPane} {
+			if searchable, ok := pane.(panes.SearchablePane); ok {
+				searchable.SetSearchResult(msg)
+			}
+		}
+		return m, nil
+
 	case loadingTimeoutMsg:
 		// After 3 seconds of loading, start periodic updates for stopwatch
 		if m.loadingState.IsLoading() {
@@ -943,7 +1320,28 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		return m, combineCmds(cmds...)
 
+	case tea.FocusMsg:
+		m.refreshScheduler.SetPaused(false)
+		m.monitor.IncludeActive = false
+		return m, nil
+
+	case tea.BlurMsg:
+		// Nobody sees the preview while the terminal is in the background;
+		// the monitor keeps the active session's state current meanwhile
+		m.refreshScheduler.SetPaused(true)
+		m.monitor.IncludeActive = true
+		return m, nil
+
 	case tea.KeyMsg:
+		// Keystrokes usually make the agent redraw
+		m.refreshScheduler.Input()
+		m.monitor.IncludeActive = false
+
+		// In insert mode every key but the detach key goes to the agent
+		if tmuxPane, ok := m.tmuxPane.(*panes.AgentTmuxPane); ok && tmuxPane.InsertMode() {
+			return m, tmuxPane.TypeKey(msg)
+		}
+
 		// If welcome overlay is visible, any key closes it
 		if m.showWelcomeOverlay {
 			m.showWelcomeOverlay = false
@@ -998,6 +1396,37 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, cmd
 		}
 
+		// Handle search dialog input
+		if m.showSearchDialog && m.searchDialog != nil {
+			var cmd tea.Cmd
+			model, cmd := m.searchDialog.Update(msg)
+			m.searchDialog = model.(*overlays.SearchDialog)
+			return m, cmd
+		}
+
+		// Handle composer input
+		if m.showComposer && m.composer != nil {
+			var cmd tea.Cmd
+			model, cmd := m.composer.Update(msg)
+			m.composer = model.(*overlays.ComposerDialog)
+			return m, cmd
+		}
+
+		// Handle queue dialog input
+		if m.showQueueDialog && m.queueDialog != nil {
+			var cmd tea.Cmd
+			model, cmd := m.queueDialog.Update(msg)
+			m.queueDialog = model.(*overlays.QueueDialog)
+			return m, cmd
+		}
+
+		// A search shown in the focused preview takes its keys first
+		if pane := m.focusedSearchPane(); pane != nil && pane.Searching() {
+			if handled, cmd := pane.HandleKey(msg.String()); handled {
+				return m, cmd
+			}
+		}
+
 		// Handle preview mode - navigation and mode switches only
 		switch {
 		case msg.String() == "enter":
@@ -1059,15 +1488,15 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Quit available from both panes - clean up all sessions
 			if m.sessionManager != nil {
 				for _, session := range m.sessionManager.ListSessions() {
-					if session.TmuxSession != nil {
-						if err := session.TmuxSession.Kill(); err != nil {
-							debug.DebugLog("Failed to kill tmux session %s on quit: %v", session.ID, err)
+					if tmuxSession := session.GetTmuxSession(); tmuxSession != nil {
+						if err := tmuxSession.Kill(); err != nil {
+							debug.DebugLog("Failed to kill tmux session %s on quit: %v", session.GetID(), err)
 							// Continue with quit regardless
 						}
 					}
 					if session.ShellTmuxSession != nil {
 						if err := session.ShellTmuxSession.Kill(); err != nil {
-							debug.DebugLog("Failed to kill shell tmux session %s on quit: %v", session.ID, err)
+							debug.DebugLog("Failed to kill shell tmux session %s on quit: %v", session.GetID(), err)
 							// Continue with quit regardless
 						}
 					}
@@ -1084,6 +1513,88 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.showHelp = true
 			return m, nil
 
+		case key.Matches(msg, common.GlobalKeys.Search):
+			// Search the focused preview's history; elsewhere, every session's
+			paneName := ""
+			switch m.focused {
+			case layout.FocusTmux:
+				paneName = app.GetCurrentAgentName()
+			case layout.FocusShell:
+				paneName = "Shell"
+			}
+			if m.focusedSearchPane() == nil {
+				paneName = ""
+			}
+			m.searchDialog = overlays.NewSearchDialog(m.sessionManager, paneName)
+			m.showSearchDialog = true
+			return m, m.searchDialog.Init()
+
+		case key.Matches(msg, common.GlobalKeys.Compose):
+			// Write a prompt for the hovered session, or the active one outside the agents pane
+			if m.sessionManager != nil {
+				var sess *session.Session
+				if repoPane, ok := m.repoPane.(*panes.AgentsPane); ok && m.focused == layout.FocusAgents {
+					sess = repoPane.GetSelectedSession()
+				}
+				if sess == nil {
+					sess = m.sessionManager.GetActiveSession()
+				}
+				var targets []*session.Session
+				if sess != nil {
+					targets = append(targets, sess)
+				}
+				m.composer = overlays.NewComposerDialog(m.sessionManager, targets)
+				m.showComposer = true
+				return m, m.composer.Init()
+			}
+
+		case key.Matches(msg, common.GlobalKeys.Queue):
+			// Edit the queue of the hovered session, or the active one outside the agents pane
+			if m.sessionManager != nil {
+				var sess *session.Session
+				if repoPane, ok := m.repoPane.(*panes.AgentsPane); ok && m.focused == layout.FocusAgents {
+					sess = repoPane.GetSelectedSession()
+				}
+				if sess == nil {
+					sess = m.sessionManager.GetActiveSession()
+				}
+				if sess == nil {
+					return m, nil
+				}
+				m.queueDialog = overlays.NewQueueDialog(m.sessionManager, sess)
+				m.showQueueDialog = true
+				return m, m.queueDialog.Init()
+			}
+
+		case key.Matches(msg, common.GlobalKeys.MarkSession):
+			// Mark the hovered session for a broadcast
+			if repoPane, ok := m.repoPane.(*panes.AgentsPane); ok && m.focused == layout.FocusAgents {
+				repoPane.ToggleMark()
+			}
+			return m, nil
+
+		case key.Matches(msg, common.GlobalKeys.Broadcast):
+			// Write one prompt for every marked session, or the hovered or active one when none is marked
+			if repoPane, ok := m.repoPane.(*panes.AgentsPane); ok && m.sessionManager != nil {
+				targets := repoPane.MarkedSessions()
+				if len(targets) == 0 {
+					var sess *session.Session
+					if m.focused == layout.FocusAgents {
+						sess = repoPane.GetSelectedSession()
+					}
+					if sess == nil {
+						sess = m.sessionManager.GetActiveSession()
+					}
+					if sess != nil {
+						targets = append(targets, sess)
+					}
+				}
+				m.composer = overlays.NewComposerDialog(m.sessionManager, targets)
+				m.showComposer = true
+				return m, m.composer.Init()
+			}
+			return m, nil
+
 		case key.Matches(msg, common.GlobalKeys.AddRepo):
 			// Add new repository using fzf search
 			debug.DebugLog("Creating new repo dialog...")
@@ -1112,12 +1623,73 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, nil
 			}
 
+		case key.Matches(msg, common.GlobalKeys.AddAgent):
+			// Add another agent to the hovered worktree, or the active one outside the agents pane
+			if m.worktreeManager != nil && m.sessionManager != nil {
+				var worktree *git.WorktreeInfo
+				if repoPane, ok := m.repoPane.(*panes.AgentsPane); ok && m.focused == layout.FocusAgents {
+					worktree = repoPane.GetHoveredWorktree()
+				}
+				if worktree == nil {
+					if active := m.sessionManager.GetActiveSession(); active != nil {
+						worktree = active.Worktree
+					}
+				}
+				if worktree == nil {
+					return m, nil
+				}
+
+				defaultAgent, _ := config.GetDefaultAgent()
+				if defaultAgent == "" {
+					defaultAgent = m.subprocess
+				}
+				m.worktreeDialog = overlays.NewAddAgentDialog(m.worktreeManager, worktree, defaultAgent)
+				m.showSessionDialog = true
+				return m, nil
+			}
+
+		case key.Matches(msg, common.GlobalKeys.RestartAgent):
+			// Restart the hovered session, or the active one outside the agents pane, with another agent
+			if m.worktreeManager != nil && m.sessionManager != nil {
+				var sess *session.Session
+				if repoPane, ok := m.repoPane.(*panes.AgentsPane); ok && m.focused == layout.FocusAgents {
+					sess = repoPane.GetSelectedSession()
+				}
+				if sess == nil {
+					sess = m.sessionManager.GetActiveSession()
+				}
+				if sess == nil || sess.Worktree == nil {
+					return m, nil
+				}
+
+				m.worktreeDialog = overlays.NewRestartAgentDialog(m.worktreeManager, sess.Worktree, sess.GetID(), sess.GetAgent().Name)
+				m.showSessionDialog = true
+				return m, nil
+			}
+
+		case key.Matches(msg, common.GlobalKeys.ResumeSession):
+			// Relaunch the hovered session, or the active one outside the agents pane
+			if m.sessionManager != nil {
+				var sess *session.Session
+				if repoPane, ok := m.repoPane.(*panes.AgentsPane); ok && m.focused == layout.FocusAgents {
+					sess = repoPane.GetSelectedSession()
+				}
+				if sess == nil {
+					sess = m.sessionManager.GetActiveSession()
+				}
+				if sess == nil {
+					return m, nil
+				}
+
+				return m, resumeSession(m.sessionManager, sess.GetID())
+			}
+
 		case key.Matches(msg, common.GlobalKeys.DeleteWorktree):
 			// Delete worktree (when left pane focused)
 			if m.focused == layout.FocusAgents && m.worktreeList != nil {
 				selected := m.worktreeList.GetSelected()
 				if selected != nil {
-					m.worktreeConfirm = overlays.NewWorktreeConfirmDialog(selected, m.worktreeManager)
+					m.worktreeConfirm = overlays.NewWorktreeConfirmDialog(selected, m.sessionManager)
 					m.showWorktreeConfirm = true
 					return m, nil
 				}
@@ -1127,6 +1699,14 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Delete entire session (when repos pane focused and session active)
 			if m.focused == layout.FocusAgents && m.sessionManager != nil {
 				if repoPane, ok := m.repoPane.(*panes.AgentsPane); ok {
+					// An agent row in a worktree with several agents deletes just that agent
+					if sess := repoPane.GetSelectedSession(); sess != nil && sess.Worktree != nil &&
+						len(m.sessionManager.GetSessionsForWorktree(sess.Worktree)) > 1 {
+						m.sessionConfirm = overlays.NewSessionDeleteConfirmDialog(sess, m.sessionManager)
+						m.showSessionConfirm = true
+						return m, nil
+					}
+
 					selected := repoPane.GetSelectedWorktree()
 					if selected != nil {
 						// Check if this is the main worktree (can't be deleted)
@@ -1160,6 +1740,11 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case key.Matches(msg, common.GlobalKeys.Up):
 			// Navigate up in focused pane
 			switch m.focused {
+			case layout.FocusTmux:
+				if m.tmuxPane != nil {
+					_, cmd := m.tmuxPane.HandleKey(msg.String())
+					return m, cmd
+				}
 			case layout.FocusAgents:
 				if m.repoPane != nil {
 					m.repoPane.MoveUp()
@@ -1175,6 +1760,11 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case key.Matches(msg, common.GlobalKeys.Down):
 			// Navigate down in focused pane
 			switch m.focused {
+			case layout.FocusTmux:
+				if m.tmuxPane != nil {
+					_, cmd := m.tmuxPane.HandleKey(msg.String())
+					return m, cmd
+				}
 			case layout.FocusAgents:
 				if m.repoPane != nil {
 					m.repoPane.MoveDown()
@@ -1187,6 +1777,15 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			return m, nil
 
+		case key.Matches(msg, common.GlobalKeys.PreviewPageUp, common.GlobalKeys.PreviewPageDown,
+			common.GlobalKeys.PreviewTop, common.GlobalKeys.PreviewBottom, common.GlobalKeys.FollowTail):
+			// Browse the agent's scrollback in the preview
+			if m.focused == layout.FocusTmux && m.tmuxPane != nil {
+				_, cmd := m.tmuxPane.HandleKey(msg.String())
+				return m, cmd
+			}
+			return m, nil
+
 		// OpenInEditor is now handled by GitPane's HandleKey method directly
 		// No separate global keybinding needed
 
@@ -1208,6 +1807,13 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		case key.Matches(msg, common.GlobalKeys.AttachTmux):
 			// Attach to agent tmux session (global shortcut 'a')
+			if m.sessionManager != nil {
+				if active := m.sessionManager.GetActiveSession(); active != nil {
+					if err := m.resumeIfStopped(active); err != nil {
+						return m, func() tea.Msg { return errMsg{err} }
+					}
+				}
+			}
 			if currentTmux := m.getCurrentTmuxSession(); currentTmux != nil {
 				// Update UI to show attached mode
 				m.footer.SetMode("attached")
@@ -1223,6 +1829,21 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m.Update(tmuxDetachedMsg{})
 			}
 
+		case key.Matches(msg, common.GlobalKeys.InsertMode):
+			// Type into the agent without leaving the dashboard
+			if tmuxPane, ok := m.tmuxPane.(*panes.AgentTmuxPane); ok && m.focused == layout.FocusTmux {
+				if active := m.sessionManager.GetActiveSession(); active != nil {
+					if err := m.resumeIfStopped(active); err != nil {
+						return m, func() tea.Msg { return errMsg{err} }
+					}
+				}
+				if currentTmux := m.getCurrentTmuxSession(); currentTmux != nil {
+					tmuxPane.SetSession(currentTmux)
+					tmuxPane.SetInsertMode(true)
+				}
+			}
+			return m, nil
+
 		case key.Matches(msg, common.GlobalKeys.AttachShell):
 			// Attach to shell tmux session (global shortcut 's')
 			if currentShellTmux := m.getCurrentShellTmuxSession(); currentShellTmux != nil {
@@ -1245,25 +1866,29 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 	case tea.MouseMsg:
-		// Handle mouse events when right pane is focused
-		if currentTmux := m.getCurrentTmuxSession(); m.focused == layout.FocusTmux && currentTmux != nil {
-			switch msg.Action {
-			case tea.MouseActionPress:
-				if msg.Button == tea.MouseButtonWheelUp {
-					// Enter copy mode and scroll up
-					if err := currentTmux.SendScrollUp(); err != nil {
-						debug.DebugLog("Failed to send scroll up to tmux session: %v", err)
-					}
-				} else if msg.Button == tea.MouseButtonWheelDown {
-					// Scroll down (or exit copy mode if at bottom)
-					if err := currentTmux.SendScrollDown(); err != nil {
-						debug.DebugLog("Failed to send scroll down to tmux session: %v", err)
-					}
+		m.refreshScheduler.Input()
+		m.monitor.IncludeActive = false
+
+		// Scroll the agent preview through its scrollback when right pane is focused
+		if tmuxPane, ok := m.tmuxPane.(*panes.AgentTmuxPane); ok && m.focused == layout.FocusTmux {
+			if msg.Action == tea.MouseActionPress {
+				switch msg.Button {
+				case tea.MouseButtonWheelUp:
+					return m, tmuxPane.HandleWheel(true)
+				case tea.MouseButtonWheelDown:
+					return m, tmuxPane.HandleWheel(false)
 				}
 			}
-			// Trigger content refresh after scroll
-			return m, waitForTmuxOutput(currentTmux)
 		}
+
+	case panes.ScrollbackMsg:
+		if tmuxPane, ok := m.tmuxPane.(*panes.AgentTmuxPane); ok {
+			if msg.Err != nil {
+				debug.DebugLog("Failed to capture scrollback of %s: %v", msg.SessionName, msg.Err)
+			}
+			tmuxPane.SetScrollback(msg)
+		}
+		return m, nil
 	}
 
 	return m, nil
@@ -1466,6 +2091,27 @@ func (m model) View() string {
 		return overlay.PlaceOverlay(0, 0, m.repoDialog.View(), mainView, true, true)
 	}
 
+	// If search dialog is visible, overlay it
+	if m.showSearchDialog && m.searchDialog != nil {
+		// Update dialog size
+		m.searchDialog.SetSize(m.layout.GetWidth(), m.layout.GetHeight())
+
+		// Use Claude Squad's overlay implementation
+		return overlay.PlaceOverlay(0, 0, m.searchDialog.View(), mainView, true, true)
+	}
+
+	// If the composer is visible, overlay it
+	if m.showComposer && m.composer != nil {
+		m.composer.SetSize(m.layout.GetWidth(), m.layout.GetHeight())
+		return overlay.PlaceOverlay(0, 0, m.composer.View(), mainView, true, true)
+	}
+
+	// If the prompt queue is visible, overlay it
+	if m.showQueueDialog && m.queueDialog != nil {
+		m.queueDialog.SetSize(m.layout.GetWidth(), m.layout.GetHeight())
+		return overlay.PlaceOverlay(0, 0, m.queueDialog.View(), mainView, true, true)
+	}
+
 	// If worktree deletion confirmation is visible, overlay it
 	if m.showWorktreeConfirm && m.worktreeConfirm != nil {
 		// Update dialog size
@@ -1488,20 +2134,153 @@ func (m model) View() string {
 }
 
 func checkTmuxInstalled() error {
-	if _, err := os.Stat("/usr/local/bin/tmux"); os.IsNotExist(err) {
-		if _, err := os.Stat("/usr/bin/tmux"); os.IsNotExist(err) {
-			return fmt.Errorf("tmux is not installed. Please install tmux to use Agate.\nOn macOS: brew install tmux\nOn Ubuntu/Debian: sudo apt-get install tmux")
+	if !tmux.Available() {
+		return fmt.Errorf("tmux is not installed. Please install tmux to use Agate.\nOn macOS: brew install tmux\nOn Ubuntu/Debian: sudo apt-get install tmux")
+	}
+	return nil
+}
+
+// selectBackend picks the terminal backend new sessions run on from
+// config.json, falling back to a PTY of agate's own when tmux isn't installed
+func selectBackend() error {
+	settings, err := config.LoadSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load backend settings: %v\n", err)
+		settings = &config.Settings{}
+	}
+
+	switch tmux.BackendKind(settings.Backend) {
+	case tmux.BackendPty:
+		tmux.SetBackendKind(tmux.BackendPty)
+	case tmux.BackendTmux:
+		if err := checkTmuxInstalled(); err != nil {
+			return err
+		}
+		tmux.SetBackendKind(tmux.BackendTmux)
+	case "":
+		if checkTmuxInstalled() != nil {
+			debug.DebugLog("tmux is not installed, running sessions on PTYs")
+			tmux.SetBackendKind(tmux.BackendPty)
+		} else {
+			tmux.SetBackendKind(tmux.BackendTmux)
+		}
+	default:
+		return fmt.Errorf("unknown backend %q in config.json, expected %q or %q", settings.Backend, tmux.BackendTmux, tmux.BackendPty)
+	}
+	return nil
+}
+
+// tmuxConfigName is the tmux config agate's server loads from the agate directory
+const tmuxConfigName = "tmux.conf"
+
+// configureTmuxServer selects the tmux server from config.json. Unless told
+// otherwise, agate runs its own server that ignores the user's .tmux.conf.
+func configureTmuxServer() {
+	var tmuxSettings config.TmuxSettings
+	settings, err := config.LoadSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load tmux settings: %v\n", err)
+	} else if settings.Tmux != nil {
+		tmuxSettings = *settings.Tmux
+	}
+
+	server := tmux.Server{
+		SocketName: tmuxSettings.SocketName,
+		SocketPath: expandHome(tmuxSettings.SocketPath),
+		ConfigFile: expandHome(tmuxSettings.ConfigFile),
+	}
+	if server.SocketName == "" && server.SocketPath == "" {
+		server.SocketName = tmux.AgateSocketName
+	}
+	if server.ConfigFile == "" {
+		server.ConfigFile = os.DevNull
+		if agateDir, err := config.GetAgateDir(); err == nil {
+			if path := filepath.Join(agateDir, tmuxConfigName); fileExists(path) {
+				server.ConfigFile = path
+			}
+		}
+	}
+	tmux.SetServer(server)
+}
+
+// configureDetachKey selects the key that detaches from attached sessions
+// from config.json and shows it in the help
+func configureDetachKey() error {
+	settings, err := config.LoadSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load detach key settings: %v\n", err)
+		settings = &config.Settings{}
+	}
+
+	if settings.DetachKey != "" {
+		if err := tmux.SetDetachKey(settings.DetachKey); err != nil {
+			return fmt.Errorf("%w in config.json", err)
 		}
 	}
+	help := tmux.CurrentDetachKey().Help()
+	common.GlobalKeys.DetachTmux.SetHelp(help, "detach from tmux")
 	return nil
 }
 
+// expandHome replaces a leading ~/ with the user's home directory
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
+
+// fileExists reports whether path is an existing regular file
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
 func runAgent(subprocess string) error {
-	if err := checkTmuxInstalled(); err != nil {
+	if err := selectBackend(); err != nil {
+		return err
+	}
+	configureTmuxServer()
+	if err := configureDetachKey(); err != nil {
 		return err
 	}
 
-	p := tea.NewProgram(initialModel(subprocess), tea.WithAltScreen(), tea.WithMouseCellMotion())
+	m := initialModel(subprocess)
+	defer m.paneWatcher.Close()
+
+	// Serve the control API so editor plugins and scripts can drive this agate
+	serving := false
+	if socketPath, err := control.SocketPath(); err == nil {
+		if server, err := control.Listen(socketPath, m.sessionManager); err != nil {
+			debug.DebugLog("Control socket unavailable: %v", err)
+		} else {
+			serving = true
+			defer server.Close()
+		}
+	}
+
+	// Run the user's hooks on session lifecycle events. Every agate UI
+	// monitors all sessions, so only the one serving the control socket
+	// runs the hooks about their states.
+	hookRunner, err := hooks.LoadRunner()
+	if err != nil {
+		debug.DebugLog("Failed to load hooks: %v", err)
+	}
+	if !serving {
+		hookRunner.SkipStateHooks()
+	}
+	stopHooks := hookRunner.Start(m.sessionManager)
+	defer func() {
+		// Let hooks of the last changes, e.g. sessions deleted before quitting, finish
+		stopHooks()
+		if !hookRunner.WaitTimeout(hookQuitTimeout) {
+			debug.DebugLog("Quitting with hooks still running")
+		}
+	}()
+
+	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion(), tea.WithReportFocus())
 	if _, err := p.Run(); err != nil {
 		return fmt.Errorf("error running program: %v", err)
 	}
@@ -1524,13 +2303,17 @@ Agate provides two interaction modes:
   Attached Mode (a): Full tmux experience with complete control
 
 Press 'a' when focused on the right pane to attach to tmux.
-Press Ctrl+Q when attached to detach back to preview.
+Press Ctrl+Q (or detach_key from config.json) when attached to detach back
+to preview.
 Press ? for help once running.
 
 Examples:
   agate claude    # Launch with Claude
   agate amp       # Launch with Amp
-  agate cn        # Launch with Continue`,
+  agate cn        # Launch with Continue
+
+Sessions can also be managed without the UI using the ls, new, rm, attach
+and prune commands.`,
 		Args: cobra.ArbitraryArgs,
 		RunE: func(_ *cobra.Command, args []string) error {
 			if showVersion {
@@ -1545,6 +2328,7 @@ Examples:
 	}
 
 	rootCmd.Flags().BoolVarP(&showVersion, "version", "v", false, "Show version information")
+	rootCmd.AddCommand(newSessionCommands()...)
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -3,15 +3,22 @@ package app
 
 import (
 	"os/exec"
+	"path/filepath"
 	"strings"
+
+	"agate/pkg/tmux"
 )
 
 // AgentConfig defines the configuration for different AI agents
 type AgentConfig struct {
-	Name           string // Display name
-	BorderColor    string // Hex color value for pane borders
-	ExecutableName string // What to match against in subprocess names
-	CompanyName    string // Company name to display in UI
+	Name           string              // Display name
+	BorderColor    string              // Hex color value for pane borders
+	ExecutableName string              // What to match against in subprocess names
+	CompanyName    string              // Company name to display in UI
+	Args           []string            // Extra arguments passed when launching the agent
+	Env            map[string]string   // Extra environment variables for the agent
+	WorkDir        string              // Working directory override, relative to the worktree unless absolute
+	Detection      tmux.DetectionRules // Patterns used to detect the agent's state from its pane
 }
 
 // Claude agent configuration with the specific color
@@ -20,6 +27,15 @@ var ClaudeAgent = AgentConfig{
 	BorderColor:    "#da7756",
 	ExecutableName: "claude",
 	CompanyName:    "Claude Code",
+	Detection: tmux.DetectionRules{
+		WaitingForApproval: []string{
+			`No, and tell Claude what to do differently`,
+			`Do you want to (proceed|make this edit|create|overwrite)`,
+		},
+		Errored: []string{`(?m)^\s*⎿\s+API Error`},
+		Working: []string{`(?i)esc to interrupt`},
+		Idle:    []string{`\? for shortcuts`},
+	},
 }
 
 // Amp agent configuration with the specific color
@@ -36,6 +52,11 @@ var GeminiAgent = AgentConfig{
 	BorderColor:    "#cda9fc",
 	ExecutableName: "gemini",
 	CompanyName:    "Gemini",
+	Detection: tmux.DetectionRules{
+		WaitingForApproval: []string{`Allow execution\?`, `Apply this change\?`},
+		Working:            []string{`\(esc to cancel`},
+		Idle:               []string{`Type your message`},
+	},
 }
 
 // Codex agent configuration with the specific color
@@ -44,9 +65,14 @@ var CodexAgent = AgentConfig{
 	BorderColor:    "#6c908e",
 	ExecutableName: "codex",
 	CompanyName:    "Codex",
+	Detection: tmux.DetectionRules{
+		WaitingForApproval: []string{`Allow command\?`, `Would you like to (run|make) the following`},
+		Errored:            []string{`(?i)stream error`},
+		Working:            []string{`(?i)esc to interrupt`},
+		Idle:               []string{`⏎ send`},
+	},
 }
 
-
 // OpenCode agent configuration with the specific color
 var OpenCodeAgent = AgentConfig{
 	Name:           "opencode",
@@ -89,22 +115,27 @@ var DefaultAgent = AgentConfig{
 // GetAgentConfig returns the appropriate agent configuration based on the subprocess name
 func GetAgentConfig(subprocess string) AgentConfig {
 	// Convert to lowercase for case-insensitive matching
-	lower := strings.ToLower(subprocess)
-
-	// List of all available agents
-	agents := []AgentConfig{
-		ClaudeAgent,
-		AmpAgent,
-		GeminiAgent,
-		CodexAgent,
-		ContinueAgent,
-		OpenCodeAgent,
-		CursorAgent,
-		GithubCopilotAgent,
-	}
+	lower := strings.ToLower(strings.TrimSpace(subprocess))
+
+	// List of all available agents, including user-defined ones
+	agents := GetAllAgents()
 
-	// Check if the subprocess contains any known agent executable names
+	// Prefer an exact match on the agent name, then on the executable name
 	for _, agent := range agents {
+		if strings.ToLower(agent.Name) == lower {
+			return agent
+		}
+	}
+	for _, agent := range agents {
+		if strings.ToLower(agent.ExecutableName) == lower {
+			return agent
+		}
+	}
+
+	// Check if the subprocess contains any known agent executable names.
+	// User-defined agents are checked first since they tend to wrap built-ins.
+	for i := len(agents) - 1; i >= 0; i-- {
+		agent := agents[i]
 		if strings.Contains(lower, strings.ToLower(agent.ExecutableName)) {
 			return agent
 		}
@@ -114,28 +145,23 @@ func GetAgentConfig(subprocess string) AgentConfig {
 	return DefaultAgent
 }
 
-// GetAllAgents returns a list of all configured agents
+// GetAllAgents returns a list of all configured agents: the built-ins
+// (with any user overrides applied) followed by user-defined agents
 func GetAllAgents() []AgentConfig {
-	return []AgentConfig{
-		ClaudeAgent,
-		AmpAgent,
-		GeminiAgent,
-		CodexAgent,
-		ContinueAgent,
-		OpenCodeAgent,
-		CursorAgent,
-		GithubCopilotAgent,
-	}
+	return globalRegistry.all()
 }
 
 // IsValidAgent checks if the given agent name is valid
 func IsValidAgent(name string) bool {
 	// Convert to lowercase for case-insensitive matching
-	lower := strings.ToLower(name)
+	lower := strings.ToLower(strings.TrimSpace(name))
+	if lower == "" {
+		return false
+	}
 
 	agents := GetAllAgents()
 	for _, agent := range agents {
-		if strings.ToLower(agent.ExecutableName) == lower {
+		if strings.ToLower(agent.ExecutableName) == lower || strings.ToLower(agent.Name) == lower {
 			return true
 		}
 	}
@@ -143,6 +169,38 @@ func IsValidAgent(name string) bool {
 	return false
 }
 
+// GetAgentNames returns the names of all configured agents
+func GetAgentNames() []string {
+	agents := GetAllAgents()
+	names := make([]string, 0, len(agents))
+	for _, agent := range agents {
+		names = append(names, agent.Name)
+	}
+	return names
+}
+
+// LaunchCommand returns the executable to start for this agent. Unknown agents
+// fall back to the name the user asked for.
+func (a AgentConfig) LaunchCommand(requested string) string {
+	if a.Name == DefaultAgent.Name || a.ExecutableName == "" {
+		return requested
+	}
+	return a.ExecutableName
+}
+
+// ResolveWorkDir returns the directory the agent should be launched in for
+// the given worktree path
+func (a AgentConfig) ResolveWorkDir(worktreePath string) string {
+	workDir := strings.TrimSpace(a.WorkDir)
+	if workDir == "" {
+		return worktreePath
+	}
+	if filepath.IsAbs(workDir) {
+		return workDir
+	}
+	return filepath.Join(worktreePath, workDir)
+}
+
 // IsInstalled checks if the agent's binary is installed
 func (a AgentConfig) IsInstalled() bool {
 	_, err := exec.LookPath(a.ExecutableName)
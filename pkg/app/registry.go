@@ -0,0 +1,184 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"agate/pkg/config"
+	"agate/pkg/tmux"
+)
+
+// builtinAgents lists the agents agate knows about out of the box
+var builtinAgents = []AgentConfig{
+	ClaudeAgent,
+	AmpAgent,
+	GeminiAgent,
+	CodexAgent,
+	ContinueAgent,
+	OpenCodeAgent,
+	CursorAgent,
+	GithubCopilotAgent,
+}
+
+// agentRegistry holds the merged set of built-in and user-defined agents
+type agentRegistry struct {
+	mu     sync.RWMutex
+	agents []AgentConfig
+}
+
+// globalRegistry is the singleton instance, seeded with the built-in agents
+var globalRegistry = &agentRegistry{
+	agents: cloneAgents(builtinAgents),
+}
+
+// all returns a copy of every registered agent
+func (r *agentRegistry) all() []AgentConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return cloneAgents(r.agents)
+}
+
+// LoadAgents reads user-defined agents from config.json and merges them with
+// the built-in agents. On error the built-in agents remain registered.
+func LoadAgents() error {
+	settings, err := config.LoadSettings()
+	if err != nil {
+		_ = RegisterAgents(nil)
+		return err
+	}
+	return RegisterAgents(settings.Agents)
+}
+
+// RegisterAgents replaces the user-defined agents with the given definitions.
+// Definitions whose name matches a built-in agent override that agent's fields.
+// Detection overrides with invalid patterns, and environment variables with
+// invalid names, are ignored and reported.
+func RegisterAgents(definitions []config.AgentSettings) error {
+	agents := cloneAgents(builtinAgents)
+	var errs []error
+
+	for _, def := range definitions {
+		name := strings.TrimSpace(def.Name)
+		if name == "" {
+			continue
+		}
+
+		index := -1
+		for i, agent := range agents {
+			if strings.EqualFold(agent.Name, name) {
+				index = i
+				break
+			}
+		}
+
+		if def.Detection != nil {
+			if err := detectionRulesFromSettings(def.Detection).Validate(); err != nil {
+				errs = append(errs, fmt.Errorf("agent %q detection rules: %w", name, err))
+				def.Detection = nil
+			}
+		}
+
+		if len(def.Env) > 0 {
+			env := make(map[string]string, len(def.Env))
+			for key, value := range def.Env {
+				if !tmux.ValidEnvKey(key) {
+					errs = append(errs, fmt.Errorf("agent %q env: invalid variable name %q", name, key))
+					continue
+				}
+				env[key] = value
+			}
+			def.Env = env
+		}
+
+		if index >= 0 {
+			agents[index] = mergeAgentSettings(agents[index], def)
+			continue
+		}
+
+		agents = append(agents, mergeAgentSettings(AgentConfig{
+			Name:           name,
+			BorderColor:    DefaultAgent.BorderColor,
+			ExecutableName: name,
+			CompanyName:    name,
+		}, def))
+	}
+
+	globalRegistry.mu.Lock()
+	defer globalRegistry.mu.Unlock()
+	globalRegistry.agents = agents
+
+	return errors.Join(errs...)
+}
+
+// mergeAgentSettings applies the non-empty fields of def on top of base
+func mergeAgentSettings(base AgentConfig, def config.AgentSettings) AgentConfig {
+	if executable := strings.TrimSpace(def.Executable); executable != "" {
+		base.ExecutableName = executable
+	}
+	if displayName := strings.TrimSpace(def.DisplayName); displayName != "" {
+		base.CompanyName = displayName
+	}
+	if color := strings.TrimSpace(def.Color); color != "" {
+		base.BorderColor = color
+	}
+	if workDir := strings.TrimSpace(def.WorkDir); workDir != "" {
+		base.WorkDir = workDir
+	}
+	if len(def.Args) > 0 {
+		base.Args = append([]string{}, def.Args...)
+	}
+	if len(def.Env) > 0 {
+		env := make(map[string]string, len(base.Env)+len(def.Env))
+		for key, value := range base.Env {
+			env[key] = value
+		}
+		for key, value := range def.Env {
+			env[key] = value
+		}
+		base.Env = env
+	}
+	if def.Detection != nil {
+		base.Detection = base.Detection.Merge(detectionRulesFromSettings(def.Detection))
+	}
+	return base
+}
+
+// detectionRulesFromSettings converts persisted detection settings to tmux rules
+func detectionRulesFromSettings(settings *config.DetectionSettings) tmux.DetectionRules {
+	if settings == nil {
+		return tmux.DetectionRules{}
+	}
+	return tmux.DetectionRules{
+		WaitingForApproval: settings.WaitingForApproval,
+		Idle:               settings.Idle,
+		Working:            settings.Working,
+		Errored:            settings.Errored,
+	}
+}
+
+// cloneAgents copies a slice of agents so callers can't mutate registry state
+func cloneAgents(agents []AgentConfig) []AgentConfig {
+	result := make([]AgentConfig, len(agents))
+	for i, agent := range agents {
+		result[i] = agent.clone()
+	}
+	return result
+}
+
+// clone returns a deep copy of the agent configuration
+func (a AgentConfig) clone() AgentConfig {
+	if a.Args != nil {
+		a.Args = append([]string{}, a.Args...)
+	}
+	if a.Env != nil {
+		env := make(map[string]string, len(a.Env))
+		for key, value := range a.Env {
+			env[key] = value
+		}
+		a.Env = env
+	}
+	a.Detection = a.Detection.Clone()
+	return a
+}
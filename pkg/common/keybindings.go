@@ -35,13 +35,34 @@ type GlobalKeyMap struct {
 	// but are globally accessible for convenience
 	AddRepo        key.Binding // r - add repository (repos pane action, but global)
 	NewWorktree    key.Binding // w - create worktree (repos pane action, but global)
+	AddAgent       key.Binding // A - add another agent to the selected worktree
+	RestartAgent   key.Binding // c - restart the selected session with a different agent
+	ResumeSession  key.Binding // R - relaunch a stopped session
 	DeleteWorktree key.Binding // d - delete worktree (repos pane action, context-sensitive)
 	DeleteSession  key.Binding // D - delete entire session (worktree + tmux, destructive)
 
 	// Session interaction - conceptually belongs to panes but globally accessible
 	AttachTmux  key.Binding // a - attach to agent session (tmux)
 	AttachShell key.Binding // s - attach to shell session
-	DetachTmux  key.Binding // Ctrl+Q - detach from tmux session
+	DetachTmux  key.Binding // Ctrl+Q unless configured - detach from tmux session, or leave insert mode
+	InsertMode  key.Binding // i - type into the agent from the preview
+	Compose     key.Binding // p - write a prompt for one or more agents
+	MarkSession key.Binding // Space - mark the hovered session for a broadcast
+	Broadcast   key.Binding // B - write a prompt for every marked session
+	Queue       key.Binding // Q - edit the prompts queued for the session's agent
+
+	// Agent preview scrollback - read-only, the agent's pane isn't touched
+	PreviewPageUp   key.Binding // PgUp - scroll the preview back a page
+	PreviewPageDown key.Binding // PgDn - scroll the preview forward a page
+	PreviewTop      key.Binding // Home, g - scroll to the oldest scrollback
+	PreviewBottom   key.Binding // End, G - follow the live screen again
+	FollowTail      key.Binding // f - toggle following the live screen
+
+	// Preview search - through a snapshot of the session's scrollback
+	Search      key.Binding // / - search the focused preview, or all sessions
+	SearchNext  key.Binding // n - jump to the next older match
+	SearchPrev  key.Binding // N - jump to the next newer match
+	CloseSearch key.Binding // Esc - back to the live preview
 
 	// Dialog actions - global because dialogs overlay all content
 	Confirm key.Binding // Enter, y - confirm dialog action
@@ -110,6 +131,18 @@ var GlobalKeys = &GlobalKeyMap{
 		key.WithKeys("n"),
 		key.WithHelp("n", "new agent"),
 	),
+	AddAgent: key.NewBinding(
+		key.WithKeys("A"),
+		key.WithHelp("A", "add agent"),
+	),
+	RestartAgent: key.NewBinding(
+		key.WithKeys("c"),
+		key.WithHelp("c", "restart with agent"),
+	),
+	ResumeSession: key.NewBinding(
+		key.WithKeys("R"),
+		key.WithHelp("R", "resume session"),
+	),
 	DeleteWorktree: key.NewBinding(
 		key.WithKeys("d"),
 		key.WithHelp("d", "delete worktree"),
@@ -132,6 +165,66 @@ var GlobalKeys = &GlobalKeyMap{
 		key.WithKeys("ctrl+q"),
 		key.WithHelp("ctrl+q", "detach from tmux"),
 	),
+	InsertMode: key.NewBinding(
+		key.WithKeys("i"),
+		key.WithHelp("i", "type into agent"),
+	),
+	Compose: key.NewBinding(
+		key.WithKeys("p"),
+		key.WithHelp("p", "compose prompt"),
+	),
+	MarkSession: key.NewBinding(
+		key.WithKeys(" "),
+		key.WithHelp("space", "mark session"),
+	),
+	Broadcast: key.NewBinding(
+		key.WithKeys("B"),
+		key.WithHelp("B", "broadcast prompt"),
+	),
+	Queue: key.NewBinding(
+		key.WithKeys("Q"),
+		key.WithHelp("Q", "prompt queue"),
+	),
+
+	// Agent preview scrollback
+	PreviewPageUp: key.NewBinding(
+		key.WithKeys("pgup"),
+		key.WithHelp("pgup", "scroll back a page"),
+	),
+	PreviewPageDown: key.NewBinding(
+		key.WithKeys("pgdown"),
+		key.WithHelp("pgdn", "scroll forward a page"),
+	),
+	PreviewTop: key.NewBinding(
+		key.WithKeys("home", "g"),
+		key.WithHelp("home/g", "scroll to top"),
+	),
+	PreviewBottom: key.NewBinding(
+		key.WithKeys("end", "G"),
+		key.WithHelp("end/G", "scroll to bottom"),
+	),
+	FollowTail: key.NewBinding(
+		key.WithKeys("f"),
+		key.WithHelp("f", "toggle follow tail"),
+	),
+
+	// Preview search
+	Search: key.NewBinding(
+		key.WithKeys("/"),
+		key.WithHelp("/", "search output"),
+	),
+	SearchNext: key.NewBinding(
+		key.WithKeys("n"),
+		key.WithHelp("n", "older match"),
+	),
+	SearchPrev: key.NewBinding(
+		key.WithKeys("N"),
+		key.WithHelp("N", "newer match"),
+	),
+	CloseSearch: key.NewBinding(
+		key.WithKeys("esc"),
+		key.WithHelp("esc", "close search"),
+	),
 
 	// List navigation
 	Filter: key.NewBinding(
@@ -202,10 +295,12 @@ func (k *GlobalKeyMap) FullHelp() [][]key.Binding {
 		{k.Quit, k.Keybindings}, // Global
 		{k.FocusPaneRepos, k.FocusPaneTmux, k.FocusPaneGit, k.FocusPaneShell}, // Direct pane switching
 		{k.Up, k.Down}, // Navigation
-		{k.AddRepo, k.NewWorktree, k.DeleteWorktree, k.DeleteSession}, // Repository & Worktree
-		{k.AttachTmux, k.AttachShell, k.DetachTmux},                   // Session
-		{k.Filter, k.ClearFilter},                                     // Filtering
-		{k.Confirm, k.Cancel},                                         // Dialogs
+		{k.AddRepo, k.NewWorktree, k.AddAgent, k.RestartAgent, k.ResumeSession, k.DeleteWorktree, k.DeleteSession}, // Repository & Worktree
+		{k.AttachTmux, k.AttachShell, k.DetachTmux, k.InsertMode, k.Compose, k.MarkSession, k.Broadcast, k.Queue},  // Session
+		{k.PreviewPageUp, k.PreviewPageDown, k.PreviewTop, k.PreviewBottom, k.FollowTail},                          // Preview scrollback
+		{k.Search, k.SearchNext, k.SearchPrev, k.CloseSearch},                                                      // Preview search
+		{k.Filter, k.ClearFilter}, // Filtering
+		{k.Confirm, k.Cancel},     // Dialogs
 	}
 }
 
@@ -229,6 +324,9 @@ func (k *GlobalKeyMap) GetHelpSections() map[string][]key.Binding {
 		"Repository & Worktree Management": {
 			k.AddRepo,
 			k.NewWorktree,
+			k.AddAgent,
+			k.RestartAgent,
+			k.ResumeSession,
 			k.DeleteWorktree,
 			k.DeleteSession,
 		},
@@ -236,6 +334,24 @@ func (k *GlobalKeyMap) GetHelpSections() map[string][]key.Binding {
 			k.AttachTmux,
 			k.AttachShell,
 			k.DetachTmux,
+			k.InsertMode,
+			k.Compose,
+			k.MarkSession,
+			k.Broadcast,
+			k.Queue,
+		},
+		"Preview Scrollback": {
+			k.PreviewPageUp,
+			k.PreviewPageDown,
+			k.PreviewTop,
+			k.PreviewBottom,
+			k.FollowTail,
+		},
+		"Preview Search": {
+			k.Search,
+			k.SearchNext,
+			k.SearchPrev,
+			k.CloseSearch,
 		},
 		"List Controls": {
 			k.Filter,
@@ -0,0 +1,75 @@
+package config
+
+import "slices"
+
+// maxComposerHistory caps how many sent prompts the composer remembers
+const maxComposerHistory = 100
+
+// ComposerState captures the prompt composer's history and saved snippets.
+type ComposerState struct {
+	History  []string  `json:"history,omitempty"` // Sent prompts, oldest first
+	Snippets []Snippet `json:"snippets,omitempty"`
+}
+
+// Snippet is a saved prompt the composer can insert.
+type Snippet struct {
+	Name string `json:"name"`
+	Text string `json:"text"`
+}
+
+// GetComposerHistory returns the sent prompts, oldest first
+func GetComposerHistory() ([]string, error) {
+	state, err := LoadState()
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{}, state.Composer.History...), nil
+}
+
+// AddComposerHistory records a sent prompt, moving a repeated one to the end
+func AddComposerHistory(text string) error {
+	return UpdateState(func(state *AppState) error {
+		history := slices.DeleteFunc(state.Composer.History, func(existing string) bool {
+			return existing == text
+		})
+		history = append(history, text)
+		if len(history) > maxComposerHistory {
+			history = history[len(history)-maxComposerHistory:]
+		}
+		state.Composer.History = history
+		return nil
+	})
+}
+
+// GetSnippets returns the saved snippets
+func GetSnippets() ([]Snippet, error) {
+	state, err := LoadState()
+	if err != nil {
+		return nil, err
+	}
+	return append([]Snippet{}, state.Composer.Snippets...), nil
+}
+
+// SaveSnippet saves a snippet, replacing one with the same name
+func SaveSnippet(snippet Snippet) error {
+	return UpdateState(func(state *AppState) error {
+		for i, existing := range state.Composer.Snippets {
+			if existing.Name == snippet.Name {
+				state.Composer.Snippets[i] = snippet
+				return nil
+			}
+		}
+		state.Composer.Snippets = append(state.Composer.Snippets, snippet)
+		return nil
+	})
+}
+
+// RemoveSnippet removes the snippet with the given name
+func RemoveSnippet(name string) error {
+	return UpdateState(func(state *AppState) error {
+		state.Composer.Snippets = slices.DeleteFunc(state.Composer.Snippets, func(existing Snippet) bool {
+			return existing.Name == name
+		})
+		return nil
+	})
+}
@@ -6,20 +6,37 @@ import (
 
 // SessionState manages the persistent state of sessions
 type SessionState struct {
-	SessionMappings map[string]PersistedSession `json:"session_mappings"` // WorktreeKey -> PersistedSession
-	ActiveSession   string                      `json:"active_session"`   // Currently active session key
+	SessionMappings map[string]PersistedSession `json:"session_mappings"` // Session ID -> PersistedSession
+	ActiveSession   string                      `json:"active_session"`   // Currently active session ID
 	DefaultAgent    string                      `json:"default_agent"`    // Default agent for new sessions
 }
 
 // PersistedSession represents a session's persistent data
 type PersistedSession struct {
-	ID           string    `json:"id"`
-	WorktreeKey  string    `json:"worktree_key"`
-	TmuxName     string    `json:"tmux_name"`     // Tmux session name
-	AgentName    string    `json:"agent_name"`    // Agent used for this session
-	WorktreePath string    `json:"worktree_path"` // Path to worktree
-	Branch       string    `json:"branch"`        // Branch name
-	RepoName     string    `json:"repo_name"`     // Repository name
+	ID           string `json:"id"`
+	WorktreeKey  string `json:"worktree_key"`
+	Instance     int    `json:"instance,omitempty"`    // Numbers repeated agents within a worktree
+	TmuxName     string `json:"tmux_name"`             // Tmux session name
+	TmuxSocket   string `json:"tmux_socket,omitempty"` // Socket of the tmux server, empty for the default server
+	Backend      string `json:"backend,omitempty"`     // Terminal backend of the agent and shell, empty for tmux
+	AgentName    string `json:"agent_name"`            // Agent used for this session
+	WorktreePath string `json:"worktree_path"`         // Path to worktree
+	Branch       string `json:"branch"`                // Branch name
+	RepoName     string `json:"repo_name"`             // Repository name
+
+	// Shell session running alongside the agent
+	ShellTmuxName string `json:"shell_tmux_name,omitempty"`
+	ShellProgram  string `json:"shell_program,omitempty"`
+	ShellSocket   string `json:"shell_socket,omitempty"` // Socket of the shell's tmux server, empty for the default server
+
+	// Launch options the agent was started with. Its environment, which
+	// often holds API keys, is taken from config.json again instead.
+	AgentProgram string   `json:"agent_program,omitempty"` // Executable, which differs from AgentName for ad-hoc commands
+	AgentArgs    []string `json:"agent_args,omitempty"`
+	AgentWorkDir string   `json:"agent_work_dir,omitempty"`
+
+	Queue []string `json:"queue,omitempty"` // Prompts waiting for the agent to get back to its prompt
+
 	CreatedAt    time.Time `json:"created_at"`
 	LastAccessed time.Time `json:"last_accessed"`
 }
@@ -39,32 +56,19 @@ func GetSessionMappings() (map[string]PersistedSession, error) {
 }
 
 // SaveSessionMapping persists a session mapping
-func SaveSessionMapping(worktreeKey string, session PersistedSession) error {
-	state, err := LoadState()
-	if err != nil {
-		return err
-	}
-
-	if state.Sessions.SessionMappings == nil {
-		state.Sessions.SessionMappings = make(map[string]PersistedSession)
-	}
-
-	state.Sessions.SessionMappings[worktreeKey] = session
-	return SaveState(state)
+func SaveSessionMapping(sessionID string, session PersistedSession) error {
+	return UpdateState(func(state *AppState) error {
+		state.Sessions.SessionMappings[sessionID] = session
+		return nil
+	})
 }
 
 // RemoveSessionMapping removes a session mapping
-func RemoveSessionMapping(worktreeKey string) error {
-	state, err := LoadState()
-	if err != nil {
-		return err
-	}
-
-	if state.Sessions.SessionMappings != nil {
-		delete(state.Sessions.SessionMappings, worktreeKey)
-	}
-
-	return SaveState(state)
+func RemoveSessionMapping(sessionID string) error {
+	return UpdateState(func(state *AppState) error {
+		delete(state.Sessions.SessionMappings, sessionID)
+		return nil
+	})
 }
 
 // GetActiveSession returns the active session key
@@ -78,13 +82,10 @@ func GetActiveSession() (string, error) {
 
 // SetActiveSession sets the active session key
 func SetActiveSession(sessionKey string) error {
-	state, err := LoadState()
-	if err != nil {
-		return err
-	}
-
-	state.Sessions.ActiveSession = sessionKey
-	return SaveState(state)
+	return UpdateState(func(state *AppState) error {
+		state.Sessions.ActiveSession = sessionKey
+		return nil
+	})
 }
 
 // GetDefaultAgent returns the default agent for new sessions
@@ -98,11 +99,8 @@ func GetDefaultAgent() (string, error) {
 
 // SetDefaultAgent sets the default agent for new sessions
 func SetDefaultAgent(agent string) error {
-	state, err := LoadState()
-	if err != nil {
-		return err
-	}
-
-	state.Sessions.DefaultAgent = agent
-	return SaveState(state)
+	return UpdateState(func(state *AppState) error {
+		state.Sessions.DefaultAgent = agent
+		return nil
+	})
 }
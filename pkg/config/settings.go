@@ -0,0 +1,89 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Settings captures user-editable configuration loaded from config.json.
+// Unlike AppState, this file is never written by agate itself.
+type Settings struct {
+	Agents  []AgentSettings     `json:"agents,omitempty"`
+	Hooks   map[string][]string `json:"hooks,omitempty"` // Shell commands run on session events, by event name
+	Tmux    *TmuxSettings       `json:"tmux,omitempty"`
+	Backend string              `json:"backend,omitempty"` // Terminal backend, "tmux" or "pty"; defaults to tmux when installed
+
+	// Keys in tmux's notation that detach from an attached session, e.g.
+	// "C-q" (the default) or "C-a d" for Ctrl+A followed by d
+	DetachKey string `json:"detach_key,omitempty"`
+}
+
+// TmuxSettings selects the tmux server agate runs its sessions on. By default
+// agate uses a server of its own, so its sessions stay out of `tmux ls` and
+// the user's .tmux.conf doesn't apply to them.
+type TmuxSettings struct {
+	SocketName string `json:"socket_name,omitempty"` // Passed to tmux as -L, defaults to "agate"
+	SocketPath string `json:"socket_path,omitempty"` // Passed to tmux as -S, overrides socket_name
+	ConfigFile string `json:"config_file,omitempty"` // tmux config for the server, defaults to ~/.agate/tmux.conf if present
+}
+
+// AgentSettings declares a user-defined agent, or overrides fields of a
+// built-in agent when Name matches one of them.
+type AgentSettings struct {
+	Name        string             `json:"name"`
+	Executable  string             `json:"executable,omitempty"`   // Binary to launch, defaults to Name
+	DisplayName string             `json:"display_name,omitempty"` // Name shown in the UI
+	Color       string             `json:"color,omitempty"`        // Hex color for pane borders
+	Args        []string           `json:"args,omitempty"`         // Extra launch arguments
+	Env         map[string]string  `json:"env,omitempty"`          // Extra environment variables
+	WorkDir     string             `json:"work_dir,omitempty"`     // Launch directory, relative to the worktree
+	Detection   *DetectionSettings `json:"detection,omitempty"`    // State detection overrides
+}
+
+// DetectionSettings holds regular expressions used to recognise an agent's
+// state from its pane content. Each non-empty list replaces the agent's
+// built-in patterns for that state.
+type DetectionSettings struct {
+	WaitingForApproval []string `json:"waiting_for_approval,omitempty"`
+	Idle               []string `json:"idle,omitempty"`
+	Working            []string `json:"working,omitempty"`
+	Errored            []string `json:"errored,omitempty"`
+}
+
+// GetSettingsFilePath returns the path to the user config.json file
+func GetSettingsFilePath() (string, error) {
+	agateDir, err := GetAgateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(agateDir, "config.json"), nil
+}
+
+// LoadSettings loads user settings from disk. A missing file yields empty
+// settings; a malformed file is reported so the user can fix it.
+func LoadSettings() (*Settings, error) {
+	settingsFile, err := GetSettingsFilePath()
+	if err != nil {
+		return nil, err
+	}
+
+	settings := &Settings{}
+
+	data, err := os.ReadFile(settingsFile)
+	if os.IsNotExist(err) {
+		return settings, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return settings, nil
+	}
+
+	if err := json.Unmarshal(data, settings); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", settingsFile, err)
+	}
+
+	return settings, nil
+}
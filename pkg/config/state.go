@@ -17,6 +17,7 @@ type AppState struct {
 	UI        UIState        `json:"ui"`
 	Workspace WorkspaceState `json:"workspace"`
 	Sessions  SessionState   `json:"sessions"`
+	Composer  ComposerState  `json:"composer"`
 }
 
 func defaultAppState() AppState {
@@ -114,7 +115,9 @@ func LoadState() (*AppState, error) {
 	return &state, nil
 }
 
-// SaveState saves the application state to disk
+// SaveState saves the application state to disk, replacing the file at once
+// so readers never see it half written. Changes to the stored state should go
+// through UpdateState, which keeps other agate processes' changes.
 func SaveState(state *AppState) error {
 	if state == nil {
 		return errors.New("state cannot be nil")
@@ -136,5 +139,53 @@ func SaveState(state *AppState) error {
 		return err
 	}
 
-	return os.WriteFile(stateFile, data, 0644)
+	tmp, err := os.CreateTemp(filepath.Dir(stateFile), "state-*.json.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // Fails harmlessly once renamed
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	// Only the user may read it, as it holds their prompts and launch options
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), stateFile)
+}
+
+// UpdateState changes the state on disk while holding the state lock, so
+// agate processes sharing state.json don't overwrite each other's changes
+func UpdateState(change func(state *AppState) error) error {
+	if err := EnsureAgateDir(); err != nil {
+		return err
+	}
+	unlock, err := lockState()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	state, err := LoadState()
+	if err != nil {
+		return err
+	}
+	if err := change(state); err != nil {
+		return err
+	}
+	return SaveState(state)
+}
+
+// getStateLockPath returns the path to the file locked while state.json is updated
+func getStateLockPath() (string, error) {
+	agateDir, err := GetAgateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(agateDir, "state.lock"), nil
 }
@@ -0,0 +1,35 @@
+//go:build !windows
+
+package config
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockState takes an exclusive lock on the state lock file, waiting for
+// other agate processes to release it
+func lockState() (func(), error) {
+	lockFile, err := getStateLockPath()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(lockFile, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return func() {
+		_ = syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
@@ -0,0 +1,14 @@
+//go:build windows
+
+package config
+
+import "sync"
+
+// stateMu serializes state updates within the process; Windows has no flock
+var stateMu sync.Mutex
+
+// lockState locks state updates made by this process
+func lockState() (func(), error) {
+	stateMu.Lock()
+	return stateMu.Unlock, nil
+}
@@ -21,11 +21,8 @@ func GetWelcomeShownState() (bool, error) {
 
 // SetWelcomeShown sets the welcome shown state
 func SetWelcomeShown(shown bool) error {
-	state, err := LoadState()
-	if err != nil {
-		return err
-	}
-
-	state.UI.Welcome.Shown = shown
-	return SaveState(state)
+	return UpdateState(func(state *AppState) error {
+		state.UI.Welcome.Shown = shown
+		return nil
+	})
 }
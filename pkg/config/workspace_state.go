@@ -31,38 +31,32 @@ func GetRepositories() ([]string, error) {
 
 // AddRepository adds a repository to the list if it's not already present
 func AddRepository(repoPath string) error {
-	state, err := LoadState()
-	if err != nil {
-		return err
-	}
-
-	for _, existing := range state.Workspace.Repositories {
-		if existing == repoPath {
-			return nil // Already exists, no need to add
+	return UpdateState(func(state *AppState) error {
+		for _, existing := range state.Workspace.Repositories {
+			if existing == repoPath {
+				return nil // Already exists, no need to add
+			}
 		}
-	}
 
-	state.Workspace.Repositories = append(state.Workspace.Repositories, repoPath)
-	return SaveState(state)
+		state.Workspace.Repositories = append(state.Workspace.Repositories, repoPath)
+		return nil
+	})
 }
 
 // RemoveRepository removes a repository from the list
 func RemoveRepository(repoPath string) error {
-	state, err := LoadState()
-	if err != nil {
-		return err
-	}
-
-	filtered := state.Workspace.Repositories[:0]
-	for _, existing := range state.Workspace.Repositories {
-		if existing != repoPath {
-			filtered = append(filtered, existing)
+	return UpdateState(func(state *AppState) error {
+		filtered := state.Workspace.Repositories[:0]
+		for _, existing := range state.Workspace.Repositories {
+			if existing != repoPath {
+				filtered = append(filtered, existing)
+			}
 		}
-	}
 
-	state.Workspace.Repositories = append([]string{}, filtered...)
-	delete(state.Workspace.RepoSelections, repoPath)
-	return SaveState(state)
+		state.Workspace.Repositories = append([]string{}, filtered...)
+		delete(state.Workspace.RepoSelections, repoPath)
+		return nil
+	})
 }
 
 // GetRepoSelections returns a copy of the stored repo selections.
@@ -101,14 +95,11 @@ func SetLastWorktreeForRepo(repoName string, worktree WorktreeRef) error {
 		return errors.New("repo name cannot be empty")
 	}
 
-	state, err := LoadState()
-	if err != nil {
-		return err
-	}
-
-	state.Workspace.RepoSelections[repoName] = RepoSelection{Worktree: worktree}
-	state.Workspace.LastRepo = repoName
-	return SaveState(state)
+	return UpdateState(func(state *AppState) error {
+		state.Workspace.RepoSelections[repoName] = RepoSelection{Worktree: worktree}
+		state.Workspace.LastRepo = repoName
+		return nil
+	})
 }
 
 // GetLastActiveRepo returns the name of the last repo that was active.
@@ -0,0 +1,205 @@
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// clientEventBuffer is how many events a client buffers before dropping them
+const clientEventBuffer = 64
+
+// ErrClosed is returned by calls on a client whose connection has ended
+var ErrClosed = errors.New("control connection closed")
+
+// Client talks to a running agate over its control socket. It is safe for
+// concurrent use.
+type Client struct {
+	conn    net.Conn
+	writeMu sync.Mutex
+
+	mu         sync.Mutex            // Guards the fields below
+	nextID     int                   // ID of the next request
+	pending    map[int]chan Response // Calls waiting for their response
+	events     chan EventInfo        // Events after Subscribe, nil before
+	subscribed bool
+	closed     bool
+}
+
+// Dial connects to the control socket at path
+func Dial(path string) (*Client, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to agate at %s: %w", path, err)
+	}
+
+	c := &Client{
+		conn:    conn,
+		pending: make(map[int]chan Response),
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+// Close disconnects from agate
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Call invokes method with params and decodes its result into result, which
+// may be nil when the result isn't needed
+func (c *Client) Call(method string, params, result any) error {
+	request := Request{JSONRPC: "2.0", Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		request.Params = data
+	}
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	id := c.nextID
+	c.nextID++
+	responses := make(chan Response, 1)
+	c.pending[id] = responses
+	c.mu.Unlock()
+	request.ID = json.RawMessage(strconv.Itoa(id))
+
+	c.writeMu.Lock()
+	err := json.NewEncoder(c.conn).Encode(request)
+	c.writeMu.Unlock()
+	if err != nil {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return err
+	}
+
+	response, ok := <-responses
+	if !ok {
+		return ErrClosed
+	}
+	if response.Error != nil {
+		return response.Error
+	}
+	if result == nil || len(response.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+// Subscribe starts delivering session events. The channel is closed when the
+// connection ends; events are dropped while it is full.
+func (c *Client) Subscribe() (<-chan EventInfo, error) {
+	c.mu.Lock()
+	if c.events == nil {
+		c.events = make(chan EventInfo, clientEventBuffer)
+	}
+	events := c.events
+	subscribed := c.subscribed
+	c.subscribed = true
+	c.mu.Unlock()
+
+	if !subscribed {
+		if err := c.Call(MethodSubscribe, nil, nil); err != nil {
+			c.mu.Lock()
+			c.subscribed = false
+			c.mu.Unlock()
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+// ListSessions returns all sessions
+func (c *Client) ListSessions() ([]SessionInfo, error) {
+	var sessions []SessionInfo
+	err := c.Call(MethodListSessions, nil, &sessions)
+	return sessions, err
+}
+
+// CreateSession starts an agent on branch, creating its worktree if needed
+func (c *Client) CreateSession(branch, agent string) (SessionInfo, error) {
+	var info SessionInfo
+	err := c.Call(MethodCreateSession, CreateParams{Branch: branch, Agent: agent}, &info)
+	return info, err
+}
+
+// DeleteSession deletes a session, and with removeWorktree its worktree and
+// branch once no other session uses them
+func (c *Client) DeleteSession(ref string, removeWorktree bool) error {
+	return c.Call(MethodDeleteSession, DeleteParams{Session: ref, RemoveWorktree: removeWorktree}, nil)
+}
+
+// SendText types text into a session's agent, pressing Enter after it when submit is set
+func (c *Client) SendText(ref, text string, submit bool) error {
+	return c.Call(MethodSendText, SendParams{Session: ref, Text: text, Submit: submit}, nil)
+}
+
+// CapturePane returns the visible content of a session's agent pane
+func (c *Client) CapturePane(ref string, escapes bool) (string, error) {
+	var result CaptureResult
+	err := c.Call(MethodCapturePane, CaptureParams{Session: ref, Escapes: escapes}, &result)
+	return result.Content, err
+}
+
+// readLoop routes responses to their calls and notifications to the events channel
+func (c *Client) readLoop() {
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		var response Response
+		if err := json.Unmarshal(scanner.Bytes(), &response); err != nil {
+			continue
+		}
+
+		if response.Method == EventNotification {
+			var event EventInfo
+			if err := json.Unmarshal(response.Params, &event); err != nil {
+				continue
+			}
+			c.mu.Lock()
+			if c.events != nil {
+				select {
+				case c.events <- event:
+				default:
+				}
+			}
+			c.mu.Unlock()
+			continue
+		}
+
+		id, err := strconv.Atoi(string(response.ID))
+		if err != nil {
+			continue
+		}
+		c.mu.Lock()
+		responses, ok := c.pending[id]
+		delete(c.pending, id)
+		c.mu.Unlock()
+		if ok {
+			responses <- response
+		}
+	}
+
+	// Fail outstanding calls and end the event stream
+	c.mu.Lock()
+	c.closed = true
+	for id, responses := range c.pending {
+		delete(c.pending, id)
+		close(responses)
+	}
+	if c.events != nil {
+		close(c.events)
+	}
+	c.mu.Unlock()
+}
@@ -0,0 +1,171 @@
+// Package control serves a JSON-RPC 2.0 API for a running agate over a
+// Unix-domain socket. Messages are newline-delimited JSON objects.
+package control
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"time"
+
+	"agate/pkg/config"
+	"agate/pkg/session"
+)
+
+// Methods served by the control socket
+const (
+	MethodListSessions  = "sessions.list"    // No params, returns []SessionInfo
+	MethodCreateSession = "sessions.create"  // CreateParams, returns SessionInfo
+	MethodDeleteSession = "sessions.delete"  // DeleteParams, returns nothing
+	MethodSendText      = "sessions.send"    // SendParams, returns nothing
+	MethodCapturePane   = "sessions.capture" // CaptureParams, returns CaptureResult
+	MethodSubscribe     = "events.subscribe" // No params, then EventNotification notifications
+)
+
+// EventNotification is the method of notifications carrying an EventInfo
+const EventNotification = "event"
+
+// JSON-RPC 2.0 error codes
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeServerError    = -32000
+)
+
+// socketDir and socketName place the control socket in a directory of its
+// own in the agate directory, which only the user may enter
+const (
+	socketDir  = "control"
+	socketName = "agate.sock"
+)
+
+// SocketPath returns the path of the control socket under ~/.agate/control/
+func SocketPath() (string, error) {
+	agateDir, err := config.GetAgateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(agateDir, socketDir, socketName), nil
+}
+
+// Request is a JSON-RPC request, or a notification when ID is absent
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is a JSON-RPC response, or a server notification when Method is set
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error is a JSON-RPC error object
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error implements error
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// SessionParams identifies a session by ID, tmux session name or
+// <branch>[/<agent>], as accepted by session.Manager.FindSession
+type SessionParams struct {
+	Session string `json:"session"`
+}
+
+// DeleteParams selects a session to delete
+type DeleteParams struct {
+	Session        string `json:"session"`
+	RemoveWorktree bool   `json:"remove_worktree,omitempty"` // Delete the worktree and branch once unused
+}
+
+// CreateParams describes a session to create. An empty branch means the main
+// worktree; a branch without a worktree gets a new one.
+type CreateParams struct {
+	Branch string `json:"branch,omitempty"`
+	Agent  string `json:"agent"`
+}
+
+// SendParams is text to type into a session's agent
+type SendParams struct {
+	Session string `json:"session"`
+	Text    string `json:"text"`
+	Submit  bool   `json:"submit,omitempty"` // Press Enter after the text
+}
+
+// CaptureParams selects a session's pane to capture
+type CaptureParams struct {
+	Session string `json:"session"`
+	Escapes bool   `json:"escapes,omitempty"` // Keep ANSI escape sequences
+}
+
+// CaptureResult is the visible content of a session's agent pane
+type CaptureResult struct {
+	Content string `json:"content"`
+}
+
+// SessionInfo describes a session
+type SessionInfo struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"` // tmux session name
+	Repo   string `json:"repo"`
+	Branch string `json:"branch"`
+	Agent  string `json:"agent"`
+	State  string `json:"state"`
+	Path   string `json:"path"`
+	Active bool   `json:"active"`
+}
+
+// DescribeSession returns the SessionInfo for a session
+func DescribeSession(sess *session.Session, active bool) SessionInfo {
+	info := SessionInfo{
+		ID:     sess.GetID(),
+		Name:   sess.GetTmuxSessionName(),
+		Agent:  sess.AgentName(),
+		State:  sess.GetState().String(),
+		Active: active,
+	}
+	if sess.Worktree != nil {
+		info.Repo = sess.Worktree.RepoName
+		info.Branch = sess.Worktree.Branch
+		info.Path = sess.Worktree.Path
+	}
+	return info
+}
+
+// EventInfo is a session manager event as sent to subscribers
+type EventInfo struct {
+	Type          string       `json:"type"`
+	SessionID     string       `json:"session_id"`
+	Session       *SessionInfo `json:"session,omitempty"` // Absent for sessions re-keyed by a restart
+	State         string       `json:"state"`
+	PreviousState string       `json:"previous_state"`
+	At            time.Time    `json:"at"`
+}
+
+// describeEvent returns the EventInfo for a session manager event
+func describeEvent(event session.Event, active *session.Session) EventInfo {
+	info := EventInfo{
+		Type:          event.Type.String(),
+		SessionID:     event.SessionID,
+		State:         event.State.String(),
+		PreviousState: event.PreviousState.String(),
+		At:            event.At,
+	}
+	if event.Session != nil {
+		sessionInfo := DescribeSession(event.Session, event.Session == active)
+		info.Session = &sessionInfo
+	}
+	return info
+}
@@ -0,0 +1,380 @@
+package control
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"agate/internal/debug"
+	"agate/pkg/app"
+	"agate/pkg/config"
+	"agate/pkg/session"
+	"agate/pkg/tmux"
+)
+
+// maxMessageSize bounds a single request line
+const maxMessageSize = 1 << 20
+
+// Server serves the control API for a session manager
+type Server struct {
+	manager  *session.Manager
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu     sync.Mutex            // Guards conns and closed
+	conns  map[net.Conn]struct{} // Open client connections
+	closed bool
+}
+
+// Listen starts serving the control API on a Unix socket at path. A socket
+// left behind by an agate that crashed is replaced; one still being served
+// by another agate is an error.
+//
+// Clients can type into agents and so run commands as the user, so the
+// socket's directory is made private before the socket is created in it.
+func Listen(path string, manager *session.Manager) (*Server, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to restrict %s: %w", dir, err)
+	}
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+
+	s := &Server{
+		manager:  manager,
+		listener: listener,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.acceptLoop()
+	debug.DebugLog("Control socket listening on %s", path)
+	return s, nil
+}
+
+// removeStaleSocket deletes a socket at path that nobody is serving. Anything
+// else at path is left alone.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("another agate is already serving %s", path)
+	}
+	return os.Remove(path)
+}
+
+// Close stops accepting clients, disconnects the connected ones and removes the socket
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+// connection is a client connection. Writes are serialized because event
+// notifications are sent alongside responses.
+type connection struct {
+	writeMu sync.Mutex
+	encoder *json.Encoder
+}
+
+// send writes a message to the client
+func (c *connection) send(response Response) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	response.JSONRPC = "2.0"
+	if err := c.encoder.Encode(response); err != nil {
+		debug.DebugLog("Failed to write to control client: %v", err)
+	}
+}
+
+// reply answers a request; notifications get no reply
+func (c *connection) reply(request Request, result any, err error) {
+	if request.ID == nil {
+		return
+	}
+
+	response := Response{ID: request.ID}
+	if err != nil {
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &Error{Code: CodeServerError, Message: err.Error()}
+		}
+		response.Error = rpcErr
+	} else {
+		data, marshalErr := json.Marshal(result)
+		if marshalErr != nil {
+			response.Error = &Error{Code: CodeServerError, Message: marshalErr.Error()}
+		} else {
+			response.Result = data
+		}
+	}
+	c.send(response)
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	client := &connection{encoder: json.NewEncoder(conn)}
+
+	// Events are forwarded until the client disconnects
+	var unsubscribe func()
+	defer func() {
+		if unsubscribe != nil {
+			unsubscribe()
+		}
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var request Request
+		if err := json.Unmarshal(line, &request); err != nil {
+			client.send(Response{ID: json.RawMessage("null"), Error: &Error{Code: CodeParseError, Message: "parse error"}})
+			continue
+		}
+		if request.JSONRPC != "2.0" || request.Method == "" {
+			id := request.ID
+			if id == nil {
+				id = json.RawMessage("null")
+			}
+			client.send(Response{ID: id, Error: &Error{Code: CodeInvalidRequest, Message: "invalid request"}})
+			continue
+		}
+
+		if request.Method == MethodSubscribe {
+			// Subscribe before replying so no event is missed, but only start
+			// forwarding once the reply is out
+			var events <-chan session.Event
+			if unsubscribe == nil {
+				events, unsubscribe = s.manager.Subscribe()
+			}
+			client.reply(request, struct{}{}, nil)
+			if events != nil {
+				s.wg.Add(1)
+				go s.forwardEvents(client, events)
+			}
+			continue
+		}
+
+		result, err := s.handle(request)
+		client.reply(request, result, err)
+	}
+}
+
+// forwardEvents sends session events to a subscribed client until the
+// subscription ends
+func (s *Server) forwardEvents(client *connection, events <-chan session.Event) {
+	defer s.wg.Done()
+	for event := range events {
+		params, err := json.Marshal(describeEvent(event, s.manager.GetActiveSession()))
+		if err != nil {
+			continue
+		}
+		client.send(Response{Method: EventNotification, Params: params})
+	}
+}
+
+// handle runs a request and returns its result
+func (s *Server) handle(request Request) (any, error) {
+	switch request.Method {
+	case MethodListSessions:
+		return ListSessions(s.manager), nil
+
+	case MethodCreateSession:
+		var params CreateParams
+		if err := decodeParams(request, &params); err != nil {
+			return nil, err
+		}
+		return s.createSession(params)
+
+	case MethodDeleteSession:
+		var params DeleteParams
+		if err := decodeParams(request, &params); err != nil {
+			return nil, err
+		}
+		sess, err := s.manager.FindSession(params.Session)
+		if err != nil {
+			return nil, err
+		}
+		return struct{}{}, s.manager.DeleteSession(sess.GetID(), params.RemoveWorktree)
+
+	case MethodSendText:
+		var params SendParams
+		if err := decodeParams(request, &params); err != nil {
+			return nil, err
+		}
+		return struct{}{}, s.sendText(params)
+
+	case MethodCapturePane:
+		var params CaptureParams
+		if err := decodeParams(request, &params); err != nil {
+			return nil, err
+		}
+		return s.capturePane(params)
+
+	default:
+		return nil, &Error{Code: CodeMethodNotFound, Message: fmt.Sprintf("method not found: %s", request.Method)}
+	}
+}
+
+// decodeParams unmarshals a request's params into v
+func decodeParams(request Request, v any) error {
+	if len(request.Params) == 0 {
+		return &Error{Code: CodeInvalidParams, Message: "missing params"}
+	}
+	if err := json.Unmarshal(request.Params, v); err != nil {
+		return &Error{Code: CodeInvalidParams, Message: fmt.Sprintf("invalid params: %v", err)}
+	}
+	return nil
+}
+
+func (s *Server) createSession(params CreateParams) (SessionInfo, error) {
+	agentName := params.Agent
+	if agentName == "" {
+		agentName, _ = config.GetDefaultAgent()
+	}
+	if agentName == "" {
+		return SessionInfo{}, &Error{Code: CodeInvalidParams, Message: "no agent given and no default agent configured"}
+	}
+	if !app.IsValidAgent(agentName) {
+		return SessionInfo{}, &Error{Code: CodeInvalidParams, Message: fmt.Sprintf("unknown agent %q", agentName)}
+	}
+
+	worktreeManager := s.manager.GetWorktreeManager()
+	if worktreeManager == nil {
+		return SessionInfo{}, fmt.Errorf("worktrees are not available")
+	}
+	worktree, err := worktreeManager.WorktreeForBranch(params.Branch)
+	if err != nil {
+		return SessionInfo{}, err
+	}
+
+	sess, err := s.manager.CreateSession(worktree, agentName)
+	if err != nil {
+		return SessionInfo{}, err
+	}
+	return DescribeSession(sess, false), nil
+}
+
+func (s *Server) sendText(params SendParams) error {
+	sess, err := s.manager.FindSession(params.Session)
+	if err != nil {
+		return err
+	}
+	if params.Submit {
+		return s.manager.SendPrompt(sess.GetID(), params.Text)
+	}
+	if sess.GetTmuxSession() == nil {
+		return fmt.Errorf("session %s has no agent tmux session", sess.GetID())
+	}
+	return sess.GetTmuxSession().PasteText(params.Text)
+}
+
+func (s *Server) capturePane(params CaptureParams) (CaptureResult, error) {
+	sess, err := s.manager.FindSession(params.Session)
+	if err != nil {
+		return CaptureResult{}, err
+	}
+	if sess.GetTmuxSession() == nil {
+		return CaptureResult{}, fmt.Errorf("session %s has no agent tmux session", sess.GetID())
+	}
+
+	content, err := sess.GetTmuxSession().CapturePaneContent()
+	if err != nil {
+		return CaptureResult{}, err
+	}
+	if !params.Escapes {
+		content = tmux.StripANSI(content)
+	}
+	return CaptureResult{Content: content}, nil
+}
+
+// ListSessions describes the manager's sessions ordered by repo, branch and creation
+func ListSessions(manager *session.Manager) []SessionInfo {
+	sessions := manager.ListSessions()
+	sort.SliceStable(sessions, func(i, j int) bool {
+		a, b := sessions[i], sessions[j]
+		if a.Worktree.RepoName != b.Worktree.RepoName {
+			return a.Worktree.RepoName < b.Worktree.RepoName
+		}
+		if a.Worktree.Branch != b.Worktree.Branch {
+			return a.Worktree.Branch < b.Worktree.Branch
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.GetID() < b.GetID()
+	})
+
+	active := manager.GetActiveSession()
+	infos := make([]SessionInfo, 0, len(sessions))
+	for _, sess := range sessions {
+		infos = append(infos, DescribeSession(sess, sess == active))
+	}
+	return infos
+}
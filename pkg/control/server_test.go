@@ -0,0 +1,363 @@
+package control_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"agate/pkg/app"
+	"agate/pkg/config"
+	"agate/pkg/control"
+	"agate/pkg/git"
+	"agate/pkg/session"
+	"agate/pkg/tmux"
+)
+
+// fakeAgent echoes what is typed into it, standing in for a real agent
+const fakeAgent = "fake"
+
+// startServer serves a session manager for a fresh git repository on a
+// temporary socket. Sessions run on PTYs, so no tmux server is involved.
+func startServer(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SHELL", "/bin/sh")
+
+	backend := tmux.CurrentBackendKind()
+	tmux.SetBackendKind(tmux.BackendPty)
+	t.Cleanup(func() { tmux.SetBackendKind(backend) })
+
+	if err := app.RegisterAgents([]config.AgentSettings{{Name: fakeAgent, Executable: "cat"}}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = app.RegisterAgents(nil) })
+
+	repo := filepath.Join(home, "repo")
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main", repo},
+		{"-C", repo, "-c", "user.name=agate", "-c", "user.email=agate@example.com", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	worktreeManager, err := git.NewWorktreeManagerAt(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager := session.NewManager(worktreeManager)
+	t.Cleanup(func() {
+		for _, sess := range manager.ListSessions() {
+			_ = manager.DeleteSession(sess.GetID(), true)
+		}
+	})
+
+	socketPath := filepath.Join(home, "control", "agate.sock")
+	server, err := control.Listen(socketPath, manager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+	return socketPath
+}
+
+// dial connects a client to the server's socket
+func dial(t *testing.T, socketPath string) *control.Client {
+	t.Helper()
+	client, err := control.Dial(socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+// errorCode returns the JSON-RPC error code of a call's error
+func errorCode(t *testing.T, err error) int {
+	t.Helper()
+	var rpcErr *control.Error
+	if !errors.As(err, &rpcErr) {
+		t.Fatalf("error = %v, want a JSON-RPC error", err)
+	}
+	return rpcErr.Code
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	socketPath := startServer(t)
+	client := dial(t, socketPath)
+
+	sessions, err := client.ListSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 0 {
+		t.Fatalf("ListSessions = %+v, want none", sessions)
+	}
+
+	events, err := client.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := client.CreateSession("", fakeAgent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Agent != fakeAgent || info.Branch != "main" || info.Repo != "repo" {
+		t.Errorf("CreateSession = %+v, want the fake agent on main in repo", info)
+	}
+	waitForEvent(t, events, session.EventSessionCreated.String(), info.ID)
+
+	sessions, err = client.ListSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != info.ID {
+		t.Fatalf("ListSessions = %+v, want the created session", sessions)
+	}
+
+	if err := client.SendText(info.ID, "hello agate", false); err != nil {
+		t.Fatal(err)
+	}
+	waitForPane(t, client, "main/"+fakeAgent, "hello agate")
+	if err := client.SendText(info.Name, " and goodbye", true); err != nil {
+		t.Fatal(err)
+	}
+	waitForPane(t, client, info.ID, "hello agate and goodbye")
+
+	if err := client.DeleteSession(info.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	waitForEvent(t, events, session.EventSessionDeleted.String(), info.ID)
+	sessions, err = client.ListSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("ListSessions after delete = %+v, want none", sessions)
+	}
+}
+
+func TestDeleteSessionKeepsWorktree(t *testing.T) {
+	socketPath := startServer(t)
+	client := dial(t, socketPath)
+
+	info, err := client.CreateSession("feature", fakeAgent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.DeleteSession(info.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(info.Path); err != nil {
+		t.Fatalf("worktree after delete: %v, want it kept", err)
+	}
+
+	info, err = client.CreateSession("feature", fakeAgent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.DeleteSession(info.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(info.Path); !os.IsNotExist(err) {
+		t.Errorf("worktree after delete with remove_worktree: %v, want it gone", err)
+	}
+}
+
+// waitForEvent waits for an event of the given type about a session
+func waitForEvent(t *testing.T, events <-chan control.EventInfo, eventType, sessionID string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatalf("events closed before %s for %s", eventType, sessionID)
+			}
+			if event.Type == eventType && event.SessionID == sessionID {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no %s event for %s", eventType, sessionID)
+		}
+	}
+}
+
+// waitForPane waits for a session's pane to show text
+func waitForPane(t *testing.T, client *control.Client, ref, text string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		content, err := client.CapturePane(ref, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(content, text) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("pane of %s = %q, want it to show %q", ref, content, text)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestErrorReplies(t *testing.T) {
+	socketPath := startServer(t)
+	client := dial(t, socketPath)
+
+	tests := []struct {
+		name string
+		call func() error
+		want int
+	}{
+		{"unknown session", func() error { return client.DeleteSession("nowhere", false) }, control.CodeServerError},
+		{"unknown session to send to", func() error { return client.SendText("nowhere", "hi", true) }, control.CodeServerError},
+		{"unknown session to capture", func() error {
+			_, err := client.CapturePane("nowhere", false)
+			return err
+		}, control.CodeServerError},
+		{"missing params", func() error { return client.Call(control.MethodDeleteSession, nil, nil) }, control.CodeInvalidParams},
+		{"invalid params", func() error { return client.Call(control.MethodSendText, []string{"text"}, nil) }, control.CodeInvalidParams},
+		{"unknown agent", func() error {
+			_, err := client.CreateSession("", "nobody")
+			return err
+		}, control.CodeInvalidParams},
+		{"unknown method", func() error { return client.Call("sessions.rename", nil, nil) }, control.CodeMethodNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorCode(t, tt.call()); got != tt.want {
+				t.Errorf("error code = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	// The connection is still usable after errors
+	if _, err := client.ListSessions(); err != nil {
+		t.Errorf("ListSessions after errors: %v", err)
+	}
+}
+
+func TestMalformedRequests(t *testing.T) {
+	socketPath := startServer(t)
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	responses := bufio.NewScanner(conn)
+
+	tests := []struct {
+		name    string
+		request string
+		id      string
+		code    int
+	}{
+		{"not JSON", `{"jsonrpc": "2.0", "id": 1,`, "null", control.CodeParseError},
+		{"wrong version", `{"jsonrpc": "1.0", "id": 2, "method": "sessions.list"}`, "2", control.CodeInvalidRequest},
+		{"no method", `{"jsonrpc": "2.0", "id": 3}`, "3", control.CodeInvalidRequest},
+		// Notifications get no reply, so the next response is the list's
+		{"notification", `{"jsonrpc": "2.0", "method": "sessions.rename"}` + "\n" +
+			`{"jsonrpc": "2.0", "id": "list", "method": "sessions.list"}`, `"list"`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := conn.Write([]byte(tt.request + "\n")); err != nil {
+				t.Fatal(err)
+			}
+			if !responses.Scan() {
+				t.Fatalf("no response: %v", responses.Err())
+			}
+			var response control.Response
+			if err := json.Unmarshal(responses.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if string(response.ID) != tt.id {
+				t.Errorf("response ID = %s, want %s", response.ID, tt.id)
+			}
+			switch {
+			case tt.code == 0 && response.Error != nil:
+				t.Errorf("response error = %+v, want a result", response.Error)
+			case tt.code != 0 && (response.Error == nil || response.Error.Code != tt.code):
+				t.Errorf("response error = %+v, want code %d", response.Error, tt.code)
+			}
+		})
+	}
+}
+
+func TestListenRefusesServedSocket(t *testing.T) {
+	socketPath := startServer(t)
+	if server, err := control.Listen(socketPath, session.NewManager(nil)); err == nil {
+		server.Close()
+		t.Fatal("Listen took over a socket another server is serving")
+	}
+
+	// The running server is unaffected
+	if _, err := dial(t, socketPath).ListSessions(); err != nil {
+		t.Errorf("ListSessions: %v", err)
+	}
+}
+
+func TestListenKeepsSocketPrivate(t *testing.T) {
+	socketPath := startServer(t)
+	info, err := os.Stat(filepath.Dir(socketPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0700 {
+		t.Errorf("socket directory mode = %v, want 0700", perm)
+	}
+
+	// A directory others could enter is restricted before listening
+	dir := filepath.Join(t.TempDir(), "open")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	server, err := control.Listen(filepath.Join(dir, "agate.sock"), session.NewManager(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+	info, err = os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0700 {
+		t.Errorf("socket directory mode after Listen = %v, want 0700", perm)
+	}
+}
+
+func TestListenLeavesOtherFilesAlone(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, []byte("keep"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	subdir := filepath.Join(dir, "dir")
+	if err := os.Mkdir(subdir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{file, subdir} {
+		if server, err := control.Listen(path, session.NewManager(nil)); err == nil {
+			server.Close()
+			t.Errorf("Listen replaced %s", path)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s was removed: %v", path, err)
+		}
+	}
+}
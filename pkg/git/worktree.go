@@ -63,7 +63,11 @@ func NewWorktreeManager() (*WorktreeManager, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get working directory: %w", err)
 	}
+	return NewWorktreeManagerAt(workDir)
+}
 
+// NewWorktreeManagerAt creates a worktree manager for the repository containing workDir
+func NewWorktreeManagerAt(workDir string) (*WorktreeManager, error) {
 	// Get repository root
 	repoPath, err := getRepositoryRoot(workDir)
 	isGitRepo := err == nil
@@ -326,6 +330,33 @@ func (wm *WorktreeManager) CreateWorktree(branchName string) (*WorktreeInfo, err
 	}, nil
 }
 
+// WorktreeForBranch finds the worktree checked out on branch, creating one
+// when the branch has none. An empty branch means the main worktree.
+func (wm *WorktreeManager) WorktreeForBranch(branch string) (*WorktreeInfo, error) {
+	mainWorktree, err := wm.GetMainWorktreeInfo()
+	if err != nil {
+		return nil, err
+	}
+	if branch == "" || branch == mainWorktree.Branch {
+		return mainWorktree, nil
+	}
+	if !wm.isGitRepo {
+		return nil, fmt.Errorf("%s is not a git repository", wm.repoPath)
+	}
+
+	groups, err := wm.ListWorktrees()
+	if err != nil {
+		return nil, err
+	}
+	for _, worktree := range groups[wm.GetRepositoryName()] {
+		if worktree.Branch == branch {
+			return &worktree, nil
+		}
+	}
+
+	return wm.CreateWorktree(branch)
+}
+
 // checkBranchExists checks if a branch already exists
 func (wm *WorktreeManager) checkBranchExists(branchName string) error {
 	cmd := exec.Command("git", "show-ref", "--verify", "--quiet", "refs/heads/"+branchName)
@@ -0,0 +1,656 @@
+package overlays
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"agate/internal/debug"
+	"agate/pkg/config"
+	"agate/pkg/gui/theme"
+	"agate/pkg/session"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/truncate"
+)
+
+// Composer dialog dimensions
+const (
+	composerMaxContentWidth = 90
+	composerTextareaHeight  = 8
+	composerSnippetNameLen  = 40
+)
+
+// composerDeliveryTimeout bounds how long a prompt waits for a busy agent
+// to get back to its prompt
+const composerDeliveryTimeout = time.Hour
+
+// composerMode is what the composer's keys act on
+type composerMode int
+
+const (
+	composeMode composerMode = iota // Editing the prompt
+	targetMode                      // Choosing the sessions to send to
+	snippetMode                     // Picking a saved snippet
+)
+
+// ComposerDialog edits a multi-line prompt and delivers it to one or more
+// sessions as a single bracketed paste followed by Enter
+type ComposerDialog struct {
+	textarea       textarea.Model
+	sessionManager *session.Manager
+	mode           composerMode
+	width          int
+	height         int
+	err            string
+	help           help.Model
+	keys           composerKeyMap
+
+	// Sessions the prompt can go to
+	targets     []*session.Session
+	selected    map[string]bool // Session IDs the prompt goes to
+	targetIndex int
+
+	// Sent prompts, oldest first; historyIndex == len(history) is the draft
+	history      []string
+	historyIndex int
+	draft        string
+
+	snippets     []config.Snippet
+	snippetIndex int
+
+	sends      int                // Numbers sends so deliveries of earlier ones are told apart
+	pending    int                // Deliveries of the last send still on their way
+	deliveries []ComposerDelivery // Progress of the last send, per session
+}
+
+// composerKeyMap defines the keybindings for the composer
+type composerKeyMap struct {
+	dialog *ComposerDialog
+
+	Send          key.Binding
+	Queue         key.Binding
+	History       key.Binding
+	HistoryPrev   key.Binding
+	HistoryNext   key.Binding
+	Snippets      key.Binding
+	Targets       key.Binding
+	Escape        key.Binding
+	Move          key.Binding
+	Toggle        key.Binding
+	ToggleAll     key.Binding
+	InsertSnippet key.Binding
+	SaveSnippet   key.Binding
+	DeleteSnippet key.Binding
+	Back          key.Binding
+}
+
+// ShortHelp returns keybindings to show in the mini help view
+func (k composerKeyMap) ShortHelp() []key.Binding {
+	if k.dialog.pending > 0 {
+		return []key.Binding{k.Escape}
+	}
+	switch k.dialog.mode {
+	case targetMode:
+		return []key.Binding{k.Move, k.Toggle, k.ToggleAll, k.Back}
+	case snippetMode:
+		return []key.Binding{k.Move, k.InsertSnippet, k.SaveSnippet, k.DeleteSnippet, k.Back}
+	}
+	return []key.Binding{k.Send, k.Queue, k.History, k.Snippets, k.Targets, k.Escape}
+}
+
+// FullHelp returns keybindings to show in the full help view
+func (k composerKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{k.ShortHelp()}
+}
+
+// ComposerDelivery tracks a prompt on its way to one session
+type ComposerDelivery struct {
+	SessionID string
+	Title     string
+	Waiting   bool // The agent was busy, so the prompt waits for its input prompt
+	Done      bool
+	Err       error
+}
+
+// ComposerDeliveredMsg reports that a prompt reached a session, or failed to
+type ComposerDeliveredMsg struct {
+	Delivery ComposerDelivery
+	composer *ComposerDialog
+	send     int
+}
+
+// ComposerClosedMsg is sent when the composer is closed
+type ComposerClosedMsg struct {
+	Sent []string // Sessions the last prompt went to, including those still waiting for their agent
+}
+
+// NewComposerDialog creates a composer that sends to the given sessions
+// unless other ones are chosen
+func NewComposerDialog(sessionManager *session.Manager, targets []*session.Session) *ComposerDialog {
+	input := textarea.New()
+	input.Placeholder = "Prompt for the agent. Enter adds a line; ctrl+s sends."
+	input.ShowLineNumbers = false
+	input.Prompt = "┃ "
+	input.SetHeight(composerTextareaHeight)
+	// The composer's own keys take ctrl+p, ctrl+n and ctrl+t
+	input.KeyMap.LinePrevious = key.NewBinding(key.WithKeys("up"))
+	input.KeyMap.LineNext = key.NewBinding(key.WithKeys("down"))
+	input.KeyMap.TransposeCharacterBackward.SetEnabled(false)
+	input.Focus()
+
+	history, err := config.GetComposerHistory()
+	if err != nil {
+		debug.DebugLog("Failed to load composer history: %v", err)
+	}
+	snippets, err := config.GetSnippets()
+	if err != nil {
+		debug.DebugLog("Failed to load snippets: %v", err)
+	}
+
+	d := &ComposerDialog{
+		textarea:       input,
+		sessionManager: sessionManager,
+		selected:       make(map[string]bool),
+		history:        history,
+		historyIndex:   len(history),
+		snippets:       snippets,
+		help:           help.New(),
+	}
+	if sessionManager != nil {
+		d.targets = sessionManager.ListSessions()
+	}
+	for _, target := range targets {
+		d.selected[target.GetID()] = true
+	}
+
+	d.keys = composerKeyMap{
+		dialog: d,
+		Send: key.NewBinding(
+			key.WithKeys("ctrl+s"),
+			key.WithHelp("ctrl+s", "send"),
+		),
+		Queue: key.NewBinding(
+			key.WithKeys("ctrl+e"),
+			key.WithHelp("ctrl+e", "queue"),
+		),
+		History: key.NewBinding(
+			key.WithKeys("ctrl+p", "ctrl+n"),
+			key.WithHelp("ctrl+p/n", "history"),
+		),
+		HistoryPrev: key.NewBinding(key.WithKeys("ctrl+p")),
+		HistoryNext: key.NewBinding(key.WithKeys("ctrl+n")),
+		Snippets: key.NewBinding(
+			key.WithKeys("ctrl+o"),
+			key.WithHelp("ctrl+o", "snippets"),
+		),
+		Targets: key.NewBinding(
+			key.WithKeys("ctrl+t"),
+			key.WithHelp("ctrl+t", "sessions"),
+		),
+		Escape: key.NewBinding(
+			key.WithKeys("esc"),
+			key.WithHelp("esc", "close"),
+		),
+		Move: key.NewBinding(
+			key.WithKeys("up", "down", "k", "j"),
+			key.WithHelp("↑/↓", "move"),
+		),
+		Toggle: key.NewBinding(
+			key.WithKeys(" "),
+			key.WithHelp("space", "toggle"),
+		),
+		ToggleAll: key.NewBinding(
+			key.WithKeys("a"),
+			key.WithHelp("a", "all/none"),
+		),
+		InsertSnippet: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("↵", "insert"),
+		),
+		SaveSnippet: key.NewBinding(
+			key.WithKeys("s"),
+			key.WithHelp("s", "save prompt"),
+		),
+		DeleteSnippet: key.NewBinding(
+			key.WithKeys("d"),
+			key.WithHelp("d", "delete"),
+		),
+		Back: key.NewBinding(
+			key.WithKeys("esc", "tab"),
+			key.WithHelp("esc", "back"),
+		),
+	}
+	d.keys.Targets.SetEnabled(len(d.targets) > 1)
+	return d
+}
+
+// SetSize sets the dialog dimensions
+func (d *ComposerDialog) SetSize(width, height int) {
+	d.width = width
+	d.height = height
+}
+
+// Init implements tea.Model
+func (d *ComposerDialog) Init() tea.Cmd {
+	return textarea.Blink
+}
+
+// Update implements tea.Model
+func (d *ComposerDialog) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case ComposerDeliveredMsg:
+		if msg.composer != d || msg.send != d.sends {
+			return d, nil
+		}
+		for i := range d.deliveries {
+			if d.deliveries[i].SessionID == msg.Delivery.SessionID {
+				d.deliveries[i] = msg.Delivery
+			}
+		}
+		d.pending--
+		if d.pending > 0 {
+			return d, nil
+		}
+		for _, delivery := range d.deliveries {
+			if delivery.Err != nil {
+				// Keep the prompt so it can be sent again
+				return d, nil
+			}
+		}
+		d.textarea.Reset()
+		return d, d.close()
+
+	case tea.KeyMsg:
+		if d.pending > 0 {
+			// Busy agents keep their prompt coming after the composer closes
+			if key.Matches(msg, d.keys.Escape) {
+				return d, d.close()
+			}
+			return d, nil
+		}
+		switch d.mode {
+		case targetMode:
+			return d, d.updateTargets(msg)
+		case snippetMode:
+			return d, d.updateSnippets(msg)
+		}
+
+		switch {
+		case key.Matches(msg, d.keys.Escape):
+			return d, d.close()
+		case key.Matches(msg, d.keys.Send):
+			return d, d.send()
+		case key.Matches(msg, d.keys.Queue):
+			return d, d.enqueue()
+		case key.Matches(msg, d.keys.HistoryPrev):
+			d.browseHistory(-1)
+			return d, nil
+		case key.Matches(msg, d.keys.HistoryNext):
+			d.browseHistory(1)
+			return d, nil
+		case key.Matches(msg, d.keys.Snippets):
+			d.mode = snippetMode
+			d.err = ""
+			return d, nil
+		case key.Matches(msg, d.keys.Targets):
+			d.mode = targetMode
+			d.err = ""
+			return d, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	d.textarea, cmd = d.textarea.Update(msg)
+	return d, cmd
+}
+
+// close closes the composer, reporting the sessions the last prompt reached
+// or is still waiting for
+func (d *ComposerDialog) close() tea.Cmd {
+	var sent []string
+	for _, delivery := range d.deliveries {
+		if delivery.Err == nil {
+			sent = append(sent, delivery.SessionID)
+		}
+	}
+	return func() tea.Msg { return ComposerClosedMsg{Sent: sent} }
+}
+
+// updateTargets handles keys while choosing the sessions to send to
+func (d *ComposerDialog) updateTargets(msg tea.KeyMsg) tea.Cmd {
+	switch {
+	case key.Matches(msg, d.keys.Back):
+		d.mode = composeMode
+	case key.Matches(msg, d.keys.Move):
+		if msg.String() == "up" || msg.String() == "k" {
+			d.targetIndex = max(0, d.targetIndex-1)
+		} else {
+			d.targetIndex = min(len(d.targets)-1, d.targetIndex+1)
+		}
+	case key.Matches(msg, d.keys.Toggle):
+		if d.targetIndex < len(d.targets) {
+			id := d.targets[d.targetIndex].GetID()
+			d.selected[id] = !d.selected[id]
+		}
+	case key.Matches(msg, d.keys.ToggleAll):
+		all := len(d.selectedTargets()) < len(d.targets)
+		for _, target := range d.targets {
+			d.selected[target.GetID()] = all
+		}
+	}
+	return nil
+}
+
+// updateSnippets handles keys while picking a saved snippet
+func (d *ComposerDialog) updateSnippets(msg tea.KeyMsg) tea.Cmd {
+	switch {
+	case key.Matches(msg, d.keys.Back):
+		d.mode = composeMode
+	case key.Matches(msg, d.keys.Move):
+		if msg.String() == "up" || msg.String() == "k" {
+			d.snippetIndex = max(0, d.snippetIndex-1)
+		} else {
+			d.snippetIndex = min(len(d.snippets)-1, d.snippetIndex+1)
+		}
+	case key.Matches(msg, d.keys.InsertSnippet):
+		if d.snippetIndex < len(d.snippets) {
+			d.textarea.InsertString(d.snippets[d.snippetIndex].Text)
+			d.mode = composeMode
+		}
+	case key.Matches(msg, d.keys.SaveSnippet):
+		text := d.textarea.Value()
+		if strings.TrimSpace(text) == "" {
+			d.err = "Write a prompt to save it as a snippet"
+			return nil
+		}
+		snippet := config.Snippet{Name: snippetName(text), Text: text}
+		if err := config.SaveSnippet(snippet); err != nil {
+			d.err = fmt.Sprintf("Failed to save snippet: %v", err)
+			return nil
+		}
+		d.err = ""
+		d.reloadSnippets()
+	case key.Matches(msg, d.keys.DeleteSnippet):
+		if d.snippetIndex < len(d.snippets) {
+			if err := config.RemoveSnippet(d.snippets[d.snippetIndex].Name); err != nil {
+				d.err = fmt.Sprintf("Failed to delete snippet: %v", err)
+				return nil
+			}
+			d.reloadSnippets()
+		}
+	}
+	return nil
+}
+
+// reloadSnippets reads the saved snippets again after a change
+func (d *ComposerDialog) reloadSnippets() {
+	snippets, err := config.GetSnippets()
+	if err != nil {
+		d.err = fmt.Sprintf("Failed to load snippets: %v", err)
+		return
+	}
+	d.snippets = snippets
+	d.snippetIndex = min(d.snippetIndex, max(0, len(snippets)-1))
+}
+
+// snippetName names a snippet after the first line of its text
+func snippetName(text string) string {
+	first, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	return truncate.StringWithTail(strings.TrimSpace(first), composerSnippetNameLen, "…")
+}
+
+// browseHistory replaces the prompt with an older or newer sent one,
+// keeping the draft to come back to
+func (d *ComposerDialog) browseHistory(delta int) {
+	index := d.historyIndex + delta
+	if index < 0 || index > len(d.history) {
+		return
+	}
+	if d.historyIndex == len(d.history) {
+		d.draft = d.textarea.Value()
+	}
+	d.historyIndex = index
+	if index == len(d.history) {
+		d.textarea.SetValue(d.draft)
+	} else {
+		d.textarea.SetValue(d.history[index])
+	}
+}
+
+// selectedTargets returns the sessions the prompt goes to, in list order
+func (d *ComposerDialog) selectedTargets() []*session.Session {
+	var targets []*session.Session
+	for _, target := range d.targets {
+		if d.selected[target.GetID()] {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// send delivers the prompt to every selected session at once
+func (d *ComposerDialog) send() tea.Cmd {
+	text, targets, ok := d.prompt()
+	if !ok {
+		return nil
+	}
+	d.sends++
+	d.pending = len(targets)
+	d.deliveries = nil
+
+	// Each session gets the prompt on its own, as soon as its agent is idle
+	var cmds []tea.Cmd
+	for _, target := range targets {
+		delivery := ComposerDelivery{
+			SessionID: target.GetID(),
+			Title:     sessionTitle(target),
+			Waiting:   target.GetState() != session.StateIdle,
+		}
+		d.deliveries = append(d.deliveries, delivery)
+		cmds = append(cmds, d.deliver(delivery, text))
+	}
+	return tea.Batch(cmds...)
+}
+
+// enqueue adds the prompt to the queue of every selected session, to be
+// sent once their agents get back to their prompts
+func (d *ComposerDialog) enqueue() tea.Cmd {
+	text, targets, ok := d.prompt()
+	if !ok {
+		return nil
+	}
+
+	var queued []string
+	for _, target := range targets {
+		if err := d.sessionManager.Enqueue(target.GetID(), text); err != nil {
+			d.err = fmt.Sprintf("Failed to queue the prompt for %s: %v", sessionTitle(target), err)
+			continue
+		}
+		queued = append(queued, target.GetID())
+	}
+	if d.err != "" {
+		return nil
+	}
+	d.textarea.Reset()
+	return func() tea.Msg { return ComposerClosedMsg{Sent: queued} }
+}
+
+// prompt returns the prompt and the sessions it goes to, recording it in the
+// history. It reports false when there is nothing to send or no one to send to.
+func (d *ComposerDialog) prompt() (string, []*session.Session, bool) {
+	text := d.textarea.Value()
+	if strings.TrimSpace(text) == "" {
+		d.err = "The prompt is empty"
+		return "", nil, false
+	}
+	targets := d.selectedTargets()
+	if len(targets) == 0 {
+		d.err = "No session selected; ctrl+t chooses them"
+		return "", nil, false
+	}
+	if d.sessionManager == nil {
+		d.err = "No sessions to send to"
+		return "", nil, false
+	}
+
+	if err := config.AddComposerHistory(text); err != nil {
+		debug.DebugLog("Failed to save composer history: %v", err)
+	}
+	if history, err := config.GetComposerHistory(); err == nil {
+		d.history = history
+	}
+	d.historyIndex = len(d.history)
+	d.draft = ""
+	d.err = ""
+	return text, targets, true
+}
+
+// deliver sends the prompt to one session, waiting for its agent if it is busy
+func (d *ComposerDialog) deliver(delivery ComposerDelivery, text string) tea.Cmd {
+	sessionManager, composer, send := d.sessionManager, d, d.sends
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), composerDeliveryTimeout)
+		defer cancel()
+		delivery.Err = sessionManager.DeliverPrompt(ctx, delivery.SessionID, text)
+		delivery.Done = true
+		return ComposerDeliveredMsg{Delivery: delivery, composer: composer, send: send}
+	}
+}
+
+// sessionTitle names a session by its repository, branch and agent
+func sessionTitle(sess *session.Session) string {
+	title := sess.GetName()
+	if sess.Worktree != nil {
+		title = fmt.Sprintf("%s:%s", sess.Worktree.RepoName, sess.Worktree.Branch)
+	}
+	return title + " · " + sess.AgentLabel()
+}
+
+// View renders the composer
+func (d *ComposerDialog) View() string {
+	frameWidth := dialogStyle.GetHorizontalFrameSize()
+	contentWidth := composerMaxContentWidth
+	if d.width > 0 {
+		contentWidth = min(contentWidth, d.width-frameWidth-4)
+	}
+	contentWidth = max(contentWidth, 20)
+	d.textarea.SetWidth(contentWidth)
+
+	titleStyle := dialogTitleStyle.Copy().MarginBottom(0)
+	mutedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(theme.TextMuted))
+	dividerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(theme.TextDescription))
+
+	var content []string
+	targets := d.selectedTargets()
+	recipient := "no session"
+	switch {
+	case len(targets) == 1:
+		recipient = sessionTitle(targets[0])
+	case len(targets) > 1:
+		recipient = fmt.Sprintf("%d sessions", len(targets))
+	}
+	content = append(content, titleStyle.Render("Compose")+mutedStyle.Render(" > ")+
+		truncate.StringWithTail(recipient, uint(max(contentWidth-10, 1)), "…"))
+	content = append(content, dividerStyle.Render(strings.Repeat("─", contentWidth)), "")
+	content = append(content, d.textarea.View())
+
+	switch d.mode {
+	case targetMode:
+		content = append(content, "", titleStyle.Render("Send to"))
+		content = append(content, d.targetLines(contentWidth)...)
+	case snippetMode:
+		content = append(content, "", titleStyle.Render("Snippets"))
+		content = append(content, d.snippetLines(contentWidth)...)
+	}
+
+	if d.pending > 0 {
+		message := "Sending..."
+		for _, delivery := range d.deliveries {
+			if delivery.Waiting && !delivery.Done {
+				message = "Waiting for busy agents to reach their prompt; esc closes and they still get it"
+				break
+			}
+		}
+		content = append(content, dialogInfoStyle.Render(truncate.StringWithTail(message, uint(contentWidth), "…")))
+	}
+	if len(d.deliveries) > 0 {
+		content = append(content, "")
+		content = append(content, d.deliveryLines(contentWidth)...)
+	}
+	if d.err != "" {
+		content = append(content, dialogErrorStyle.Render(truncate.StringWithTail(d.err, uint(contentWidth), "…")))
+	}
+
+	content = append(content, "", d.help.View(d.keys))
+
+	dialog := dialogStyle.Render(strings.Join(content, "\n"))
+	return lipgloss.Place(d.width, d.height, lipgloss.Center, lipgloss.Center, dialog)
+}
+
+// targetLines renders the sessions with their selection
+func (d *ComposerDialog) targetLines(width int) []string {
+	cursorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(theme.AgateColor))
+	var lines []string
+	for i, target := range d.targets {
+		cursor := "  "
+		if i == d.targetIndex {
+			cursor = cursorStyle.Render("› ")
+		}
+		check := "[ ] "
+		if d.selected[target.GetID()] {
+			check = "[x] "
+		}
+		lines = append(lines, cursor+check+truncate.StringWithTail(sessionTitle(target), uint(max(width-6, 1)), "…"))
+	}
+	return lines
+}
+
+// snippetLines renders the saved snippets
+func (d *ComposerDialog) snippetLines(width int) []string {
+	if len(d.snippets) == 0 {
+		return []string{dialogInfoStyle.Copy().MarginTop(0).Render("No snippets yet; s saves the prompt as one")}
+	}
+	cursorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(theme.AgateColor))
+	var lines []string
+	for i, snippet := range d.snippets {
+		cursor := "  "
+		if i == d.snippetIndex {
+			cursor = cursorStyle.Render("› ")
+		}
+		lines = append(lines, cursor+truncate.StringWithTail(snippet.Name, uint(max(width-2, 1)), "…"))
+	}
+	return lines
+}
+
+// deliveryLines reports the last send per session
+func (d *ComposerDialog) deliveryLines(width int) []string {
+	okStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(theme.SuccessStatus))
+	failStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(theme.ErrorStatus))
+	pendingStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(theme.TextMuted))
+	var lines []string
+	for _, delivery := range d.deliveries {
+		var line string
+		style := okStyle
+		switch {
+		case delivery.Err != nil:
+			line = fmt.Sprintf("✗ %s: %v", delivery.Title, delivery.Err)
+			style = failStyle
+		case delivery.Done:
+			line = "✓ " + delivery.Title
+		case delivery.Waiting:
+			line = "… " + delivery.Title + ": waiting for the agent"
+			style = pendingStyle
+		default:
+			line = "… " + delivery.Title
+			style = pendingStyle
+		}
+		lines = append(lines, style.Render(truncate.StringWithTail(line, uint(width), "…")))
+	}
+	return lines
+}
@@ -3,14 +3,17 @@ package overlays
 import (
 	"agate/internal/debug"
 	"bufio"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"agate/pkg/config"
 	"agate/pkg/gui/theme"
+	"agate/pkg/tmux"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -20,6 +23,7 @@ import (
 type DebugOverlay struct {
 	viewport    viewport.Model
 	debugLogger *debug.DebugLogger
+	scheduler   *tmux.RefreshScheduler // Preview refresh scheduler whose rate is shown, if any
 	width       int
 	height      int
 }
@@ -38,6 +42,11 @@ func NewDebugOverlay(debugLogger *debug.DebugLogger) *DebugOverlay {
 	}
 }
 
+// SetRefreshScheduler shows the effective rate of the preview's refresh scheduler
+func (d *DebugOverlay) SetRefreshScheduler(scheduler *tmux.RefreshScheduler) {
+	d.scheduler = scheduler
+}
+
 // SetSize updates the overlay dimensions
 func (d *DebugOverlay) SetSize(width, height int) {
 	d.width = width
@@ -108,6 +117,9 @@ func (d *DebugOverlay) View() string {
 		Align(lipgloss.Center)
 
 	titleRow := titleStyle.Render("Debug Log Viewer") + " " + pathStyle.Render("("+debugLogPath+")")
+	if d.scheduler != nil {
+		titleRow += " " + pathStyle.Render(formatRefreshStats(d.scheduler.Stats()))
+	}
 	helpRow := helpStyle.Render("Use ↑/↓ to scroll • o to open in editor • ESC to close")
 
 	header := lipgloss.NewStyle().Align(lipgloss.Center).Render(titleRow) + "\n" + helpRow
@@ -143,6 +155,14 @@ func (d *DebugOverlay) View() string {
 	return overlayStyle.Render(overlayContent)
 }
 
+// formatRefreshStats describes the preview's refresh rate for the title row
+func formatRefreshStats(stats tmux.RefreshStats) string {
+	if stats.Paused {
+		return fmt.Sprintf("• preview %.1f/s, paused", stats.Rate)
+	}
+	return fmt.Sprintf("• preview %.1f/s, every %s", stats.Rate, stats.Interval.Round(time.Millisecond))
+}
+
 // readDebugLogFile reads all lines from the debug.log file
 func (d *DebugOverlay) readDebugLogFile() []string {
 	// Get .agate directory path
@@ -85,7 +85,7 @@ func (h *HelpDialog) View() string {
 	shortcuts := common.AllShortcuts(h.keyMap)
 
 	// Define the order of sections
-	sectionOrder := []string{"Global", "Navigation", "Worktree Management", "Tmux Interaction", "List Controls", "Dialog Actions"}
+	sectionOrder := []string{"Global", "Navigation", "Worktree Management", "Tmux Interaction", "Preview Scrollback", "Preview Search", "List Controls", "Dialog Actions"}
 
 	for _, section := range sectionOrder {
 		if items, ok := shortcuts[section]; ok {
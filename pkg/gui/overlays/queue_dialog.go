@@ -0,0 +1,323 @@
+package overlays
+
+import (
+	"fmt"
+	"strings"
+
+	"agate/pkg/gui/theme"
+	"agate/pkg/session"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/truncate"
+)
+
+// Queue dialog dimensions
+const (
+	queueDialogMaxContentWidth = 90
+	queueTextareaHeight        = 6
+)
+
+// QueueDialog shows the prompts queued for a session's agent and edits them.
+// The session manager sends them one by one as the agent gets back to its prompt.
+type QueueDialog struct {
+	sessionManager *session.Manager
+	session        *session.Session
+	items          []string
+	selected       int
+	width          int
+	height         int
+	err            string
+	help           help.Model
+	keys           queueKeyMap
+
+	// Item being edited; editIndex is len(items) for a new one
+	editing      bool
+	editIndex    int
+	editOriginal string
+	textarea     textarea.Model
+}
+
+// queueKeyMap defines the keybindings for the queue dialog
+type queueKeyMap struct {
+	dialog *QueueDialog
+
+	Move    key.Binding
+	Add     key.Binding
+	Edit    key.Binding
+	Delete  key.Binding
+	Reorder key.Binding
+	Up      key.Binding
+	Down    key.Binding
+	Escape  key.Binding
+	Save    key.Binding
+	Discard key.Binding
+}
+
+// ShortHelp returns keybindings to show in the mini help view
+func (k queueKeyMap) ShortHelp() []key.Binding {
+	if k.dialog.editing {
+		return []key.Binding{k.Save, k.Discard}
+	}
+	return []key.Binding{k.Move, k.Add, k.Edit, k.Delete, k.Reorder, k.Escape}
+}
+
+// FullHelp returns keybindings to show in the full help view
+func (k queueKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{k.ShortHelp()}
+}
+
+// QueueClosedMsg is sent when the queue dialog is closed
+type QueueClosedMsg struct{}
+
+// NewQueueDialog creates a dialog editing the prompt queue of a session
+func NewQueueDialog(sessionManager *session.Manager, sess *session.Session) *QueueDialog {
+	input := textarea.New()
+	input.Placeholder = "Prompt to send once the agent is back at its prompt"
+	input.ShowLineNumbers = false
+	input.Prompt = "┃ "
+	input.SetHeight(queueTextareaHeight)
+
+	d := &QueueDialog{
+		sessionManager: sessionManager,
+		session:        sess,
+		items:          sess.GetQueue(),
+		help:           help.New(),
+		textarea:       input,
+	}
+	d.keys = queueKeyMap{
+		dialog: d,
+		Move: key.NewBinding(
+			key.WithKeys("up", "down", "k", "j"),
+			key.WithHelp("↑/↓", "select"),
+		),
+		Add: key.NewBinding(
+			key.WithKeys("a"),
+			key.WithHelp("a", "add"),
+		),
+		Edit: key.NewBinding(
+			key.WithKeys("enter", "e"),
+			key.WithHelp("↵", "edit"),
+		),
+		Delete: key.NewBinding(
+			key.WithKeys("d"),
+			key.WithHelp("d", "delete"),
+		),
+		Reorder: key.NewBinding(
+			key.WithKeys("K", "J"),
+			key.WithHelp("K/J", "move up/down"),
+		),
+		Up:   key.NewBinding(key.WithKeys("K")),
+		Down: key.NewBinding(key.WithKeys("J")),
+		Escape: key.NewBinding(
+			key.WithKeys("esc"),
+			key.WithHelp("esc", "close"),
+		),
+		Save: key.NewBinding(
+			key.WithKeys("ctrl+s"),
+			key.WithHelp("ctrl+s", "save"),
+		),
+		Discard: key.NewBinding(
+			key.WithKeys("esc"),
+			key.WithHelp("esc", "discard"),
+		),
+	}
+	return d
+}
+
+// SetSize sets the dialog dimensions
+func (d *QueueDialog) SetSize(width, height int) {
+	d.width = width
+	d.height = height
+}
+
+// SessionID returns the ID of the session whose queue is shown
+func (d *QueueDialog) SessionID() string {
+	return d.session.GetID()
+}
+
+// Refresh shows the queue again after the session manager changed it,
+// e.g. because its first prompt was sent
+func (d *QueueDialog) Refresh() {
+	d.items = d.session.GetQueue()
+	d.selected = min(d.selected, max(0, len(d.items)-1))
+}
+
+// Init implements tea.Model
+func (d *QueueDialog) Init() tea.Cmd {
+	return nil
+}
+
+// Update implements tea.Model
+func (d *QueueDialog) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if d.editing {
+		return d, d.updateEditing(msg)
+	}
+
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return d, nil
+	}
+	// Work on the queue as it is now; its first prompt may have just been sent
+	d.Refresh()
+	switch {
+	case key.Matches(keyMsg, d.keys.Escape):
+		return d, func() tea.Msg { return QueueClosedMsg{} }
+	case key.Matches(keyMsg, d.keys.Move):
+		if keyMsg.String() == "up" || keyMsg.String() == "k" {
+			d.selected = max(0, d.selected-1)
+		} else {
+			d.selected = min(max(0, len(d.items)-1), d.selected+1)
+		}
+	case key.Matches(keyMsg, d.keys.Add):
+		return d, d.startEditing(len(d.items))
+	case key.Matches(keyMsg, d.keys.Edit):
+		if d.selected < len(d.items) {
+			return d, d.startEditing(d.selected)
+		}
+	case key.Matches(keyMsg, d.keys.Delete):
+		if d.selected < len(d.items) {
+			items := append([]string{}, d.items[:d.selected]...)
+			d.save(append(items, d.items[d.selected+1:]...))
+		}
+	case key.Matches(keyMsg, d.keys.Up):
+		d.swap(d.selected - 1)
+	case key.Matches(keyMsg, d.keys.Down):
+		d.swap(d.selected + 1)
+	}
+	return d, nil
+}
+
+// updateEditing handles messages while an item is edited
+func (d *QueueDialog) updateEditing(msg tea.Msg) tea.Cmd {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case key.Matches(keyMsg, d.keys.Discard):
+			d.stopEditing()
+			return nil
+		case key.Matches(keyMsg, d.keys.Save):
+			text := d.textarea.Value()
+			if strings.TrimSpace(text) == "" {
+				d.err = "The prompt is empty"
+				return nil
+			}
+			d.Refresh()
+			items := append([]string{}, d.items...)
+			if d.editIndex < len(items) && items[d.editIndex] == d.editOriginal {
+				items[d.editIndex] = text
+			} else {
+				// New, or the edited prompt was sent meanwhile
+				d.editIndex = len(items)
+				items = append(items, text)
+			}
+			d.selected = d.editIndex
+			d.stopEditing()
+			d.save(items)
+			return nil
+		}
+	}
+
+	var cmd tea.Cmd
+	d.textarea, cmd = d.textarea.Update(msg)
+	return cmd
+}
+
+// startEditing opens the editor on the item at index, or on a new item
+func (d *QueueDialog) startEditing(index int) tea.Cmd {
+	d.editing = true
+	d.editIndex = index
+	d.editOriginal = ""
+	d.err = ""
+	d.textarea.Reset()
+	if index < len(d.items) {
+		d.editOriginal = d.items[index]
+		d.textarea.SetValue(d.editOriginal)
+	}
+	return d.textarea.Focus()
+}
+
+// stopEditing closes the editor
+func (d *QueueDialog) stopEditing() {
+	d.editing = false
+	d.err = ""
+	d.textarea.Blur()
+}
+
+// swap moves the selected item to index
+func (d *QueueDialog) swap(index int) {
+	if index < 0 || index >= len(d.items) || d.selected >= len(d.items) {
+		return
+	}
+	items := append([]string{}, d.items...)
+	items[d.selected], items[index] = items[index], items[d.selected]
+	d.selected = index
+	d.save(items)
+}
+
+// save hands the edited queue to the session manager
+func (d *QueueDialog) save(items []string) {
+	if err := d.sessionManager.SetQueue(d.session.GetID(), items); err != nil {
+		d.err = fmt.Sprintf("Failed to save the queue: %v", err)
+	}
+	d.Refresh()
+}
+
+// View renders the queue dialog
+func (d *QueueDialog) View() string {
+	frameWidth := dialogStyle.GetHorizontalFrameSize()
+	contentWidth := queueDialogMaxContentWidth
+	if d.width > 0 {
+		contentWidth = min(contentWidth, d.width-frameWidth-4)
+	}
+	contentWidth = max(contentWidth, 20)
+	d.textarea.SetWidth(contentWidth)
+
+	titleStyle := dialogTitleStyle.Copy().MarginBottom(0)
+	mutedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(theme.TextMuted))
+	dividerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(theme.TextDescription))
+
+	var content []string
+	header := fmt.Sprintf("%s (%s)", sessionTitle(d.session), d.session.GetState())
+	content = append(content, titleStyle.Render("Queue")+mutedStyle.Render(" > ")+
+		truncate.StringWithTail(header, uint(max(contentWidth-8, 1)), "…"))
+	content = append(content, dividerStyle.Render(strings.Repeat("─", contentWidth)), "")
+
+	if len(d.items) == 0 {
+		content = append(content, dialogInfoStyle.Copy().MarginTop(0).Render("Nothing queued; a adds a prompt to send once the agent is idle"))
+	}
+	cursorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(theme.AgateColor))
+	for i, item := range d.items {
+		cursor := "  "
+		if i == d.selected && !d.editing {
+			cursor = cursorStyle.Render("› ")
+		}
+		first, rest, multiline := strings.Cut(strings.TrimSpace(item), "\n")
+		line := fmt.Sprintf("%d. %s", i+1, first)
+		suffix := ""
+		if multiline {
+			suffix = mutedStyle.Render(fmt.Sprintf(" (+%d lines)", strings.Count(rest, "\n")+1))
+		}
+		line = truncate.StringWithTail(line, uint(max(contentWidth-2-lipgloss.Width(suffix), 1)), "…")
+		content = append(content, cursor+line+suffix)
+	}
+
+	if d.editing {
+		label := "New prompt"
+		if d.editIndex < len(d.items) {
+			label = fmt.Sprintf("Prompt %d", d.editIndex+1)
+		}
+		content = append(content, "", titleStyle.Render(label), d.textarea.View())
+	}
+
+	if d.err != "" {
+		content = append(content, dialogErrorStyle.Render(truncate.StringWithTail(d.err, uint(contentWidth), "…")))
+	}
+
+	content = append(content, "", d.help.View(d.keys))
+
+	dialog := dialogStyle.Render(strings.Join(content, "\n"))
+	return lipgloss.Place(d.width, d.height, lipgloss.Center, lipgloss.Center, dialog)
+}
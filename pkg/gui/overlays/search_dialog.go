@@ -0,0 +1,371 @@
+package overlays
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"agate/pkg/gui/theme"
+	"agate/pkg/search"
+	"agate/pkg/session"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/muesli/reflow/truncate"
+)
+
+// searchDialogMaxContentWidth caps how wide the search dialog grows
+const searchDialogMaxContentWidth = 100
+
+// SearchDialog asks for a regular expression to search the focused preview
+// pane's history with, or greps the scrollback of every session and lists
+// the matches by session
+type SearchDialog struct {
+	input          textinput.Model
+	sessionManager *session.Manager
+	paneName       string // Pane searched on its own, empty when only all sessions can be
+	allSessions    bool
+	err            string
+	searching      bool
+	grepped        string // Pattern the results were found for
+	results        []search.Result
+	rows           []searchRow
+	selected       int // Index of the selected match in rows
+	seq            int // Numbers greps so stale results are dropped
+	width          int
+	height         int
+	help           help.Model
+	keys           searchKeyMap
+}
+
+// searchRow is a row of the result list: a session's header or one of its matches
+type searchRow struct {
+	result int // Index into results
+	line   int // Index into the result's lines, -1 for the header
+}
+
+// searchKeyMap defines the keybindings for the search dialog
+type searchKeyMap struct {
+	Scope  key.Binding
+	Select key.Binding
+	Search key.Binding
+	Escape key.Binding
+}
+
+// ShortHelp returns keybindings to show in the mini help view
+func (k searchKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Search, k.Scope, k.Select, k.Escape}
+}
+
+// FullHelp returns keybindings to show in the full help view
+func (k searchKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Search, k.Scope, k.Select, k.Escape},
+	}
+}
+
+// SearchSubmittedMsg asks the focused preview pane to search its session's history
+type SearchSubmittedMsg struct {
+	Pattern *regexp.Regexp
+}
+
+// SearchResultSelectedMsg asks to show a match found in a session's scrollback
+type SearchResultSelectedMsg struct {
+	SessionID string
+	Shell     bool // The match is in the session's shell rather than its agent
+	Pattern   *regexp.Regexp
+	Line      int // Index of the line in the captured history, oldest first
+}
+
+// SearchResultsMsg carries the matches found across sessions
+type SearchResultsMsg struct {
+	Results []search.Result
+	seq     int
+}
+
+// SearchCancelledMsg is sent when the search dialog is closed
+type SearchCancelledMsg struct{}
+
+// NewSearchDialog creates a search dialog. paneName names the preview pane
+// the search starts in; when empty, the dialog only searches all sessions.
+func NewSearchDialog(sessionManager *session.Manager, paneName string) *SearchDialog {
+	input := textinput.New()
+	input.Placeholder = "regular expression, case-insensitive unless it has capitals"
+	input.PlaceholderStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(theme.TextDescription))
+	input.Focus()
+	input.CharLimit = 200
+	input.Prompt = "/ "
+
+	h := help.New()
+	h.ShowAll = false
+
+	keys := searchKeyMap{
+		Scope: key.NewBinding(
+			key.WithKeys("tab"),
+			key.WithHelp("tab", "switch scope"),
+		),
+		Select: key.NewBinding(
+			key.WithKeys("up", "down"),
+			key.WithHelp("↑/↓", "select match"),
+		),
+		Search: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("↵", "search"),
+		),
+		Escape: key.NewBinding(
+			key.WithKeys("esc"),
+			key.WithHelp("esc", "close"),
+		),
+	}
+	keys.Scope.SetEnabled(paneName != "")
+
+	return &SearchDialog{
+		input:          input,
+		sessionManager: sessionManager,
+		paneName:       paneName,
+		allSessions:    paneName == "",
+		help:           h,
+		keys:           keys,
+	}
+}
+
+// SetSize sets the dialog dimensions
+func (d *SearchDialog) SetSize(width, height int) {
+	d.width = width
+	d.height = height
+}
+
+// Init implements tea.Model
+func (d *SearchDialog) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+// Update implements tea.Model
+func (d *SearchDialog) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case SearchResultsMsg:
+		if msg.seq == d.seq {
+			d.searching = false
+			d.setResults(msg.Results)
+		}
+		return d, nil
+
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, d.keys.Escape):
+			return d, func() tea.Msg { return SearchCancelledMsg{} }
+
+		case key.Matches(msg, d.keys.Scope):
+			d.allSessions = !d.allSessions
+			d.err = ""
+			return d, nil
+
+		case key.Matches(msg, d.keys.Select):
+			if msg.String() == "up" {
+				d.moveSelection(-1)
+			} else {
+				d.moveSelection(1)
+			}
+			return d, nil
+
+		case key.Matches(msg, d.keys.Search):
+			return d, d.submit()
+		}
+	}
+
+	var cmd tea.Cmd
+	previous := d.input.Value()
+	d.input, cmd = d.input.Update(msg)
+	if d.input.Value() != previous {
+		d.err = ""
+	}
+	return d, cmd
+}
+
+// submit searches for the entered pattern, or opens the selected match when
+// the results are for it already
+func (d *SearchDialog) submit() tea.Cmd {
+	pattern := d.input.Value()
+	re, err := search.Compile(pattern)
+	if err != nil {
+		d.err = err.Error()
+		return nil
+	}
+
+	if !d.allSessions {
+		return func() tea.Msg { return SearchSubmittedMsg{Pattern: re} }
+	}
+
+	if pattern == d.grepped && d.selected < len(d.rows) && !d.searching {
+		row := d.rows[d.selected]
+		result := d.results[row.result]
+		return func() tea.Msg {
+			return SearchResultSelectedMsg{
+				SessionID: result.SessionID,
+				Shell:     result.Shell,
+				Pattern:   re,
+				Line:      result.Lines[row.line].Number,
+			}
+		}
+	}
+
+	d.seq++
+	d.searching = true
+	d.grepped = pattern
+	seq, sources := d.seq, d.sources()
+	return func() tea.Msg {
+		return SearchResultsMsg{Results: search.Grep(re, sources), seq: seq}
+	}
+}
+
+// sources lists the agent and shell of every session
+func (d *SearchDialog) sources() []search.Source {
+	if d.sessionManager == nil {
+		return nil
+	}
+
+	var sources []search.Source
+	for _, sess := range d.sessionManager.ListSessions() {
+		title := sessionTitle(sess)
+		sources = append(sources,
+			search.Source{SessionID: sess.GetID(), Title: title, Backend: sess.GetTmuxSession()},
+			search.Source{SessionID: sess.GetID(), Title: title + " (shell)", Shell: true, Backend: sess.ShellTmuxSession},
+		)
+	}
+	return sources
+}
+
+// setResults lists grep results and selects the first match
+func (d *SearchDialog) setResults(results []search.Result) {
+	d.results = results
+	d.rows = nil
+	d.selected = 0
+	for i, result := range results {
+		d.rows = append(d.rows, searchRow{result: i, line: -1})
+		for j := range result.Lines {
+			d.rows = append(d.rows, searchRow{result: i, line: j})
+		}
+	}
+	d.moveSelection(1)
+}
+
+// moveSelection selects the match delta rows away, skipping session headers
+func (d *SearchDialog) moveSelection(delta int) {
+	for i := d.selected + delta; i >= 0 && i < len(d.rows); i += delta {
+		if d.rows[i].line >= 0 {
+			d.selected = i
+			return
+		}
+	}
+}
+
+// View renders the search dialog
+func (d *SearchDialog) View() string {
+	frameWidth := dialogStyle.GetHorizontalFrameSize()
+	contentWidth := searchDialogMaxContentWidth
+	if d.width > 0 {
+		contentWidth = min(contentWidth, d.width-frameWidth-4)
+	}
+	contentWidth = max(contentWidth, 20)
+	d.input.Width = contentWidth - lipgloss.Width(d.input.Prompt) - 1
+
+	var content []string
+
+	// Header: what is searched, the other scope dimmed
+	titleStyle := dialogTitleStyle.Copy().MarginBottom(0)
+	otherStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(theme.TextMuted))
+	scopes := []string{}
+	if d.paneName != "" {
+		if d.allSessions {
+			scopes = append(scopes, otherStyle.Render(d.paneName))
+		} else {
+			scopes = append(scopes, titleStyle.Render(d.paneName))
+		}
+	}
+	if d.allSessions {
+		scopes = append(scopes, titleStyle.Render("All sessions"))
+	} else {
+		scopes = append(scopes, otherStyle.Render("All sessions"))
+	}
+	content = append(content, titleStyle.Render("Search")+otherStyle.Render(" > ")+strings.Join(scopes, otherStyle.Render(" │ ")))
+
+	dividerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(theme.TextDescription))
+	content = append(content, dividerStyle.Render(strings.Repeat("─", contentWidth)), "")
+	content = append(content, d.input.View())
+
+	if d.err != "" {
+		content = append(content, dialogErrorStyle.Render(truncate.StringWithTail(d.err, uint(contentWidth), "…")))
+	}
+
+	if d.allSessions {
+		content = append(content, "")
+		content = append(content, d.resultLines(contentWidth)...)
+	}
+
+	content = append(content, "", d.help.View(d.keys))
+
+	dialog := dialogStyle.Render(strings.Join(content, "\n"))
+	return lipgloss.Place(d.width, d.height, lipgloss.Center, lipgloss.Center, dialog)
+}
+
+// resultLines renders the status of a grep and the matches it found
+func (d *SearchDialog) resultLines(width int) []string {
+	switch {
+	case d.searching:
+		return []string{dialogInfoStyle.Copy().MarginTop(0).Render("Searching every session's scrollback...")}
+	case d.grepped == "":
+		return []string{dialogInfoStyle.Copy().MarginTop(0).Render("↵ lists the matches in every agent and shell")}
+	case len(d.results) == 0:
+		return []string{dialogInfoStyle.Copy().MarginTop(0).Render("No matches")}
+	}
+
+	total := 0
+	for _, result := range d.results {
+		total += result.Total
+	}
+	lines := []string{dialogInfoStyle.Copy().MarginTop(0).Render(
+		fmt.Sprintf("%d matching lines in %d terminals • ↵ opens the selected one", total, len(d.results)))}
+
+	// Keep the dialog within the screen, scrolling the list with the selection
+	visible := len(d.rows)
+	if d.height > 0 {
+		visible = min(visible, max(3, d.height-16))
+	}
+	first := min(max(0, d.selected-visible/2), len(d.rows)-visible)
+
+	re, _ := search.Compile(d.grepped)
+	headerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(theme.InfoStatus)).Bold(true)
+	numberStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(theme.TextMuted))
+	for i := first; i < first+visible; i++ {
+		row := d.rows[i]
+		result := d.results[row.result]
+		if row.line < 0 {
+			header := fmt.Sprintf("%s  %d", result.Title, result.Total)
+			if result.Total > len(result.Lines) {
+				header += fmt.Sprintf(" (newest %d)", len(result.Lines))
+			}
+			lines = append(lines, headerStyle.Render(truncate.String(header, uint(width))))
+			continue
+		}
+
+		line := result.Lines[row.line]
+		marker, current := "  ", -1
+		if i == d.selected {
+			marker = lipgloss.NewStyle().Foreground(lipgloss.Color(theme.AgateColor)).Render("› ")
+			current = line.Start
+		}
+		number := numberStyle.Render(fmt.Sprintf("%6d ", line.Number+1))
+		textWidth := width - lipgloss.Width(marker) - lipgloss.Width(number)
+		text := search.Highlight(line.Text, re, current)
+		// Bring the match into view on long lines
+		if start := lipgloss.Width(line.Text[:line.Start]); start > textWidth/2 {
+			text = ansi.TruncateLeft(text, start-textWidth/4, "…")
+		}
+		lines = append(lines, marker+number+truncate.String(text, uint(max(textWidth, 0)))+"\x1b[0m")
+	}
+	return lines
+}
@@ -87,7 +87,7 @@ func (d *SessionDeleteConfirmDialog) deleteSession() tea.Cmd {
 		}
 
 		// Delete the session using the session manager
-		err := d.sessionManager.DeleteSession(d.session.WorktreeKey)
+		err := d.sessionManager.DeleteSession(d.session.GetID(), true)
 		if err != nil {
 			return SessionDeletionErrorMsg{
 				Session: d.session,
@@ -128,7 +128,7 @@ func (d *SessionDeleteConfirmDialog) View() string {
 		Margin(0, 1)
 
 	// Generate session name for display
-	sessionName := d.session.Name
+	sessionName := d.session.GetName()
 	if sessionName == "" && d.session.Worktree != nil {
 		sessionName = fmt.Sprintf("%s:%s", d.session.Worktree.RepoName, d.session.Worktree.Branch)
 	}
@@ -142,20 +142,25 @@ func (d *SessionDeleteConfirmDialog) View() string {
 
 	// Session details
 	content.WriteString(fmt.Sprintf("Session: %s\n", sessionName))
-	if d.session.Agent.Name != "" {
-		content.WriteString(fmt.Sprintf("Agent: %s\n", d.session.Agent.Name))
+	if d.session.GetAgent().Name != "" {
+		content.WriteString(fmt.Sprintf("Agent: %s\n", d.session.GetAgent().Name))
 	}
 	if d.session.Worktree != nil {
 		content.WriteString(fmt.Sprintf("Worktree: %s\n", d.session.Worktree.Path))
-		if d.session.TmuxSession != nil {
-			content.WriteString(fmt.Sprintf("Tmux Session: %s\n", d.session.TmuxSession.GetSessionName()))
+		if d.session.GetTmuxSession() != nil {
+			content.WriteString(fmt.Sprintf("Tmux Session: %s\n", d.session.GetTmuxSession().GetSessionName()))
 		}
 	}
 
 	content.WriteString("\n")
 
 	// Warning message
-	content.WriteString(warningStyle.Render("This will delete both the git worktree and terminate the tmux session."))
+	if d.sessionManager != nil && len(d.sessionManager.GetSessionsForWorktree(d.session.Worktree)) > 1 {
+		// Other agents keep using the worktree, so only this agent goes away
+		content.WriteString(warningStyle.Render("This will terminate the agent's tmux session. The worktree and its other agents are kept."))
+	} else {
+		content.WriteString(warningStyle.Render("This will delete both the git worktree and terminate the tmux session."))
+	}
 	content.WriteString("\n")
 	content.WriteString(infoStyle.Render("All unsaved work will be lost."))
 	content.WriteString("\n\n")
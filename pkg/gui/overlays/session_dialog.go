@@ -35,23 +35,26 @@ type SessionDialog struct {
 	loader          *components.LaunchAgentLoader
 	help            help.Model
 	keys            sessionKeyMap
+	targetWorktree  *git.WorktreeInfo // Existing worktree to add an agent to, nil when creating one
+	restartSession  string            // Session whose agent is replaced, empty unless restarting
 }
 
 // sessionKeyMap defines the keybindings for the session dialog
 type sessionKeyMap struct {
-	Tab    key.Binding
-	Escape key.Binding
+	Tab      key.Binding
+	Complete key.Binding
+	Escape   key.Binding
 }
 
 // ShortHelp returns keybindings to show in the mini help view
 func (k sessionKeyMap) ShortHelp() []key.Binding {
-	return []key.Binding{k.Tab, k.Escape}
+	return []key.Binding{k.Tab, k.Complete, k.Escape}
 }
 
 // FullHelp returns keybindings to show in the full help view
 func (k sessionKeyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
-		{k.Tab, k.Escape},
+		{k.Tab, k.Complete, k.Escape},
 	}
 }
 
@@ -111,6 +114,11 @@ func NewSessionDialog(worktreeManager *git.WorktreeManager, defaultAgent string)
 	agentInput.Width = 40
 	agentInput.Prompt = ""
 
+	// Suggest every registered agent, including user-defined ones
+	agentInput.ShowSuggestions = true
+	agentInput.SetSuggestions(app.GetAgentNames())
+	agentInput.KeyMap.AcceptSuggestion = key.NewBinding(key.WithKeys("right"))
+
 	// Set default value
 	if defaultAgent != "" {
 		agentInput.SetValue(defaultAgent)
@@ -139,6 +147,10 @@ func NewSessionDialog(worktreeManager *git.WorktreeManager, defaultAgent string)
 			key.WithKeys("tab"),
 			key.WithHelp("tab", "navigate fields"),
 		),
+		Complete: key.NewBinding(
+			key.WithKeys("right"),
+			key.WithHelp("→", "complete agent"),
+		),
 		Escape: key.NewBinding(
 			key.WithKeys("esc"),
 			key.WithHelp("esc", "cancel"),
@@ -162,6 +174,33 @@ func NewSessionDialog(worktreeManager *git.WorktreeManager, defaultAgent string)
 	}
 }
 
+// NewAddAgentDialog creates a dialog that starts another agent session in an
+// existing worktree
+func NewAddAgentDialog(worktreeManager *git.WorktreeManager, worktree *git.WorktreeInfo, defaultAgent string) *SessionDialog {
+	d := NewSessionDialog(worktreeManager, defaultAgent)
+	d.targetWorktree = worktree
+	if worktree != nil && worktree.RepoName != "" {
+		d.repoName = worktree.RepoName
+	}
+	d.focusedField = 1
+	d.updateFocus()
+	d.keys.Tab.SetEnabled(false)
+	return d
+}
+
+// NewRestartAgentDialog creates a dialog that restarts an existing session
+// with a different agent, keeping its worktree and shell
+func NewRestartAgentDialog(worktreeManager *git.WorktreeManager, worktree *git.WorktreeInfo, sessionID, currentAgent string) *SessionDialog {
+	d := NewAddAgentDialog(worktreeManager, worktree, currentAgent)
+	d.restartSession = sessionID
+	return d
+}
+
+// isAddingAgent reports whether the dialog adds an agent to an existing worktree
+func (d *SessionDialog) isAddingAgent() bool {
+	return d.targetWorktree != nil
+}
+
 // Init implements tea.Model
 func (d *SessionDialog) Init() tea.Cmd {
 	return textinput.Blink
@@ -182,17 +221,27 @@ func (d *SessionDialog) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "enter":
 			// Only create if both fields are valid
 			if d.isValid() {
+				if d.isAddingAgent() {
+					return d, d.addAgentToWorktree()
+				}
 				return d, d.createAndAttachWorktree()
 			}
 			return d, nil
 
 		case "tab":
+			// The branch field is hidden when adding an agent
+			if d.isAddingAgent() {
+				return d, nil
+			}
 			// Switch to next field
 			d.focusedField = (d.focusedField + 1) % 2
 			d.updateFocus()
 			return d, nil
 
 		case "shift+tab":
+			if d.isAddingAgent() {
+				return d, nil
+			}
 			// Switch to previous field
 			d.focusedField--
 			if d.focusedField < 0 {
@@ -321,6 +370,32 @@ func (d *SessionDialog) createAndAttachWorktree() tea.Cmd {
 	return tea.Batch(cmds...)
 }
 
+// addAgentToWorktree starts another agent in the target worktree and attaches to it
+func (d *SessionDialog) addAgentToWorktree() tea.Cmd {
+	d.creating = true
+	d.err = ""
+
+	worktree := d.targetWorktree
+	agentName := d.agentInput.Value()
+	return tea.Batch(
+		func() tea.Msg {
+			if d.restartSession != "" {
+				return WorktreeCreatedMsg{
+					Worktree:         worktree,
+					AgentName:        agentName,
+					RestartSessionID: d.restartSession,
+				}
+			}
+			return WorktreeCreatedMsg{
+				Worktree:  worktree,
+				AgentName: agentName,
+				AddAgent:  true,
+			}
+		},
+		d.loader.TickCmd(),
+	)
+}
+
 // SetSize updates the dialog dimensions
 func (d *SessionDialog) SetSize(width, height int) {
 	d.width = width
@@ -347,6 +422,17 @@ func (d *SessionDialog) View() string {
 	repoText := repoStyle.Render(d.repoName)
 	arrowText := titleStyle.Render(" > ")
 	sessionText := titleStyle.Render("New agent")
+	if d.isAddingAgent() {
+		branch := d.targetWorktree.Branch
+		if strings.TrimSpace(branch) == "" {
+			branch = d.targetWorktree.Name
+		}
+		repoText = repoStyle.Render(d.repoName + " > " + branch)
+		sessionText = titleStyle.Render("Add agent")
+		if d.restartSession != "" {
+			sessionText = titleStyle.Render("Restart with agent")
+		}
+	}
 
 	headerLine := lipgloss.JoinHorizontal(lipgloss.Left, repoText, arrowText, sessionText)
 	appendLine(headerLine)
@@ -375,10 +461,12 @@ func (d *SessionDialog) View() string {
 			Foreground(lipgloss.Color("#FFFFFF")).
 			Bold(true)
 
-		// Branch name field
-		appendLine(labelStyle.Render("Branch name"))
-		appendLine(d.branchInput.View())
-		content = append(content, "")
+		// Branch name field, unless the worktree already exists
+		if !d.isAddingAgent() {
+			appendLine(labelStyle.Render("Branch name"))
+			appendLine(d.branchInput.View())
+			content = append(content, "")
+		}
 
 		// Agent command field
 		appendLine(labelStyle.Render("Agent command"))
@@ -399,7 +487,7 @@ func (d *SessionDialog) View() string {
 		content = append(content, "HELP_PLACEHOLDER")
 
 		// Warning for non-COW systems
-		if !d.systemCaps.SupportsCOW {
+		if !d.systemCaps.SupportsCOW && !d.isAddingAgent() {
 			content = append(content, "")
 			appendLine(dialogWarningStyle.Render("⚠️  Only version controlled files"))
 			appendLine(dialogWarningStyle.Render("   will be copied, which excludes"))
@@ -512,8 +600,10 @@ func (d *SessionDialog) View() string {
 
 // WorktreeCreatedMsg indicates a worktree was successfully created
 type WorktreeCreatedMsg struct {
-	Worktree  *git.WorktreeInfo
-	AgentName string // The agent command selected by the user
+	Worktree         *git.WorktreeInfo
+	AgentName        string // The agent command selected by the user
+	AddAgent         bool   // The worktree already existed and gets an additional agent session
+	RestartSessionID string // Session whose agent is replaced by AgentName, if any
 }
 
 // WorktreeCreationErrorMsg indicates worktree creation failed
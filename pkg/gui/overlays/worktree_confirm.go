@@ -6,6 +6,7 @@ import (
 
 	"agate/pkg/git"
 	"agate/pkg/gui/theme"
+	"agate/pkg/session"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -13,11 +14,11 @@ import (
 
 // WorktreeConfirmDialog represents the confirmation dialog for deleting worktrees
 type WorktreeConfirmDialog struct {
-	worktree        *git.WorktreeInfo
-	worktreeManager *git.WorktreeManager
-	width           int
-	height          int
-	deleting        bool
+	worktree       *git.WorktreeInfo
+	sessionManager *session.Manager
+	width          int
+	height         int
+	deleting       bool
 }
 
 // Styling for confirmation dialog
@@ -52,11 +53,11 @@ var (
 )
 
 // NewWorktreeConfirmDialog creates a new agent deletion confirmation dialog
-func NewWorktreeConfirmDialog(worktree *git.WorktreeInfo, worktreeManager *git.WorktreeManager) *WorktreeConfirmDialog {
+func NewWorktreeConfirmDialog(worktree *git.WorktreeInfo, sessionManager *session.Manager) *WorktreeConfirmDialog {
 	return &WorktreeConfirmDialog{
-		worktree:        worktree,
-		worktreeManager: worktreeManager,
-		deleting:        false,
+		worktree:       worktree,
+		sessionManager: sessionManager,
+		deleting:       false,
 	}
 }
 
@@ -106,7 +107,7 @@ func (d *WorktreeConfirmDialog) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 // deleteWorktree deletes the worktree
 func (d *WorktreeConfirmDialog) deleteWorktree() tea.Cmd {
-	if d.worktreeManager == nil || d.worktree == nil {
+	if d.sessionManager == nil || d.worktree == nil {
 		return func() tea.Msg {
 			return WorktreeDeletionErrorMsg{Error: "Worktree manager or worktree not available"}
 		}
@@ -117,7 +118,7 @@ func (d *WorktreeConfirmDialog) deleteWorktree() tea.Cmd {
 
 	// Delete worktree in background
 	return func() tea.Msg {
-		err := d.worktreeManager.DeleteWorktree(*d.worktree)
+		err := d.sessionManager.DeleteWorktree(d.worktree)
 		if err != nil {
 			return WorktreeDeletionErrorMsg{Error: err.Error()}
 		}
@@ -1,6 +1,12 @@
 package panes
 
 import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+
 	"agate/pkg/app"
 	"agate/pkg/common"
 	"agate/pkg/gui/components"
@@ -11,14 +17,40 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 )
 
+// wheelScrollLines is how far one mouse wheel step scrolls the preview
+const wheelScrollLines = 3
+
 // AgentTmuxPane manages the display of tmux terminal content
 type AgentTmuxPane struct {
 	*components.BasePane
-	session      *tmux.TmuxSession
+	session      tmux.Backend
 	content      string
 	loadingState *tmux.LoadingState
 	isLoading    bool
 	mode         string // "preview" or "attached"
+
+	// Read-only scrollback, captured separately from the live screen
+	followTail   bool   // Show the live screen as output arrives
+	scrollOffset int    // Lines scrolled back from the live screen while not following
+	historySize  int    // Scrollback lines as of the last capture, -1 when unknown
+	scrollback   string // Captured lines shown while not following
+	scrollSeq    int    // Numbers scrollback captures so stale ones are dropped
+
+	search previewSearch // Search through a snapshot of the history
+
+	insertMode bool        // Keys go to the agent while the rest of the UI stays live
+	heldKeys   []tmux.Key  // Keys typed in insert mode that may start the detach key
+	typing     typingQueue // Keys on their way to the agent
+}
+
+// ScrollbackMsg carries scrollback captured for the preview
+type ScrollbackMsg struct {
+	SessionName string
+	Content     string
+	HistorySize int
+	Offset      int
+	Err         error
+	seq         int
 }
 
 // NewAgentTmuxPane creates a new AgentTmuxPane instance
@@ -30,11 +62,19 @@ func NewAgentTmuxPane(loadingState *tmux.LoadingState) *AgentTmuxPane {
 		loadingState: loadingState,
 		isLoading:    false,
 		mode:         "preview", // Start in preview mode
+		followTail:   true,
+		historySize:  -1,
 	}
 }
 
 // SetSession sets the tmux session for this pane
-func (t *AgentTmuxPane) SetSession(session *tmux.TmuxSession) {
+func (t *AgentTmuxPane) SetSession(session tmux.Backend) {
+	if t.session != session {
+		t.ScrollToBottom()
+		t.search.stop()
+		t.insertMode = false
+		t.heldKeys = nil
+	}
 	t.session = session
 }
 
@@ -58,9 +98,23 @@ func (t *AgentTmuxPane) GetTitleStyle() components.TitleStyle {
 	shortcuts := ""
 	isActive := t.IsActive()
 
-	if isActive {
+	if t.insertMode {
+		shortcuts = "INSERT • " + common.GlobalKeys.DetachTmux.Help().Key + " exit"
+	} else if t.search.active() {
+		shortcuts = t.search.status()
+	} else if !t.followTail {
+		// Show where in the scrollback the preview is
+		switch {
+		case t.scrollOffset == 0:
+			shortcuts = "paused • f follow"
+		case t.historySize >= 0:
+			shortcuts = fmt.Sprintf("scrollback -%d/%d • f follow", t.scrollOffset, t.historySize)
+		default:
+			shortcuts = fmt.Sprintf("scrollback -%d • f follow", t.scrollOffset)
+		}
+	} else if isActive {
 		// When active, format shortcuts like the footer (without brackets)
-		shortcuts = "↵ attach • ctrl+q detach"
+		shortcuts = "↵ attach • " + common.GlobalKeys.DetachTmux.Help().Key + " detach"
 	} else {
 		// When not active, show pane number
 		shortcuts = "(1)"
@@ -88,10 +142,222 @@ func (t *AgentTmuxPane) View() string {
 		)
 	}
 
+	if t.search.active() {
+		return t.search.view(t.GetWidth(), t.GetHeight(), app.GetCurrentAgentColor())
+	}
+
+	if !t.followTail && t.scrollback != "" {
+		return t.renderScrollback()
+	}
+
 	// Show tmux content
 	return t.content
 }
 
+// renderScrollback renders the captured scrollback with a scroll bar in the last column
+func (t *AgentTmuxPane) renderScrollback() string {
+	lines := strings.Split(strings.TrimSuffix(t.scrollback, "\n"), "\n")
+	height := t.GetHeight()
+	return renderRows(lines, t.GetWidth(), height, scrollBar(height, t.historySize, t.scrollOffset), app.GetCurrentAgentColor())
+}
+
+// scrollBar returns which rows of a scroll bar the thumb covers, for a view
+// of height rows scrolled offset lines back into history lines of scrollback
+func scrollBar(height, history, offset int) []bool {
+	bar := make([]bool, height)
+	if history <= 0 {
+		for i := range bar {
+			bar[i] = true
+		}
+		return bar
+	}
+
+	total := history + height
+	size := max(1, height*height/total)
+	top := (history - offset) * (height - size) / history
+	for i := top; i < top+size && i < height; i++ {
+		bar[i] = true
+	}
+	return bar
+}
+
+// FollowingTail reports whether the preview shows the live screen
+func (t *AgentTmuxPane) FollowingTail() bool {
+	return t.followTail
+}
+
+// ScrollBy scrolls the preview back by lines, or forward when negative.
+// Scrolling back stops following the live screen.
+func (t *AgentTmuxPane) ScrollBy(lines int) {
+	if t.followTail {
+		if lines <= 0 {
+			return
+		}
+		t.followTail = false
+		t.scrollOffset = 0
+	}
+	t.scrollOffset = max(0, t.scrollOffset+lines)
+	if t.historySize >= 0 {
+		t.scrollOffset = min(t.scrollOffset, t.historySize)
+	}
+}
+
+// ScrollToTop shows the oldest scrollback
+func (t *AgentTmuxPane) ScrollToTop() {
+	t.ScrollBy(math.MaxInt32)
+}
+
+// ScrollToBottom follows the live screen again
+func (t *AgentTmuxPane) ScrollToBottom() {
+	t.followTail = true
+	t.scrollOffset = 0
+	t.historySize = -1
+	t.scrollback = ""
+}
+
+// ToggleFollowTail switches between following the live screen and holding
+// the current view still while output arrives
+func (t *AgentTmuxPane) ToggleFollowTail() {
+	if t.followTail {
+		t.followTail = false
+		t.scrollOffset = 0
+		return
+	}
+	t.ScrollToBottom()
+}
+
+// CaptureScrollback captures the lines the preview shows while it isn't
+// following the live screen. Lines that arrived since the last capture push
+// the view further back, so it holds still.
+func (t *AgentTmuxPane) CaptureScrollback() tea.Cmd {
+	if t.session == nil || t.followTail {
+		return nil
+	}
+
+	t.scrollSeq++
+	session := t.session
+	seq, offset, knownHistory, height := t.scrollSeq, t.scrollOffset, t.historySize, max(t.GetHeight(), 1)
+	return func() tea.Msg {
+		msg := ScrollbackMsg{SessionName: session.GetSessionName(), seq: seq}
+		history, err := session.HistorySize()
+		if err != nil {
+			msg.Err = err
+			return msg
+		}
+		if knownHistory >= 0 {
+			offset += history - knownHistory
+		}
+		offset = min(max(offset, 0), history)
+
+		content, err := session.CapturePaneContentWithOptions(-offset, height-1-offset)
+		if err != nil {
+			msg.Err = err
+			return msg
+		}
+		msg.Content = content
+		msg.HistorySize = history
+		msg.Offset = offset
+		return msg
+	}
+}
+
+// SetScrollback shows captured scrollback, unless a newer capture was requested since
+func (t *AgentTmuxPane) SetScrollback(msg ScrollbackMsg) {
+	if msg.seq != t.scrollSeq || t.followTail || t.session == nil || msg.SessionName != t.session.GetSessionName() {
+		return
+	}
+	if msg.Err != nil {
+		return
+	}
+	t.scrollback = msg.Content
+	t.historySize = msg.HistorySize
+	t.scrollOffset = msg.Offset
+}
+
+// StartSearch captures the agent's history and shows the matches of re,
+// starting at the one nearest to line, or the newest when line is negative
+func (t *AgentTmuxPane) StartSearch(re *regexp.Regexp, line int) tea.Cmd {
+	return t.search.start(t.session, re, line)
+}
+
+// SetSearchResult shows the history captured for a search
+func (t *AgentTmuxPane) SetSearchResult(msg SearchMsg) {
+	t.search.set(msg, t.session, t.GetHeight())
+}
+
+// Searching reports whether the preview shows a search instead of the live screen
+func (t *AgentTmuxPane) Searching() bool {
+	return t.search.active()
+}
+
+// InsertMode reports whether keys typed in agate go to the agent
+func (t *AgentTmuxPane) InsertMode() bool {
+	return t.insertMode
+}
+
+// SetInsertMode starts or stops sending keys to the agent. The preview
+// follows the live screen while they are, so what is typed can be seen.
+func (t *AgentTmuxPane) SetInsertMode(insert bool) {
+	if insert && t.session == nil {
+		return
+	}
+	t.insertMode = insert
+	t.heldKeys = nil
+	if insert {
+		t.search.stop()
+		t.ScrollToBottom()
+	}
+}
+
+// TypeKey types a key from insert mode into the agent, and leaves insert mode
+// once the detach key has been typed. Keys that may start the detach key are
+// held back until it is clear whether they do. The returned command sends
+// the keys; failures arrive as a TypeErrorMsg.
+func (t *AgentTmuxPane) TypeKey(msg tea.KeyMsg) tea.Cmd {
+	if t.session == nil {
+		t.SetInsertMode(false)
+		return func() tea.Msg {
+			return TypeErrorMsg{Err: fmt.Errorf("no agent session to type into")}
+		}
+	}
+
+	key, ok := keyForMsg(msg)
+	if ok && !msg.Paste {
+		complete, prefix := tmux.CurrentDetachKey().Matches(append(t.heldKeys, key))
+		switch {
+		case complete:
+			t.SetInsertMode(false)
+			return nil
+		case prefix:
+			t.heldKeys = append(t.heldKeys, key)
+			return nil
+		}
+	}
+
+	session := t.session
+	held := t.heldKeys
+	t.heldKeys = nil
+	sends := make([]func() error, 0, len(held))
+	for _, key := range held {
+		sends = append(sends, func() error { return session.SendKey(key) })
+	}
+	flush := t.typing.push(sends...)
+	if len(held) > 0 && !msg.Paste {
+		// This key may start the detach key afresh
+		return tea.Batch(flush, t.TypeKey(msg))
+	}
+
+	// Pasted text is delivered as one paste
+	switch {
+	case msg.Paste:
+		text := string(msg.Runes)
+		return tea.Batch(flush, t.typing.push(func() error { return session.PasteText(text) }))
+	case ok:
+		return tea.Batch(flush, t.typing.push(func() error { return session.SendKey(key) }))
+	}
+	return flush
+}
+
 // Update handles tea.Msg updates for the tmux pane
 func (t *AgentTmuxPane) Update(msg tea.Msg) (components.Pane, tea.Cmd) {
 	// Handle spinner tick messages for loading state
@@ -105,11 +371,56 @@ func (t *AgentTmuxPane) Update(msg tea.Msg) (components.Pane, tea.Cmd) {
 	return t, nil
 }
 
-// HandleKey processes keyboard input when the pane is active
-func (t *AgentTmuxPane) HandleKey(key string) (handled bool, cmd tea.Cmd) {
-	// AgentTmuxPane key handling is managed at the main model level
-	// (attach/detach logic, scrolling, etc.)
-	return false, nil
+// HandleKey scrolls the preview through the agent's scrollback. Attaching
+// and detaching are handled at the main model level.
+func (t *AgentTmuxPane) HandleKey(keyName string) (handled bool, cmd tea.Cmd) {
+	if t.search.active() {
+		return t.search.handleKey(keyName, t.GetHeight()), nil
+	}
+
+	keys := common.GlobalKeys
+	switch {
+	case matchesKey(keys.Up, keyName):
+		t.ScrollBy(1)
+	case matchesKey(keys.Down, keyName):
+		t.ScrollBy(-1)
+	case matchesKey(keys.PreviewPageUp, keyName):
+		t.ScrollBy(max(1, t.GetHeight()-1))
+	case matchesKey(keys.PreviewPageDown, keyName):
+		t.ScrollBy(-max(1, t.GetHeight()-1))
+	case matchesKey(keys.PreviewTop, keyName):
+		t.ScrollToTop()
+	case matchesKey(keys.PreviewBottom, keyName):
+		t.ScrollToBottom()
+	case matchesKey(keys.FollowTail, keyName):
+		t.ToggleFollowTail()
+	default:
+		return false, nil
+	}
+	return true, t.CaptureScrollback()
+}
+
+// HandleWheel scrolls the preview for a mouse wheel step
+func (t *AgentTmuxPane) HandleWheel(up bool) tea.Cmd {
+	if t.search.active() {
+		if up {
+			t.search.scroll(wheelScrollLines, t.GetHeight())
+		} else {
+			t.search.scroll(-wheelScrollLines, t.GetHeight())
+		}
+		return nil
+	}
+	if up {
+		t.ScrollBy(wheelScrollLines)
+	} else {
+		t.ScrollBy(-wheelScrollLines)
+	}
+	return t.CaptureScrollback()
+}
+
+// matchesKey reports whether a key name is one of a binding's keys
+func matchesKey(binding key.Binding, keyName string) bool {
+	return binding.Enabled() && slices.Contains(binding.Keys(), keyName)
 }
 
 // GetPaneSpecificKeybindings returns tmux pane specific keybindings
@@ -118,5 +429,12 @@ func (t *AgentTmuxPane) GetPaneSpecificKeybindings() []key.Binding {
 	return []key.Binding{
 		common.GlobalKeys.AttachTmux,
 		common.GlobalKeys.DetachTmux,
+		common.GlobalKeys.InsertMode,
+		common.GlobalKeys.PreviewPageUp,
+		common.GlobalKeys.PreviewPageDown,
+		common.GlobalKeys.PreviewTop,
+		common.GlobalKeys.PreviewBottom,
+		common.GlobalKeys.FollowTail,
+		common.GlobalKeys.Search,
 	}
 }
\ No newline at end of file
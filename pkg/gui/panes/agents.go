@@ -1,6 +1,7 @@
 package panes
 
 import (
+	"agate/internal/debug"
 	"agate/pkg/app"
 	"agate/pkg/common"
 	"agate/pkg/config"
@@ -37,17 +38,25 @@ type AgentsPane struct {
 	lastSavedBranch string
 	lastSavedRepo   string
 	isGitRepo       bool
+	marked          map[string]bool // Session IDs marked for a broadcast
 }
 
 // AgentListItem implements list.Item interface for agent sessions
 type AgentListItem struct {
-	Type         string // "repo_header", "section_header", "main_session", "linked_session", "empty_message"
+	Type         string // "repo_header", "section_header", "main_session", "linked_session", "agent_session", "empty_message"
 	RepoName     string
 	RepoPath     string // Full path to repository
 	Worktree     *git.WorktreeInfo
 	Index        int // Index in original repo list
 	IsSelected   bool
-	SectionTitle string // For section headers: "Main worktree" or "Linked worktrees"
+	SectionTitle string        // For section headers: "Main worktree" or "Linked worktrees"
+	State        session.State // Lifecycle state of the session's agent
+	Unseen       bool          // Session has activity the user hasn't looked at yet
+	SessionID    string        // Session shown by the row, empty for worktrees with several agents
+	SessionCount int           // Number of agent sessions in the row's worktree
+	AgentLabel   string        // Agent name shown on agent_session rows
+	Marked       bool          // Session is marked for a broadcast
+	Queued       int           // Prompts queued for the session's agent
 }
 
 // FilterValue implements list.Item
@@ -202,37 +211,59 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, item list.Ite
 		linePlain = ""
 		lineStyled = ""
 
-	case "main_session":
+	case "main_session", "linked_session", "agent_session":
 		if workItem.Worktree == nil {
 			return
 		}
-		label := workItem.Worktree.Branch
-		if strings.TrimSpace(label) == "" {
-			label = workItem.Worktree.Name
-		}
-		branchIcon := "\ue0a0" // Nerd Font git branch icon
-		linePlain = "   " + branchIcon + "  " + label
-		if highlight {
-			lineStyled = linePlain
-		} else {
-			lineStyled = d.styles.normalItem.Render(linePlain)
+		badge := " "
+		if workItem.Unseen {
+			badge = "•" // Unseen activity in a background session
 		}
 
-	case "linked_session":
-		if workItem.Worktree == nil {
-			return
+		var label string
+		if workItem.Type == "agent_session" {
+			// Agent rows are indented under their worktree
+			label = "    " + workItem.AgentLabel
+		} else {
+			branch := workItem.Worktree.Branch
+			if strings.TrimSpace(branch) == "" {
+				branch = workItem.Worktree.Name
+			}
+			branchIcon := "\ue0a0" // Nerd Font git branch icon
+			label = " " + branchIcon + "  " + branch
 		}
-		label := workItem.Worktree.Branch
-		if strings.TrimSpace(label) == "" {
-			label = workItem.Worktree.Name
+		mark := " "
+		if workItem.Marked {
+			mark = "✓"
 		}
-		branchIcon := "\ue0a0" // Nerd Font git branch icon
-		linePlain = "   " + branchIcon + "  " + label
+		left := mark + badge + label
+
+		// Right-align the agent state unless the row is showing key hints instead
 		if highlight {
+			linePlain = left
 			lineStyled = linePlain
-		} else {
-			lineStyled = d.styles.normalItem.Render(linePlain)
+			break
+		}
+		rightText := sessionStateIcon(workItem.State) + " " + workItem.State.String()
+		rightStyle := sessionStateStyle(workItem.State)
+		if workItem.Type != "agent_session" && workItem.SessionCount > 1 {
+			// Worktrees running several agents show their states on the rows below
+			rightText = fmt.Sprintf("%d agents", workItem.SessionCount)
+			rightStyle = d.styles.mustedText
+		}
+		queued := ""
+		if workItem.Queued > 0 {
+			queued = fmt.Sprintf("≡%d ", workItem.Queued)
 		}
+		availableSpace := innerWidth - lipgloss.Width(left) - lipgloss.Width(queued+rightText)
+		if availableSpace < 1 {
+			availableSpace = 1
+		}
+		spacing := strings.Repeat(" ", availableSpace)
+		linePlain = left + spacing + queued + rightText
+		badgeStyled := lipgloss.NewStyle().Foreground(lipgloss.Color(theme.AgateColor)).Bold(true).Render(badge)
+		markStyled := lipgloss.NewStyle().Foreground(lipgloss.Color(theme.InfoStatus)).Bold(true).Render(mark)
+		lineStyled = markStyled + badgeStyled + d.styles.normalItem.Render(label+spacing) + d.styles.mustedText.Render(queued) + rightStyle.Render(rightText)
 
 	case "empty_message":
 		// Show empty state message
@@ -255,18 +286,25 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, item list.Ite
 	}
 
 	// Handle hint text - only when pane is active and hovering a session item
-	if hint == "" && d.isActive && highlight && (workItem.Type == "main_session" || workItem.Type == "linked_session") {
+	if hint == "" && d.isActive && highlight && isSessionItem(workItem) {
+		deletable := workItem.Type == "linked_session" || workItem.Type == "agent_session"
 		// Use the same logic as the orange bar - workItem.IsSelected indicates active/selected
-		if workItem.IsSelected {
+		if workItem.State == session.StateStopped && workItem.SessionID != "" {
+			// Stopped sessions have nothing to open until they're resumed
+			hint = " R to resume"
+			if deletable {
+				hint = " R to resume, D to delete"
+			}
+		} else if workItem.IsSelected {
 			// Hovering a row that is already selected (has orange bar) - show "enter to open"
 			hint = " ↵ to open"
-			if workItem.Type == "linked_session" {
+			if deletable {
 				hint = " ↵ to open, D to delete"
 			}
 		} else {
 			// Hovering a row that is not selected (no orange bar) - show "enter to select"
 			hint = " ↵ to select"
-			if workItem.Type == "linked_session" {
+			if deletable {
 				hint = " ↵ to select, D to delete"
 			}
 		}
@@ -351,6 +389,38 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, item list.Ite
 	}
 }
 
+// sessionStateIcon returns the indicator shown next to a session's state
+func sessionStateIcon(state session.State) string {
+	switch state {
+	case session.StateWorking:
+		return "●"
+	case session.StateWaitingForInput:
+		return "◆"
+	case session.StateErrored, session.StateCrashed:
+		return "✗"
+	case session.StateExited, session.StateStopped:
+		return "■"
+	default:
+		return "○"
+	}
+}
+
+// sessionStateStyle returns the color used to render a session's state
+func sessionStateStyle(state session.State) lipgloss.Style {
+	color := theme.TextMuted
+	switch state {
+	case session.StateWorking:
+		color = theme.InfoStatus
+	case session.StateWaitingForInput:
+		color = theme.WarningYellow
+	case session.StateIdle:
+		color = theme.SuccessStatus
+	case session.StateErrored, session.StateCrashed:
+		color = theme.ErrorStatus
+	}
+	return lipgloss.NewStyle().Foreground(lipgloss.Color(color))
+}
+
 // NewAgentsPane creates a new AgentsPane instance
 func NewAgentsPane(sessionManager *session.Manager) *AgentsPane {
 	styles := newItemStyles()
@@ -383,6 +453,7 @@ func NewAgentsPane(sessionManager *session.Manager) *AgentsPane {
 		sessionManager: sessionManager,
 		delegate:       delegate,
 		expandedRepos:  expandedRepos, // Use the same map reference
+		marked:         make(map[string]bool),
 	}
 
 	// Initial refresh
@@ -451,6 +522,10 @@ func (r *AgentsPane) GetTitleStyle() components.TitleStyle {
 		repoHelp := common.GlobalKeys.AddRepo.Help()
 		sessionHelp := common.GlobalKeys.NewWorktree.Help()
 		shortcuts = fmt.Sprintf("%s %s • %s %s", repoHelp.Key, repoHelp.Desc, sessionHelp.Key, sessionHelp.Desc)
+		if len(r.marked) > 0 {
+			broadcastHelp := common.GlobalKeys.Broadcast.Help()
+			shortcuts = fmt.Sprintf("%d marked • %s %s", len(r.marked), broadcastHelp.Key, broadcastHelp.Desc)
+		}
 	} else {
 		// When not active, show pane number
 		shortcuts = "(0)"
@@ -491,7 +566,7 @@ func (r *AgentsPane) View() string {
 		sessionItemCount := 0
 		for _, item := range r.items {
 			if agentItem, ok := item.(AgentListItem); ok {
-				if agentItem.Type == "main_session" || agentItem.Type == "linked_session" {
+				if isSessionItem(agentItem) {
 					sessionItemCount++
 				}
 			}
@@ -552,20 +627,23 @@ func (r *AgentsPane) HandleKey(key string) (handled bool, cmd tea.Cmd) {
 					// Update the list's delegate
 					r.list.SetDelegate(r.delegate)
 					r.rebuildListPreservingSelection(currentIndex)
-				} else if (workItem.Type == "main_session" || workItem.Type == "linked_session") && workItem.Worktree != nil {
-					// Check if this worktree is already selected
-					if r.isActiveWorktree(workItem.Worktree) {
+				} else if isSessionItem(workItem) && workItem.Worktree != nil {
+					// Check if this row is already selected
+					if workItem.IsSelected || (workItem.SessionID == "" && r.isActiveWorktree(workItem.Worktree)) {
 						// Already selected - attach to tmux session
-						if r.sessionManager != nil {
-							if session := r.sessionManager.GetSessionForWorktree(workItem.Worktree); session != nil && session.TmuxSession != nil {
-								// Return a command that triggers tmux attachment
-								return true, func() tea.Msg {
-									return AttachToSessionMsg{Session: session}
-								}
+						if session := r.sessionForItem(workItem); session != nil && session.GetTmuxSession() != nil {
+							// Return a command that triggers tmux attachment
+							return true, func() tea.Msg {
+								return AttachToSessionMsg{Session: session}
 							}
 						}
 					} else {
 						// Not selected yet - select it
+						if workItem.SessionID != "" && r.sessionManager != nil {
+							if _, err := r.sessionManager.SwitchToSession(workItem.SessionID); err != nil {
+								git.DebugLog("failed to switch session: %v", err)
+							}
+						}
 						r.setActiveWorktree(workItem.Worktree)
 						r.rebuildListPreservingSelection(currentIndex)
 					}
@@ -577,14 +655,12 @@ func (r *AgentsPane) HandleKey(key string) (handled bool, cmd tea.Cmd) {
 		// Delete selected session
 		if len(r.items) > 0 {
 			selectedItem := r.list.SelectedItem()
-			if workItem, ok := selectedItem.(AgentListItem); ok && workItem.Type == "linked_session" && workItem.Worktree != nil {
-				// Only allow deletion of linked worktree sessions, not main sessions
-				if r.sessionManager != nil {
-					if session := r.sessionManager.GetSessionForWorktree(workItem.Worktree); session != nil {
-						// Return a command to trigger the delete confirmation dialog
-						return true, func() tea.Msg {
-							return DeleteSessionRequestMsg{Session: session}
-						}
+			if workItem, ok := selectedItem.(AgentListItem); ok && (workItem.Type == "linked_session" || workItem.Type == "agent_session") {
+				// Main worktree rows can't be deleted, only linked worktrees and individual agents
+				if session := r.sessionForItem(workItem); session != nil {
+					// Return a command to trigger the delete confirmation dialog
+					return true, func() tea.Msg {
+						return DeleteSessionRequestMsg{Session: session}
 					}
 				}
 			}
@@ -669,6 +745,97 @@ func (r *AgentsPane) moveDown() {
 	}
 }
 
+// GetSelectedSession returns the session of the highlighted row, or nil when
+// the row isn't a session
+func (r *AgentsPane) GetSelectedSession() *session.Session {
+	if len(r.items) == 0 {
+		return nil
+	}
+	workItem, ok := r.list.SelectedItem().(AgentListItem)
+	if !ok || !isSessionItem(workItem) {
+		return nil
+	}
+	return r.sessionForItem(workItem)
+}
+
+// ToggleMark marks the highlighted session for a broadcast, or unmarks it.
+// Rows of worktrees running several agents mark nothing; their agents'
+// rows below them do.
+func (r *AgentsPane) ToggleMark() {
+	workItem, ok := r.list.SelectedItem().(AgentListItem)
+	if !ok || workItem.SessionID == "" {
+		return
+	}
+	if r.marked[workItem.SessionID] {
+		delete(r.marked, workItem.SessionID)
+	} else {
+		r.marked[workItem.SessionID] = true
+	}
+	r.rebuildListPreservingSelection(r.list.Index())
+}
+
+// MarkedSessions returns the sessions marked for a broadcast, including
+// those in collapsed repositories
+func (r *AgentsPane) MarkedSessions() []*session.Session {
+	if r.sessionManager == nil {
+		return nil
+	}
+	var sessions []*session.Session
+	for _, sess := range r.sessionManager.ListSessions() {
+		if r.marked[sess.GetID()] {
+			sessions = append(sessions, sess)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].GetName() < sessions[j].GetName()
+	})
+	return sessions
+}
+
+// Unmark unmarks the given sessions
+func (r *AgentsPane) Unmark(sessionIDs ...string) {
+	changed := false
+	for _, id := range sessionIDs {
+		if r.marked[id] {
+			delete(r.marked, id)
+			changed = true
+		}
+	}
+	if changed {
+		r.rebuildListPreservingSelection(r.list.Index())
+	}
+}
+
+// GetHoveredWorktree returns the worktree of the highlighted row, or nil
+func (r *AgentsPane) GetHoveredWorktree() *git.WorktreeInfo {
+	if len(r.items) == 0 {
+		return nil
+	}
+	workItem, ok := r.list.SelectedItem().(AgentListItem)
+	if !ok || workItem.Worktree == nil {
+		return nil
+	}
+	clone := *workItem.Worktree
+	return &clone
+}
+
+// sessionForItem resolves the session a row refers to. Worktree rows that
+// group several agents resolve to the worktree's preferred session.
+func (r *AgentsPane) sessionForItem(item AgentListItem) *session.Session {
+	if r.sessionManager == nil {
+		return nil
+	}
+	if item.SessionID != "" {
+		return r.sessionManager.GetSession(item.SessionID)
+	}
+	return r.sessionManager.GetSessionForWorktree(item.Worktree)
+}
+
+// isSessionItem reports whether the item is a worktree or agent row
+func isSessionItem(item AgentListItem) bool {
+	return item.Type == "main_session" || item.Type == "linked_session" || item.Type == "agent_session"
+}
+
 // GetSelectedWorktree returns the currently selected worktree
 func (r *AgentsPane) GetSelectedWorktree() *git.WorktreeInfo {
 	if r.activeWorktree != nil {
@@ -778,6 +945,13 @@ func (r *AgentsPane) Refresh() error {
 	// Get all sessions from session manager (now includes both main and linked)
 	sessions := r.sessionManager.ListSessions()
 
+	// Forget marks of deleted sessions
+	for id := range r.marked {
+		if r.sessionManager.GetSession(id) == nil {
+			delete(r.marked, id)
+		}
+	}
+
 	// Group sessions by repository
 	r.groupedSessions = make(map[string][]*session.Session)
 	for _, sess := range sessions {
@@ -809,6 +983,22 @@ func (r *AgentsPane) Refresh() error {
 	return nil
 }
 
+// HandleSessionEvent updates the list after a change in the session manager
+func (r *AgentsPane) HandleSessionEvent(event session.Event) {
+	if err := r.Refresh(); err != nil {
+		debug.DebugLog("Failed to refresh agents pane after %s: %v", event.Type, err)
+	}
+
+	// Follow sessions activated from outside the pane
+	if event.Type == session.EventSessionActivated && event.Session != nil && event.Session.Worktree != nil {
+		if !r.isActiveWorktree(event.Session.Worktree) {
+			r.setActiveWorktree(event.Session.Worktree)
+			r.rebuildListPreservingSelection(r.list.Index())
+		}
+		r.jumpToActiveSession()
+	}
+}
+
 // buildItemList creates a list of items for the bubbles list
 func (r *AgentsPane) buildItemList() {
 	r.items = nil
@@ -872,7 +1062,7 @@ func (r *AgentsPane) buildItemList() {
 		// Only add sessions if repository is expanded
 		if r.expandedRepos[repoName] {
 			// Get main and linked sessions for this repository
-			mainSession := r.sessionManager.GetMainSession(repoName)
+			mainSessions := r.sessionManager.GetMainSessions(repoName)
 			linkedSessions := r.sessionManager.GetLinkedSessions(repoName)
 
 			// Add "Main worktree" section header
@@ -882,15 +1072,9 @@ func (r *AgentsPane) buildItemList() {
 				SectionTitle: "Main worktree",
 			})
 
-			// Add main worktree session
-			if mainSession != nil && mainSession.Worktree != nil {
-				worktreeCopy := *mainSession.Worktree
-				r.items = append(r.items, AgentListItem{
-					Type:       "main_session",
-					RepoName:   repoName,
-					Worktree:   &worktreeCopy,
-					IsSelected: r.isActiveWorktree(&worktreeCopy),
-				})
+			// Add main worktree sessions
+			if len(mainSessions) > 0 {
+				r.appendWorktreeItems("main_session", repoName, mainSessions)
 			} else {
 				// Show placeholder if no main session
 				r.items = append(r.items, AgentListItem{
@@ -915,24 +1099,22 @@ func (r *AgentsPane) buildItemList() {
 
 			// Add linked worktree sessions
 			if len(linkedSessions) > 0 {
-				// Sort linked sessions by branch name
-				sort.Slice(linkedSessions, func(i, j int) bool {
+				// Sort linked sessions by branch name, keeping each worktree's agents in order
+				sort.SliceStable(linkedSessions, func(i, j int) bool {
 					if linkedSessions[i].Worktree != nil && linkedSessions[j].Worktree != nil {
 						return linkedSessions[i].Worktree.Branch < linkedSessions[j].Worktree.Branch
 					}
-					return linkedSessions[i].Name < linkedSessions[j].Name
+					return linkedSessions[i].GetName() < linkedSessions[j].GetName()
 				})
 
-				for _, sess := range linkedSessions {
-					if sess.Worktree != nil {
-						worktreeCopy := *sess.Worktree
-						r.items = append(r.items, AgentListItem{
-							Type:       "linked_session",
-							RepoName:   repoName,
-							Worktree:   &worktreeCopy,
-							IsSelected: r.isActiveWorktree(&worktreeCopy),
-						})
+				// Group consecutive sessions that share a worktree
+				for start := 0; start < len(linkedSessions); {
+					end := start + 1
+					for end < len(linkedSessions) && linkedSessions[end].WorktreeKey == linkedSessions[start].WorktreeKey {
+						end++
 					}
+					r.appendWorktreeItems("linked_session", repoName, linkedSessions[start:end])
+					start = end
 				}
 			} else {
 				// No linked worktrees - add placeholder
@@ -952,6 +1134,60 @@ func (r *AgentsPane) buildItemList() {
 	r.delegate.activeWorktree = r.activeWorktree
 }
 
+// appendWorktreeItems adds a row for a worktree and, when it runs several
+// agents, an indented row for each of them
+func (r *AgentsPane) appendWorktreeItems(itemType, repoName string, sessions []*session.Session) {
+	if len(sessions) == 0 || sessions[0].Worktree == nil {
+		return
+	}
+
+	worktreeCopy := *sessions[0].Worktree
+	worktreeItem := AgentListItem{
+		Type:         itemType,
+		RepoName:     repoName,
+		Worktree:     &worktreeCopy,
+		IsSelected:   r.isActiveWorktree(&worktreeCopy),
+		SessionCount: len(sessions),
+	}
+
+	if len(sessions) == 1 {
+		worktreeItem.SessionID = sessions[0].GetID()
+		worktreeItem.Marked = r.marked[sessions[0].GetID()]
+		worktreeItem.Queued = len(sessions[0].GetQueue())
+		worktreeItem.State = sessions[0].GetState()
+		worktreeItem.Unseen = sessions[0].IsUnseen()
+		r.items = append(r.items, worktreeItem)
+		return
+	}
+
+	// The worktree row only carries the cursor bar when no agent row below it will
+	worktreeItem.IsSelected = false
+	for _, sess := range sessions {
+		worktreeItem.Unseen = worktreeItem.Unseen || sess.IsUnseen()
+	}
+	r.items = append(r.items, worktreeItem)
+
+	var activeID string
+	if active := r.sessionManager.GetActiveSession(); active != nil {
+		activeID = active.GetID()
+	}
+	for _, sess := range sessions {
+		r.items = append(r.items, AgentListItem{
+			Type:         "agent_session",
+			RepoName:     repoName,
+			Worktree:     &worktreeCopy,
+			IsSelected:   r.isActiveWorktree(&worktreeCopy) && sess.GetID() == activeID,
+			State:        sess.GetState(),
+			Unseen:       sess.IsUnseen(),
+			SessionID:    sess.GetID(),
+			SessionCount: len(sessions),
+			AgentLabel:   sess.AgentLabel(),
+			Marked:       r.marked[sess.GetID()],
+			Queued:       len(sess.GetQueue()),
+		})
+	}
+}
+
 func (r *AgentsPane) firstRepoName() string {
 	for _, item := range r.items {
 		if workItem, ok := item.(AgentListItem); ok && workItem.Type == "repo_header" {
@@ -1055,20 +1291,30 @@ func (r *AgentsPane) jumpToActiveSession() {
 		return
 	}
 
-	// Find the active session in the list
+	// Find the active session in the list, falling back to its worktree row
+	fallback := -1
 	for idx, item := range r.items {
 		if workItem, ok := item.(AgentListItem); ok {
-			if (workItem.Type == "main_session" || workItem.Type == "linked_session") &&
+			if isSessionItem(workItem) &&
 				workItem.Worktree != nil &&
 				workItem.Worktree.Path == activeSession.Worktree.Path {
 				// Ensure this item is selectable
-				if r.isSelectableItem(idx) {
+				if !r.isSelectableItem(idx) {
+					continue
+				}
+				if workItem.SessionID == activeSession.GetID() {
 					r.list.Select(idx)
 					return
 				}
+				if fallback < 0 {
+					fallback = idx
+				}
 			}
 		}
 	}
+	if fallback >= 0 {
+		r.list.Select(fallback)
+	}
 }
 
 func (r *AgentsPane) rebuildListPreservingSelection(selectedIndex int) {
@@ -1126,7 +1372,12 @@ func (r *AgentsPane) GetPaneSpecificKeybindings() []key.Binding {
 	return []key.Binding{
 		common.GlobalKeys.AddRepo,
 		common.GlobalKeys.NewWorktree,
+		common.GlobalKeys.AddAgent,
+		common.GlobalKeys.RestartAgent,
+		common.GlobalKeys.ResumeSession,
 		common.GlobalKeys.DeleteWorktree,
+		common.GlobalKeys.MarkSession,
+		common.GlobalKeys.Broadcast,
 	}
 }
 
@@ -0,0 +1,142 @@
+package panes
+
+import (
+	"fmt"
+	"sync"
+
+	"agate/pkg/tmux"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// namedKeys are the tmux names of the keys Bubble Tea reports by type
+var namedKeys = map[tea.KeyType]string{
+	tea.KeyEnter:          "Enter",
+	tea.KeyTab:            "Tab",
+	tea.KeyShiftTab:       "BTab",
+	tea.KeyBackspace:      "BSpace",
+	tea.KeyEsc:            "Escape",
+	tea.KeySpace:          "Space",
+	tea.KeyUp:             "Up",
+	tea.KeyDown:           "Down",
+	tea.KeyRight:          "Right",
+	tea.KeyLeft:           "Left",
+	tea.KeyShiftUp:        "S-Up",
+	tea.KeyShiftDown:      "S-Down",
+	tea.KeyShiftRight:     "S-Right",
+	tea.KeyShiftLeft:      "S-Left",
+	tea.KeyCtrlUp:         "C-Up",
+	tea.KeyCtrlDown:       "C-Down",
+	tea.KeyCtrlRight:      "C-Right",
+	tea.KeyCtrlLeft:       "C-Left",
+	tea.KeyCtrlShiftUp:    "C-S-Up",
+	tea.KeyCtrlShiftDown:  "C-S-Down",
+	tea.KeyCtrlShiftRight: "C-S-Right",
+	tea.KeyCtrlShiftLeft:  "C-S-Left",
+	tea.KeyHome:           "Home",
+	tea.KeyEnd:            "End",
+	tea.KeyShiftHome:      "S-Home",
+	tea.KeyShiftEnd:       "S-End",
+	tea.KeyCtrlHome:       "C-Home",
+	tea.KeyCtrlEnd:        "C-End",
+	tea.KeyPgUp:           "PPage",
+	tea.KeyPgDown:         "NPage",
+	tea.KeyCtrlPgUp:       "C-PPage",
+	tea.KeyCtrlPgDown:     "C-NPage",
+	tea.KeyInsert:         "IC",
+	tea.KeyDelete:         "DC",
+	tea.KeyF1:             "F1",
+	tea.KeyF2:             "F2",
+	tea.KeyF3:             "F3",
+	tea.KeyF4:             "F4",
+	tea.KeyF5:             "F5",
+	tea.KeyF6:             "F6",
+	tea.KeyF7:             "F7",
+	tea.KeyF8:             "F8",
+	tea.KeyF9:             "F9",
+	tea.KeyF10:            "F10",
+	tea.KeyF11:            "F11",
+	tea.KeyF12:            "F12",
+}
+
+// TypeErrorMsg reports that keys typed in insert mode couldn't be sent to
+// the agent. The keys typed after them are dropped.
+type TypeErrorMsg struct {
+	Err error
+}
+
+// typingQueue sends keys typed in insert mode to the agent off the UI
+// goroutine, in the order they were typed. Bubble Tea runs commands
+// concurrently, so each command sends whatever is queued rather than its own key.
+type typingQueue struct {
+	mu      sync.Mutex
+	pending []func() error
+
+	sendMu sync.Mutex // Held while sending, so queued keys go out one at a time
+}
+
+// push queues sends and returns the command that carries them out
+func (q *typingQueue) push(sends ...func() error) tea.Cmd {
+	if len(sends) == 0 {
+		return nil
+	}
+	q.mu.Lock()
+	q.pending = append(q.pending, sends...)
+	q.mu.Unlock()
+	return q.flush
+}
+
+// flush sends the queued keys, stopping at the first that fails
+func (q *typingQueue) flush() tea.Msg {
+	q.sendMu.Lock()
+	defer q.sendMu.Unlock()
+	for {
+		q.mu.Lock()
+		if len(q.pending) == 0 {
+			q.mu.Unlock()
+			return nil
+		}
+		send := q.pending[0]
+		q.pending = q.pending[1:]
+		q.mu.Unlock()
+
+		if err := send(); err != nil {
+			q.mu.Lock()
+			q.pending = nil
+			q.mu.Unlock()
+			return TypeErrorMsg{Err: err}
+		}
+	}
+}
+
+// keyForMsg translates a key Bubble Tea read into a keystroke for the agent.
+// It returns false for keys that have no equivalent, such as F13 and up.
+func keyForMsg(msg tea.KeyMsg) (tmux.Key, bool) {
+	var key tmux.Key
+	switch {
+	case msg.Type == tea.KeyRunes:
+		if !msg.Alt {
+			return tmux.Key{Text: string(msg.Runes)}, true
+		}
+		if len(msg.Runes) != 1 {
+			return key, false
+		}
+		key.Name = string(msg.Runes)
+	case namedKeys[msg.Type] != "":
+		key.Name = namedKeys[msg.Type]
+	case msg.Type >= tea.KeyCtrlAt && msg.Type <= tea.KeyCtrlUnderscore:
+		// The remaining control characters, e.g. Ctrl+C
+		r := rune(msg.Type) + '@'
+		if r >= 'A' && r <= 'Z' {
+			r += 'a' - 'A'
+		}
+		key.Name = fmt.Sprintf("C-%c", r)
+	default:
+		return key, false
+	}
+
+	if msg.Alt {
+		key.Name = "M-" + key.Name
+	}
+	return key, true
+}
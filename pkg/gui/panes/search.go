@@ -0,0 +1,241 @@
+package panes
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"agate/pkg/common"
+	"agate/pkg/gui/components"
+	"agate/pkg/gui/theme"
+	"agate/pkg/search"
+	"agate/pkg/tmux"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/muesli/reflow/truncate"
+)
+
+// SearchablePane is a preview pane that can search its session's history
+type SearchablePane interface {
+	components.Pane
+	// StartSearch captures the session's history and shows the matches of re,
+	// starting at the one nearest to line, or the newest when line is negative
+	StartSearch(re *regexp.Regexp, line int) tea.Cmd
+	SetSearchResult(msg SearchMsg)
+	Searching() bool
+}
+
+// SearchMsg carries a session's history captured for a search
+type SearchMsg struct {
+	SessionName string
+	Lines       []string
+	Err         error
+	seq         int
+}
+
+// previewSearch holds a search through a snapshot of a session's history.
+// It is shared by the agent and shell preview panes.
+type previewSearch struct {
+	re      *regexp.Regexp // Nil while no search is shown
+	lines   []string       // Captured history, oldest first
+	matches []search.Match
+	current int // Index of the shown match in matches
+	top     int // First line shown
+	jumpTo  int // Line whose nearest match is shown once the capture arrives
+	loading bool
+	err     error
+	seq     int // Numbers captures so stale ones are dropped
+}
+
+// active reports whether a search is shown
+func (s *previewSearch) active() bool {
+	return s.re != nil
+}
+
+// start captures a session's history for a search
+func (s *previewSearch) start(session tmux.Backend, re *regexp.Regexp, line int) tea.Cmd {
+	if session == nil {
+		return nil
+	}
+	s.seq++
+	*s = previewSearch{re: re, jumpTo: line, loading: true, seq: s.seq}
+
+	seq := s.seq
+	return func() tea.Msg {
+		lines, err := search.CaptureHistory(session)
+		return SearchMsg{SessionName: session.GetSessionName(), Lines: lines, Err: err, seq: seq}
+	}
+}
+
+// stop closes the search, dropping captures still on their way
+func (s *previewSearch) stop() {
+	s.seq++
+	*s = previewSearch{seq: s.seq}
+}
+
+// set shows a capture requested by start
+func (s *previewSearch) set(msg SearchMsg, session tmux.Backend, height int) {
+	if !s.active() || msg.seq != s.seq || session == nil || msg.SessionName != session.GetSessionName() {
+		return
+	}
+	s.loading = false
+	s.err = msg.Err
+	s.lines = msg.Lines
+	s.matches = search.FindAll(s.re, s.lines)
+	if len(s.matches) == 0 {
+		s.top = max(0, len(s.lines)-height)
+		return
+	}
+
+	s.current = len(s.matches) - 1
+	if s.jumpTo >= 0 {
+		for i, match := range s.matches {
+			if match.Line >= s.jumpTo {
+				s.current = i
+				break
+			}
+		}
+	}
+	s.reveal(height)
+}
+
+// jump shows the match delta matches newer than the current one, wrapping around
+func (s *previewSearch) jump(delta, height int) {
+	if len(s.matches) == 0 {
+		return
+	}
+	s.current = ((s.current+delta)%len(s.matches) + len(s.matches)) % len(s.matches)
+	s.reveal(height)
+}
+
+// reveal centers the current match unless it is already in view
+func (s *previewSearch) reveal(height int) {
+	line := s.matches[s.current].Line
+	if line < s.top || line >= s.top+height {
+		s.top = line - height/2
+	}
+	s.clamp(height)
+}
+
+// scroll moves the view by lines, back into history when positive
+func (s *previewSearch) scroll(lines, height int) {
+	s.top -= lines
+	s.clamp(height)
+}
+
+// clamp keeps the view within the captured lines
+func (s *previewSearch) clamp(height int) {
+	s.top = min(max(s.top, 0), max(0, len(s.lines)-height))
+}
+
+// handleKey jumps between matches and scrolls the snapshot
+func (s *previewSearch) handleKey(keyName string, height int) bool {
+	keys := common.GlobalKeys
+	page := max(1, height-1)
+	switch {
+	case matchesKey(keys.SearchNext, keyName):
+		s.jump(-1, height)
+	case matchesKey(keys.SearchPrev, keyName):
+		s.jump(1, height)
+	case matchesKey(keys.CloseSearch, keyName), matchesKey(keys.FollowTail, keyName):
+		s.stop()
+	case matchesKey(keys.Up, keyName):
+		s.scroll(1, height)
+	case matchesKey(keys.Down, keyName):
+		s.scroll(-1, height)
+	case matchesKey(keys.PreviewPageUp, keyName):
+		s.scroll(page, height)
+	case matchesKey(keys.PreviewPageDown, keyName):
+		s.scroll(-page, height)
+	case matchesKey(keys.PreviewTop, keyName):
+		s.top = 0
+	case matchesKey(keys.PreviewBottom, keyName):
+		s.scroll(-len(s.lines), height)
+	default:
+		return false
+	}
+	return true
+}
+
+// status describes the search for the pane title
+func (s *previewSearch) status() string {
+	switch {
+	case s.loading:
+		return "searching… • esc close"
+	case s.err != nil:
+		return "search failed • esc close"
+	case len(s.matches) == 0:
+		return "no matches • esc close"
+	}
+	return fmt.Sprintf("match %d/%d • n/N • esc close", s.current+1, len(s.matches))
+}
+
+// view renders the snapshot with the matches highlighted
+func (s *previewSearch) view(width, height int, thumbColor string) string {
+	if s.loading || s.err != nil {
+		message := "Searching..."
+		if s.err != nil {
+			message = "Search failed: " + s.err.Error()
+		}
+		return lipgloss.NewStyle().
+			Width(width).
+			Height(height).
+			Align(lipgloss.Center, lipgloss.Center).
+			Foreground(lipgloss.Color(theme.TextMuted)).
+			Render(message)
+	}
+
+	current := search.Match{Line: -1}
+	if len(s.matches) > 0 {
+		current = s.matches[s.current]
+	}
+	end := min(s.top+height, len(s.lines))
+	lines := make([]string, 0, end-s.top)
+	for i := s.top; i < end; i++ {
+		if i != current.Line {
+			lines = append(lines, search.Highlight(s.lines[i], s.re, -1))
+			continue
+		}
+		line := search.Highlight(s.lines[i], s.re, current.Start)
+		// Lines are captured joined, so the current match may lie beyond the
+		// right edge; cut the line's start off to bring it into view
+		end := lipgloss.Width(tmux.StripANSI(s.lines[i])[:current.End])
+		if overflow := end - (width - 1); overflow > 0 {
+			line = ansi.TruncateLeft(line, overflow+(width-1)/4, "…")
+		}
+		lines = append(lines, line)
+	}
+
+	history := max(0, len(s.lines)-height)
+	return renderRows(lines, width, height, scrollBar(height, history, history-s.top), thumbColor)
+}
+
+// renderRows renders lines into a view of width by height with a scroll bar
+// in the last column, whose thumb covers the rows set in bar
+func renderRows(lines []string, width, height int, bar []bool, thumbColor string) string {
+	if width < 2 || height < 1 {
+		return strings.Join(lines, "\n")
+	}
+
+	track := lipgloss.NewStyle().Foreground(lipgloss.Color(theme.BorderMuted)).Render("│")
+	thumb := lipgloss.NewStyle().Foreground(lipgloss.Color(thumbColor)).Render("┃")
+
+	rendered := make([]string, height)
+	for i := range rendered {
+		line := ""
+		if i < len(lines) {
+			line = truncate.String(lines[i], uint(width-1))
+		}
+		if padding := width - 1 - lipgloss.Width(line); padding > 0 {
+			line += strings.Repeat(" ", padding)
+		}
+		if bar[i] {
+			rendered[i] = line + "\x1b[0m" + thumb
+		} else {
+			rendered[i] = line + "\x1b[0m" + track
+		}
+	}
+	return strings.Join(rendered, "\n")
+}
@@ -1,6 +1,9 @@
 package panes
 
 import (
+	"regexp"
+
+	"agate/pkg/common"
 	"agate/pkg/gui/components"
 	"agate/pkg/gui/theme"
 	"agate/pkg/tmux"
@@ -13,8 +16,9 @@ import (
 // ShellTmuxPane manages the display of shell content using tmux like the AgentTmuxPane
 type ShellTmuxPane struct {
 	*components.BasePane
-	session *tmux.TmuxSession
+	session tmux.Backend
 	content string
+	search  previewSearch // Search through a snapshot of the history
 }
 
 // NewShellTmuxPane creates a new ShellTmuxPane instance
@@ -26,7 +30,10 @@ func NewShellTmuxPane() *ShellTmuxPane {
 }
 
 // SetSession sets the tmux session for this pane
-func (s *ShellTmuxPane) SetSession(session *tmux.TmuxSession) {
+func (s *ShellTmuxPane) SetSession(session tmux.Backend) {
+	if s.session != session {
+		s.search.stop()
+	}
 	s.session = session
 	s.updateContent()
 }
@@ -58,9 +65,11 @@ func (s *ShellTmuxPane) SetSize(width, height int) {
 // GetTitleStyle returns the plain title style for the shell pane
 func (s *ShellTmuxPane) GetTitleStyle() components.TitleStyle {
 	shortcuts := ""
-	if s.IsActive() {
+	if s.search.active() {
+		shortcuts = s.search.status()
+	} else if s.IsActive() {
 		// When active, format shortcuts like the footer (without brackets)
-		shortcuts = "↵ attach • ctrl+q detach"
+		shortcuts = "↵ attach • " + common.GlobalKeys.DetachTmux.Help().Key + " detach"
 	} else {
 		// When not active, show pane number
 		shortcuts = "(3)"
@@ -76,6 +85,10 @@ func (s *ShellTmuxPane) GetTitleStyle() components.TitleStyle {
 
 // View renders the shell pane content
 func (s *ShellTmuxPane) View() string {
+	if s.search.active() {
+		return s.search.view(s.GetWidth(), s.GetHeight(), theme.AgateColor)
+	}
+
 	// Update content from session if available
 	if s.session != nil {
 		s.updateContent()
@@ -106,14 +119,34 @@ func (s *ShellTmuxPane) Update(msg tea.Msg) (components.Pane, tea.Cmd) {
 	return s, nil
 }
 
-// HandleKey processes keyboard input when the pane is active
+// StartSearch captures the shell's history and shows the matches of re,
+// starting at the one nearest to line, or the newest when line is negative
+func (s *ShellTmuxPane) StartSearch(re *regexp.Regexp, line int) tea.Cmd {
+	return s.search.start(s.session, re, line)
+}
+
+// SetSearchResult shows the history captured for a search
+func (s *ShellTmuxPane) SetSearchResult(msg SearchMsg) {
+	s.search.set(msg, s.session, s.GetHeight())
+}
+
+// Searching reports whether the pane shows a search instead of the live screen
+func (s *ShellTmuxPane) Searching() bool {
+	return s.search.active()
+}
+
+// HandleKey processes keyboard input when the pane is active. Only a search
+// takes keys; attaching and detaching are handled at the main model level.
 func (s *ShellTmuxPane) HandleKey(key string) (handled bool, cmd tea.Cmd) {
-	// ShellTmuxPane uses tmux attach/detach like AgentTmuxPane - no direct key handling
+	if s.search.active() {
+		return s.search.handleKey(key, s.GetHeight()), nil
+	}
 	return false, nil
 }
 
 // GetPaneSpecificKeybindings returns shell pane specific keybindings
 func (s *ShellTmuxPane) GetPaneSpecificKeybindings() []key.Binding {
-	// No shell-specific keybindings currently
-	return []key.Binding{}
+	return []key.Binding{
+		common.GlobalKeys.Search,
+	}
 }
\ No newline at end of file
@@ -0,0 +1,260 @@
+// Package hooks runs user-configured commands when sessions change
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"agate/internal/debug"
+	"agate/pkg/config"
+	"agate/pkg/session"
+)
+
+// Hook names, as used for the keys of the "hooks" section in config.json
+const (
+	SessionCreated  = "session_created"   // A session was started
+	SessionDeleted  = "session_deleted"   // A session was deleted
+	StateChanged    = "state_changed"     // The agent's lifecycle state changed
+	WaitingForInput = "waiting_for_input" // The agent needs the user, e.g. to approve an action
+	AgentExited     = "agent_exited"      // The agent process exited or crashed
+	WorktreeDeleted = "worktree_deleted"  // A linked worktree was deleted
+)
+
+// hookTimeout bounds how long a hook may run before it is killed
+const hookTimeout = time.Minute
+
+// validHooks lists the hook names the runner knows about
+var validHooks = map[string]bool{
+	SessionCreated:  true,
+	SessionDeleted:  true,
+	StateChanged:    true,
+	WaitingForInput: true,
+	AgentExited:     true,
+	WorktreeDeleted: true,
+}
+
+// Payload is the JSON a hook receives on stdin
+type Payload struct {
+	Event         string    `json:"event"`
+	SessionID     string    `json:"session_id,omitempty"`
+	WorktreePath  string    `json:"worktree_path,omitempty"`
+	Branch        string    `json:"branch,omitempty"`
+	Repo          string    `json:"repo,omitempty"`
+	Agent         string    `json:"agent,omitempty"`
+	State         string    `json:"state,omitempty"`
+	PreviousState string    `json:"previous_state,omitempty"`
+	Restarted     bool      `json:"restarted,omitempty"`
+	At            time.Time `json:"at"`
+}
+
+// stateHooks are the hooks about agents' states, which every agate process
+// probing the sessions would otherwise run
+var stateHooks = map[string]bool{
+	StateChanged:    true,
+	WaitingForInput: true,
+	AgentExited:     true,
+}
+
+// Runner runs the configured hooks for session events
+type Runner struct {
+	hooks      map[string][]string
+	skipStates bool           // Leave state hooks to the process monitoring the sessions
+	running    sync.WaitGroup // Hook commands that haven't finished
+}
+
+// NewRunner creates a runner for the given hooks. Unknown hook names are
+// reported and ignored.
+func NewRunner(hooks map[string][]string) (*Runner, error) {
+	runner := &Runner{hooks: make(map[string][]string)}
+	var unknown []string
+	for name, commands := range hooks {
+		if !validHooks[name] {
+			unknown = append(unknown, name)
+			continue
+		}
+		runner.hooks[name] = append([]string{}, commands...)
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return runner, fmt.Errorf("unknown hooks: %s", strings.Join(unknown, ", "))
+	}
+	return runner, nil
+}
+
+// LoadRunner creates a runner for the hooks in config.json
+func LoadRunner() (*Runner, error) {
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return &Runner{hooks: map[string][]string{}}, err
+	}
+	return NewRunner(settings.Hooks)
+}
+
+// Start runs hooks for the manager's events until the returned function is
+// called, which starts the hooks of the events published before it returns
+func (r *Runner) Start(manager *session.Manager) func() {
+	if len(r.hooks) == 0 {
+		return func() {}
+	}
+
+	events, unsubscribe := manager.Subscribe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for event := range events {
+			for _, name := range hookNames(event) {
+				if r.skipStates && stateHooks[name] {
+					continue
+				}
+				r.run(name, event)
+			}
+		}
+	}()
+	return func() {
+		unsubscribe()
+		<-done
+	}
+}
+
+// SkipStateHooks leaves the hooks about agents' states to the agate process
+// that monitors the sessions, so they run once per change. Session and
+// worktree hooks still run.
+func (r *Runner) SkipStateHooks() {
+	r.skipStates = true
+}
+
+// Wait waits for the hook commands that were started to finish, for
+// processes that exit right after changing sessions
+func (r *Runner) Wait() {
+	r.running.Wait()
+}
+
+// WaitTimeout is Wait giving up after timeout. It reports whether the hook
+// commands finished; those still running are left to finish on their own.
+func (r *Runner) WaitTimeout(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		r.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// hookNames returns the hooks an event triggers
+func hookNames(event session.Event) []string {
+	switch event.Type {
+	case session.EventSessionCreated, session.EventSessionDeleted:
+		// The agate process that made the change runs its hooks
+		if event.Elsewhere {
+			return nil
+		}
+		if event.Type == session.EventSessionCreated {
+			return []string{SessionCreated}
+		}
+		return []string{SessionDeleted}
+	case session.EventWorktreeDeleted:
+		return []string{WorktreeDeleted}
+	case session.EventStateChanged:
+		// Unseen activity is announced as a change to the same state
+		if event.State == event.PreviousState {
+			return nil
+		}
+		names := []string{StateChanged}
+		switch {
+		case event.State == session.StateWaitingForInput:
+			names = append(names, WaitingForInput)
+		case event.State == session.StateExited || event.State == session.StateCrashed:
+			names = append(names, AgentExited)
+		}
+		return names
+	}
+	return nil
+}
+
+// newPayload describes an event for a hook
+func newPayload(name string, event session.Event) Payload {
+	payload := Payload{
+		Event:     name,
+		SessionID: event.SessionID,
+		Restarted: event.Restarted,
+		At:        event.At,
+	}
+	if event.Worktree != nil {
+		payload.WorktreePath = event.Worktree.Path
+		payload.Branch = event.Worktree.Branch
+		payload.Repo = event.Worktree.RepoName
+	}
+	if event.Session != nil {
+		payload.Agent = event.Session.AgentName()
+		payload.State = event.State.String()
+	}
+	if event.Type == session.EventStateChanged {
+		payload.PreviousState = event.PreviousState.String()
+	}
+	return payload
+}
+
+// run starts the hook's commands in the background
+func (r *Runner) run(name string, event session.Event) {
+	commands := r.hooks[name]
+	if len(commands) == 0 {
+		return
+	}
+
+	payload := newPayload(name, event)
+	input, err := json.Marshal(payload)
+	if err != nil {
+		debug.DebugLog("Failed to encode %s hook payload: %v", name, err)
+		return
+	}
+
+	for _, command := range commands {
+		r.running.Add(1)
+		go func() {
+			defer r.running.Done()
+			runCommand(name, command, payload, input)
+		}()
+	}
+}
+
+// runCommand runs one hook command with the payload on stdin and in its
+// environment, from the worktree when it still exists
+func runCommand(name, command string, payload Payload, input []byte) {
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Env = append(os.Environ(),
+		"AGATE_EVENT="+payload.Event,
+		"AGATE_SESSION_ID="+payload.SessionID,
+		"AGATE_WORKTREE_PATH="+payload.WorktreePath,
+		"AGATE_BRANCH="+payload.Branch,
+		"AGATE_REPO="+payload.Repo,
+		"AGATE_AGENT="+payload.Agent,
+		"AGATE_STATE="+payload.State,
+	)
+	if info, err := os.Stat(payload.WorktreePath); err == nil && info.IsDir() {
+		cmd.Dir = payload.WorktreePath
+	}
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		debug.DebugLog("Hook %s (%s) failed: %v: %s", name, command, err, strings.TrimSpace(string(output)))
+		return
+	}
+	debug.DebugLog("Hook %s (%s) ran", name, command)
+}
@@ -0,0 +1,69 @@
+package search
+
+import (
+	"regexp"
+	"strings"
+
+	"agate/pkg/tmux"
+)
+
+// MaxLinesPerSource caps the matching lines Grep keeps for each source
+const MaxLinesPerSource = 200
+
+// Source is a session terminal Grep searches
+type Source struct {
+	SessionID string
+	Title     string // How the session is listed
+	Shell     bool   // The session's shell rather than its agent
+	Backend   tmux.Backend
+}
+
+// Line is a line Grep found
+type Line struct {
+	Number int    // Index among the source's captured lines, oldest first
+	Text   string // The line without escape sequences
+	Start  int    // Byte offset of the line's first match in Text
+}
+
+// Result holds the lines of one source that match
+type Result struct {
+	Source
+	Lines []Line // At most MaxLinesPerSource, oldest first
+	Total int    // Matching lines, including those beyond Lines
+}
+
+// Grep captures the history of each source and collects the lines re
+// matches. Sources without matches, or that can't be captured, e.g. because
+// their program stopped, are left out.
+func Grep(re *regexp.Regexp, sources []Source) []Result {
+	var results []Result
+	for _, source := range sources {
+		if source.Backend == nil {
+			continue
+		}
+		lines, err := CaptureHistory(source.Backend)
+		if err != nil {
+			continue
+		}
+
+		result := Result{Source: source}
+		for i, line := range lines {
+			text := strings.TrimRight(tmux.StripANSI(line), " ")
+			loc := matchIndexes(re, text)
+			if len(loc) == 0 {
+				continue
+			}
+			result.Total++
+			result.Lines = append(result.Lines, Line{Number: i, Text: text, Start: loc[0][0]})
+		}
+		if result.Total == 0 {
+			continue
+		}
+		// The newest output is the most likely to matter
+		if len(result.Lines) > MaxLinesPerSource {
+			result.Lines = result.Lines[len(result.Lines)-MaxLinesPerSource:]
+		}
+		results = append(results, result)
+	}
+	return results
+}
@@ -0,0 +1,171 @@
+// Package search finds regular expression matches in terminal output,
+// including the scrollback of agent and shell sessions
+package search
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strings"
+
+	"agate/pkg/tmux"
+)
+
+// Highlight styles. Both reset the line's own attributes so matches stand
+// out whatever the program printed them in.
+const (
+	matchStyle   = "\x1b[0;30;43m"              // Black on yellow
+	currentStyle = "\x1b[0;1;38;5;16;48;5;208m" // Bold black on orange
+	resetStyle   = "\x1b[0m"
+)
+
+// Match is a match of a pattern in captured output
+type Match struct {
+	Line  int // Index of the line among the captured lines
+	Start int // Byte offset of the match in the line without escape sequences
+	End   int
+}
+
+// Compile compiles a search pattern. Patterns without upper-case letters
+// match case-insensitively.
+func Compile(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, errors.New("empty pattern")
+	}
+	if !hasUpper(pattern) {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// hasUpper reports whether a pattern has upper-case letters outside escapes
+// such as \S or \W
+func hasUpper(pattern string) bool {
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case strings.ToLower(string(r)) != string(r):
+			return true
+		}
+	}
+	return false
+}
+
+// SplitLines splits captured content into lines, dropping the blank lines
+// below the last output
+func SplitLines(content string) []string {
+	lines := strings.Split(content, "\n")
+	for len(lines) > 0 && strings.TrimSpace(tmux.StripANSI(lines[len(lines)-1])) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// FindAll returns the non-empty matches of re in lines, in order
+func FindAll(re *regexp.Regexp, lines []string) []Match {
+	var matches []Match
+	for i, line := range lines {
+		for _, loc := range matchIndexes(re, tmux.StripANSI(line)) {
+			matches = append(matches, Match{Line: i, Start: loc[0], End: loc[1]})
+		}
+	}
+	return matches
+}
+
+// matchIndexes returns the byte ranges of the non-empty matches of re in text
+func matchIndexes(re *regexp.Regexp, text string) [][]int {
+	var indexes [][]int
+	for _, loc := range re.FindAllStringIndex(text, -1) {
+		if loc[1] > loc[0] {
+			indexes = append(indexes, loc)
+		}
+	}
+	return indexes
+}
+
+// CaptureHistory captures a session's scrollback and visible screen as
+// lines, oldest first
+func CaptureHistory(backend tmux.Backend) ([]string, error) {
+	history, err := backend.HistorySize()
+	if err != nil {
+		return nil, err
+	}
+	// Both backends stop at the bottom of the visible screen
+	content, err := backend.CapturePaneContentWithOptions(-history, math.MaxInt16)
+	if err != nil {
+		return nil, err
+	}
+	return SplitLines(content), nil
+}
+
+// Highlight marks the matches of re in a captured line, keeping its escape
+// sequences. The match starting at byte offset current of the line without
+// escape sequences is marked as the current one; -1 marks none.
+func Highlight(line string, re *regexp.Regexp, current int) string {
+	spans := matchIndexes(re, tmux.StripANSI(line))
+	if len(spans) == 0 {
+		return line
+	}
+
+	var b strings.Builder
+	var state strings.Builder // SGR sequences in effect, restored after each match
+	sequences := tmux.ANSISequenceIndexes(line)
+	style := "" // Style of the match being written, empty between matches
+	pos := 0    // Offset in the line without escape sequences
+	for i := 0; ; {
+		if style != "" && pos == spans[0][1] {
+			b.WriteString(resetStyle + state.String())
+			style = ""
+			spans = spans[1:]
+		}
+		if style == "" && len(spans) > 0 && pos == spans[0][0] {
+			style = matchStyle
+			if pos == current {
+				style = currentStyle
+			}
+			b.WriteString(style)
+		}
+		if i == len(line) {
+			break
+		}
+
+		if len(sequences) > 0 && sequences[0][0] == i {
+			sequence := line[i:sequences[0][1]]
+			b.WriteString(sequence)
+			if isSGR(sequence) {
+				if resetsSGR(sequence) {
+					state.Reset()
+				}
+				state.WriteString(sequence)
+				// Keep the match highlighted through the line's own styling
+				b.WriteString(style)
+			}
+			i = sequences[0][1]
+			sequences = sequences[1:]
+			continue
+		}
+		b.WriteByte(line[i])
+		i++
+		pos++
+	}
+	if style != "" {
+		b.WriteString(resetStyle + state.String())
+	}
+	return b.String()
+}
+
+// isSGR reports whether an escape sequence sets graphic attributes
+func isSGR(sequence string) bool {
+	return strings.HasPrefix(sequence, "\x1b[") && strings.HasSuffix(sequence, "m")
+}
+
+// resetsSGR reports whether an SGR sequence starts by resetting all attributes
+func resetsSGR(sequence string) bool {
+	params := strings.TrimSuffix(strings.TrimPrefix(sequence, "\x1b["), "m")
+	first, _, _ := strings.Cut(params, ";")
+	return first == "" || first == "0"
+}
@@ -0,0 +1,123 @@
+package session
+
+import (
+	"time"
+
+	"agate/internal/debug"
+	"agate/pkg/git"
+)
+
+// EventType identifies what happened to a session
+type EventType int
+
+const (
+	// EventSessionCreated is published when a session is added to the manager
+	EventSessionCreated EventType = iota
+	// EventSessionDeleted is published when a session is removed from the manager
+	EventSessionDeleted
+	// EventSessionActivated is published when a different session becomes active
+	EventSessionActivated
+	// EventStateChanged is published when a session's lifecycle state or
+	// unseen activity flag changes
+	EventStateChanged
+	// EventWorktreeDeleted is published when a linked worktree is deleted,
+	// after the deletion of the session that used it, if any
+	EventWorktreeDeleted
+	// EventQueueChanged is published when a session's prompt queue changes
+	EventQueueChanged
+)
+
+// eventBufferSize is how many events a subscriber can fall behind before
+// further events are dropped for it
+const eventBufferSize = 64
+
+// String returns the event's name
+func (t EventType) String() string {
+	switch t {
+	case EventSessionCreated:
+		return "session_created"
+	case EventSessionDeleted:
+		return "session_deleted"
+	case EventSessionActivated:
+		return "session_activated"
+	case EventStateChanged:
+		return "state_changed"
+	case EventWorktreeDeleted:
+		return "worktree_deleted"
+	case EventQueueChanged:
+		return "queue_changed"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes a change to a session
+type Event struct {
+	Type          EventType
+	SessionID     string
+	Session       *Session          // Nil for sessions re-keyed by RestartSession and worktree-only events
+	Worktree      *git.WorktreeInfo // Worktree of the session, or the deleted worktree
+	State         State             // State after the change
+	PreviousState State             // State before the change, for EventStateChanged
+	Restarted     bool              // Deleted and created events of a session re-keyed by RestartSession
+	Elsewhere     bool              // Created and deleted events for changes another agate process made
+	At            time.Time
+}
+
+// Subscribe returns a channel receiving every session event published from
+// now on, and a function that ends the subscription. Subscribers must keep
+// reading; events are dropped for subscribers that fall too far behind.
+func (m *Manager) Subscribe() (<-chan Event, func()) {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+
+	if m.subscribers == nil {
+		m.subscribers = make(map[int]chan Event)
+	}
+	id := m.nextSubscriber
+	m.nextSubscriber++
+	ch := make(chan Event, eventBufferSize)
+	m.subscribers[id] = ch
+
+	unsubscribe := func() {
+		m.subMu.Lock()
+		defer m.subMu.Unlock()
+		if sub, ok := m.subscribers[id]; ok {
+			delete(m.subscribers, id)
+			close(sub)
+		}
+	}
+	return ch, unsubscribe
+}
+
+// publish announces a change to a session whose state was previous
+func (m *Manager) publish(eventType EventType, session *Session, previous State) {
+	m.publishEvent(newEvent(eventType, session, previous))
+}
+
+// newEvent describes a change to a session whose state was previous
+func newEvent(eventType EventType, session *Session, previous State) Event {
+	return Event{
+		Type:          eventType,
+		SessionID:     session.GetID(),
+		Session:       session,
+		Worktree:      session.Worktree,
+		State:         session.GetState(),
+		PreviousState: previous,
+		At:            time.Now(),
+	}
+}
+
+// publishEvent delivers an event to every subscriber without blocking. It
+// must not be called with m.mu held.
+func (m *Manager) publishEvent(event Event) {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	for id, ch := range m.subscribers {
+		select {
+		case ch <- event:
+		default:
+			debug.DebugLog("Dropping %s event for slow subscriber %d", event.Type, id)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package session
+
+import (
+	"strings"
+	"time"
+
+	"agate/pkg/tmux"
+)
+
+// State is the lifecycle state of a session's agent
+type State int
+
+const (
+	// StateStarting means the agent was launched but hasn't drawn anything yet
+	StateStarting State = iota
+	// StateWorking means the agent is busy
+	StateWorking
+	// StateWaitingForInput means the agent needs the user, e.g. to approve an action
+	StateWaitingForInput
+	// StateIdle means the agent is sitting at its prompt
+	StateIdle
+	// StateErrored means the agent reported an error but is still running
+	StateErrored
+	// StateExited means the agent process exited cleanly
+	StateExited
+	// StateCrashed means the agent process exited with a failure
+	StateCrashed
+	// StateStopped means the session's tmux session is gone, e.g. after a
+	// reboot, and can be resumed
+	StateStopped
+)
+
+// idleAfter is how long unrecognised output must stay unchanged before the
+// agent is considered idle
+const idleAfter = 2 * time.Second
+
+// String returns a short label for the state
+func (s State) String() string {
+	switch s {
+	case StateStarting:
+		return "starting"
+	case StateWorking:
+		return "working"
+	case StateWaitingForInput:
+		return "needs input"
+	case StateIdle:
+		return "idle"
+	case StateErrored:
+		return "error"
+	case StateExited:
+		return "exited"
+	case StateCrashed:
+		return "crashed"
+	case StateStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
+// IsFinal reports whether the agent process is no longer running
+func (s State) IsFinal() bool {
+	return s == StateExited || s == StateCrashed || s == StateStopped
+}
+
+// NeedsAttention reports whether the user should look at the session
+func (s State) NeedsAttention() bool {
+	return s == StateWaitingForInput || s == StateErrored || s == StateCrashed
+}
+
+// Probe is a snapshot of an agent pane used to advance the lifecycle state.
+// Probes are taken off the UI goroutine and applied with Manager.ApplyProbe.
+type Probe struct {
+	Missing    bool            // The tmux session no longer exists
+	Failed     bool            // The pane couldn't be inspected, so the state is left alone
+	StatusOnly bool            // Only the pane's status was checked, not its content
+	Status     tmux.PaneStatus // Whether the pane's program has exited
+	PaneState  tmux.PaneState  // State detected from the pane content
+	Updated    bool            // Content changed since the previous capture
+	Empty      bool            // The pane has no visible output yet
+	At         time.Time
+}
+
+// ProbeTmuxSession inspects a tmux session given content that was just
+// captured from it and whether that content changed
+func ProbeTmuxSession(t tmux.Backend, content string, updated bool) Probe {
+	status := ProbePaneStatus(t)
+	if status.Missing || status.Failed {
+		return status
+	}
+	probe := ProbePaneContent(t, content, updated)
+	probe.Status = status.Status
+	return probe
+}
+
+// ProbePaneContent classifies content just captured from a tmux session
+// without checking whether its program still runs. The preview uses it on
+// every refresh and leaves the status to the monitor's sweeps.
+func ProbePaneContent(t tmux.Backend, content string, updated bool) Probe {
+	probe := Probe{
+		Updated: updated,
+		Empty:   strings.TrimSpace(content) == "",
+		At:      time.Now(),
+	}
+	if t == nil {
+		probe.Missing = true
+		return probe
+	}
+	probe.PaneState = t.Classify(content)
+	return probe
+}
+
+// ProbePaneStatus checks whether a tmux session's program still runs
+func ProbePaneStatus(t tmux.Backend) Probe {
+	probe := Probe{StatusOnly: true, At: time.Now()}
+	if t == nil {
+		probe.Missing = true
+		return probe
+	}
+
+	status, err := t.PaneStatus()
+	if err != nil {
+		if exists, existsErr := t.SessionExists(); existsErr == nil && !exists {
+			probe.Missing = true
+		} else {
+			probe.Failed = true
+		}
+		return probe
+	}
+	probe.Status = status
+	return probe
+}
+
+// GetState returns the session's lifecycle state
+func (s *Session) GetState() State {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.State
+}
+
+// resetState puts the session into a state without regard for its history,
+// e.g. when its agent is relaunched
+func (s *Session) resetState(state State) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.State = state
+	s.StateChangedAt = time.Now()
+	s.Unseen = false
+	s.lastOutputAt = time.Time{}
+}
+
+// applyProbe advances the lifecycle state machine with a new probe and
+// reports whether the state changed
+func (s *Session) applyProbe(probe Probe) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if probe.Updated && !probe.Empty {
+		s.lastOutputAt = probe.At
+	}
+
+	next := nextState(s.State, probe, s.lastOutputAt)
+	if next == s.State {
+		return false
+	}
+
+	s.State = next
+	s.StateChangedAt = probe.At
+	return true
+}
+
+// nextState computes the state that follows current given a probe
+func nextState(current State, probe Probe, lastOutputAt time.Time) State {
+	switch {
+	case probe.Missing:
+		// The tmux session itself is gone, which only resuming can fix
+		if current == StateCrashed {
+			return current
+		}
+		return StateStopped
+	case current.IsFinal(), probe.Failed:
+		// A finished agent only comes back by restarting or resuming it
+		return current
+	case probe.Status.Dead:
+		if probe.Status.ExitStatus == 0 {
+			return StateExited
+		}
+		return StateCrashed
+	case probe.StatusOnly:
+		return current
+	}
+
+	switch probe.PaneState {
+	case tmux.PaneStateWaitingForApproval:
+		return StateWaitingForInput
+	case tmux.PaneStateErrored:
+		return StateErrored
+	case tmux.PaneStateWorking:
+		return StateWorking
+	case tmux.PaneStateIdle:
+		return StateIdle
+	}
+
+	// No rule matched, so fall back to watching for output changes
+	if probe.Empty && current == StateStarting {
+		return StateStarting
+	}
+	if probe.Updated {
+		return StateWorking
+	}
+	if !lastOutputAt.IsZero() && probe.At.Sub(lastOutputAt) < idleAfter {
+		return current
+	}
+	if current == StateStarting || current == StateWorking {
+		return StateIdle
+	}
+	return current
+}
@@ -3,7 +3,9 @@ package session
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"agate/internal/debug"
@@ -12,54 +14,101 @@ import (
 	"agate/pkg/tmux"
 )
 
-// Manager is a singleton that manages all sessions
+// Manager is a singleton that manages all sessions. It is safe for
+// concurrent use; changes are announced to subscribers as Events.
 type Manager struct {
-	sessions      map[string]*Session  // WorktreeKey -> Session
+	mu            sync.RWMutex         // Guards sessions, starting, restarting, activeSession and deliverQueue
+	sessions      map[string]*Session  // Session ID -> Session
+	starting      map[string]bool      // IDs held for sessions whose tmux sessions are starting
+	restarting    map[*Session]bool    // Sessions whose agent is being replaced or resumed
 	activeSession *Session             // Currently active session
 	worktreeMgr   *git.WorktreeManager // Git worktree management
+	deliverQueue  bool                 // Whether this process sends queued prompts
+
+	persistMu sync.Mutex      // Guards persisted, taken after mu
+	persisted map[string]bool // IDs of sessions whose mappings were in the state file
+
+	subMu          sync.Mutex         // Guards subscribers
+	subscribers    map[int]chan Event // Event subscriptions by ID
+	nextSubscriber int
 }
 
 // NewManager creates a new session manager
 func NewManager(worktreeMgr *git.WorktreeManager) *Manager {
 	return &Manager{
 		sessions:    make(map[string]*Session),
+		starting:    make(map[string]bool),
+		restarting:  make(map[*Session]bool),
+		persisted:   make(map[string]bool),
 		worktreeMgr: worktreeMgr,
 	}
 }
 
-// CreateSession creates a new session for the given worktree and agent
+// CreateSession starts a new agent session in the given worktree. Worktrees
+// can host several sessions, including more than one of the same agent.
 func (m *Manager) CreateSession(worktree *git.WorktreeInfo, agentName string) (*Session, error) {
 	if worktree == nil {
 		return nil, fmt.Errorf("worktree cannot be nil")
 	}
 
-	// Get agent configuration for this session
-	agentConfig := app.GetAgentConfig(agentName)
+	m.mu.Lock()
+	sessionID, instance := m.reserveInstance(generateWorktreeKey(worktree), agentName)
+	m.mu.Unlock()
+	return m.startSession(worktree, agentName, sessionID, instance)
+}
 
-	// Generate stable identifiers
-	worktreeKey := generateWorktreeKey(worktree)
-	sessionName := generateTmuxSessionName(worktree, agentName)
+// reserveInstance picks the ID and instance number of a new session of the
+// agent in the worktree, and holds them while its tmux sessions start; m.mu
+// must be held
+func (m *Manager) reserveInstance(worktreeKey, agentName string) (string, int) {
+	instance := m.nextInstance(worktreeKey, agentName, "")
+	sessionID := generateSessionID(worktreeKey, agentName, instance)
+	m.starting[sessionID] = true
+	return sessionID, instance
+}
 
-	// Check if session already exists
-	if existing, exists := m.sessions[worktreeKey]; exists {
-		debug.DebugLog("Session already exists for worktree key: %s", worktreeKey)
-		return existing, nil
+// startSession launches the agent and shell of a session whose ID was
+// reserved and adds the session. tmux is started without m.mu held, as it
+// can take seconds.
+func (m *Manager) startSession(worktree *git.WorktreeInfo, agentName, sessionID string, instance int) (*Session, error) {
+	session, err := newSession(worktree, agentName, sessionID, instance)
+
+	m.mu.Lock()
+	delete(m.starting, sessionID)
+	if err != nil {
+		m.mu.Unlock()
+		return nil, err
+	}
+	m.sessions[sessionID] = session
+
+	// Persist session to config
+	if err := m.persistSessions(); err != nil {
+		debug.DebugLog("Failed to persist session %s: %v", sessionID, err)
+		// Don't fail session creation if persistence fails
 	}
+	m.mu.Unlock()
 
-	// Create tmux session
-	tmuxSession := tmux.NewTmuxSession(sessionName, agentName)
-	err := tmuxSession.Start(worktree.Path)
+	debug.DebugLog("Created new session: %s for worktree: %s with agent: %s",
+		sessionID, worktree.Path, agentName)
+	m.publish(EventSessionCreated, session, session.GetState())
+	return session, nil
+}
+
+// newSession starts the tmux sessions of a new session
+func newSession(worktree *git.WorktreeInfo, agentName, sessionID string, instance int) (*Session, error) {
+	// Get agent configuration for this session
+	agentConfig := app.GetAgentConfig(agentName)
+	sessionName := generateTmuxSessionName(worktree, instanceName(agentName, instance))
+
+	// Create tmux session running the agent's executable with its launch options
+	tmuxSession, err := startAgentTmuxSession(worktree, agentConfig, agentName, sessionName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to start tmux session: %w", err)
+		return nil, err
 	}
 
 	// Create shell tmux session with user's preferred shell
-	shell := os.Getenv("SHELL")
-	if shell == "" {
-		shell = "/bin/bash"
-	}
 	shellSessionName := "shell_" + sessionName
-	shellTmuxSession := tmux.NewTmuxSession(shellSessionName, shell)
+	shellTmuxSession := tmux.NewBackend(shellSessionName, defaultShell())
 	err = shellTmuxSession.Start(worktree.Path)
 	if err != nil {
 		// Clean up agent tmux session if shell session fails
@@ -67,63 +116,248 @@ func (m *Manager) CreateSession(worktree *git.WorktreeInfo, agentName string) (*
 		return nil, fmt.Errorf("failed to start shell tmux session: %w", err)
 	}
 
-	// Create session
-	session := &Session{
-		ID:               worktreeKey + "_" + agentName,
-		Name:             sessionName,
-		WorktreeKey:      worktreeKey,
-		TmuxSession:      tmuxSession,
+	return &Session{
+		id:               sessionID,
+		name:             sessionName,
+		WorktreeKey:      generateWorktreeKey(worktree),
+		instance:         instance,
+		tmuxSession:      tmuxSession,
 		ShellTmuxSession: shellTmuxSession,
 		Worktree:         worktree,
-		Agent:            agentConfig,
+		agent:            agentConfig,
 		CreatedAt:        time.Now(),
 		LastAccessed:     time.Now(),
 		IsActive:         false,
+		State:            StateStarting,
+		StateChangedAt:   time.Now(),
+	}, nil
+}
+
+// defaultShell returns the user's preferred shell for shell sessions
+func defaultShell() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
 	}
+	return "/bin/bash"
+}
 
-	// Store session
-	m.sessions[worktreeKey] = session
+// startAgentTmuxSession launches the agent in a new tmux session in the worktree
+func startAgentTmuxSession(worktree *git.WorktreeInfo, agentConfig app.AgentConfig, agentName, sessionName string) (tmux.Backend, error) {
+	tmuxSession := tmux.NewBackend(sessionName, agentConfig.LaunchCommand(agentName))
+	tmuxSession.SetArgs(agentConfig.Args)
+	tmuxSession.SetEnv(agentConfig.Env)
+	tmuxSession.SetRemainOnExit(true)
+	if err := tmuxSession.SetDetectionRules(agentConfig.Detection); err != nil {
+		debug.DebugLog("Invalid detection rules for agent %s: %v", agentConfig.Name, err)
+	}
+	if err := tmuxSession.Start(agentConfig.ResolveWorkDir(worktree.Path)); err != nil {
+		return nil, fmt.Errorf("failed to start tmux session: %w", err)
+	}
+	return tmuxSession, nil
+}
 
-	// Persist session to config
-	if err := m.PersistSessions(); err != nil {
-		debug.DebugLog("Failed to persist session %s: %v", session.ID, err)
-		// Don't fail session creation if persistence fails
+// RestartSession replaces the session's agent with a fresh instance of the
+// given agent. The worktree and shell session are kept; the session is
+// re-keyed because its ID and tmux name include the agent name.
+func (m *Manager) RestartSession(sessionID, agentName string) (*Session, error) {
+	m.mu.Lock()
+	session, exists := m.sessions[sessionID]
+	if !exists {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+	if session.Worktree == nil {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("session %s has no worktree", sessionID)
 	}
 
-	debug.DebugLog("Created new session: %s for worktree: %s with agent: %s",
-		session.ID, worktree.Path, agentName)
+	if m.restarting[session] {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("session %s is already restarting", sessionID)
+	}
+
+	// The session's own slot is free, so restarting with the same agent keeps its number
+	instance := m.nextInstance(session.WorktreeKey, agentName, sessionID)
+	newID := generateSessionID(session.WorktreeKey, agentName, instance)
+	m.starting[newID] = true
+	m.restarting[session] = true
+	m.mu.Unlock()
+
+	previous := session.GetState()
+	agentConfig := app.GetAgentConfig(agentName)
+	sessionName := generateTmuxSessionName(session.Worktree, instanceName(agentName, instance))
+
+	// The old agent has to go first in case the new tmux session reuses its name
+	if oldTmuxSession := session.GetTmuxSession(); oldTmuxSession != nil {
+		if err := oldTmuxSession.Kill(); err != nil {
+			debug.DebugLog("Failed to kill tmux session: %v", err)
+		}
+	}
+	tmuxSession, err := startAgentTmuxSession(session.Worktree, agentConfig, agentName, sessionName)
+
+	m.mu.Lock()
+	delete(m.starting, newID)
+	delete(m.restarting, session)
+	if err != nil {
+		tracked := m.sessions[sessionID] == session
+		m.mu.Unlock()
+		if tracked {
+			// The old agent is gone, so the session can only be resumed
+			session.resetState(StateStopped)
+			m.publish(EventStateChanged, session, previous)
+		}
+		return nil, err
+	}
+	if m.sessions[sessionID] != session {
+		m.mu.Unlock()
+		_ = tmuxSession.Kill()
+		return nil, fmt.Errorf("session %s was deleted while restarting", sessionID)
+	}
 
+	delete(m.sessions, sessionID)
+	session.replaceAgent(newID, sessionName, instance, tmuxSession, agentConfig)
+	session.resetState(StateStarting)
+	m.sessions[newID] = session
+
+	var removed []string
+	if newID != sessionID {
+		removed = append(removed, sessionID)
+	}
+	if err := m.persistSessions(removed...); err != nil {
+		debug.DebugLog("Failed to persist session %s: %v", newID, err)
+	}
+	m.mu.Unlock()
+
+	debug.DebugLog("Restarted session %s as %s with agent: %s", sessionID, newID, agentName)
+	if newID != sessionID {
+		// Subscribers know sessions by ID, so a re-keyed session is announced as a new one
+		m.publishEvent(Event{
+			Type:          EventSessionDeleted,
+			SessionID:     sessionID,
+			Worktree:      session.Worktree,
+			State:         previous,
+			PreviousState: previous,
+			Restarted:     true,
+			At:            time.Now(),
+		})
+		created := newEvent(EventSessionCreated, session, previous)
+		created.Restarted = true
+		m.publishEvent(created)
+	} else {
+		m.publish(EventStateChanged, session, previous)
+	}
 	return session, nil
 }
 
-// GetOrCreateSession returns existing session or creates a new one
+// ResumeSession relaunches a stopped session's agent and shell in its
+// worktree with the agent and options it was persisted with. Sessions whose
+// tmux session is still running are returned unchanged.
+func (m *Manager) ResumeSession(sessionID string) (*Session, error) {
+	session := m.GetSession(sessionID)
+	if session == nil {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+	tmuxSession := session.GetTmuxSession()
+	if session.Worktree == nil || tmuxSession == nil {
+		return nil, fmt.Errorf("session %s cannot be resumed", sessionID)
+	}
+	if exists, err := tmuxSession.SessionExists(); err == nil && exists {
+		// Still running, nothing to resume
+		return session, nil
+	}
+	if _, err := os.Stat(session.Worktree.Path); err != nil {
+		return nil, fmt.Errorf("worktree %s is no longer available: %w", session.Worktree.Path, err)
+	}
+
+	m.mu.Lock()
+	if m.restarting[session] {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("session %s is already restarting", sessionID)
+	}
+	m.restarting[session] = true
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.restarting, session)
+		m.mu.Unlock()
+	}()
+
+	// Sessions started on another tmux server by older versions move to the current one
+	setServer(tmuxSession, tmux.CurrentServer())
+	if err := tmuxSession.Start(session.GetAgent().ResolveWorkDir(session.Worktree.Path)); err != nil {
+		return nil, fmt.Errorf("failed to start tmux session: %w", err)
+	}
+
+	if session.ShellTmuxSession != nil {
+		if exists, err := session.ShellTmuxSession.SessionExists(); err != nil || !exists {
+			setServer(session.ShellTmuxSession, tmux.CurrentServer())
+		}
+		if err := session.ShellTmuxSession.Start(session.Worktree.Path); err != nil {
+			debug.DebugLog("Failed to start shell session for %s: %v", session.GetID(), err)
+		}
+	}
+
+	previous := session.GetState()
+	session.resetState(StateStarting)
+
+	// Record the server the session now runs on
+	if err := m.PersistSessions(); err != nil {
+		debug.DebugLog("Failed to persist resumed session %s: %v", session.GetID(), err)
+	}
+
+	debug.DebugLog("Resumed session: %s", session.GetID())
+	m.publish(EventStateChanged, session, previous)
+	return session, nil
+}
+
+// nextInstance returns the lowest instance number not yet used or held by a
+// session of the agent in the worktree, other than the session with ID
+// except; m.mu must be held
+func (m *Manager) nextInstance(worktreeKey, agentName, except string) int {
+	instance := 1
+	for {
+		sessionID := generateSessionID(worktreeKey, agentName, instance)
+		if _, exists := m.sessions[sessionID]; (!exists || sessionID == except) && !m.starting[sessionID] {
+			return instance
+		}
+		instance++
+	}
+}
+
+// GetOrCreateSession returns the worktree's preferred session, creating one
+// with the given agent if the worktree has none yet
 func (m *Manager) GetOrCreateSession(worktree *git.WorktreeInfo, agentName string) (*Session, error) {
 	if worktree == nil {
 		return nil, fmt.Errorf("worktree cannot be nil")
 	}
 
-	worktreeKey := generateWorktreeKey(worktree)
-
+	m.mu.Lock()
 	// Check if session exists
-	if session, exists := m.sessions[worktreeKey]; exists {
+	if session := m.sessionForWorktree(worktree); session != nil {
+		m.mu.Unlock()
 		// Update access time
 		session.Update()
-		debug.DebugLog("Reusing existing session: %s", session.ID)
+		debug.DebugLog("Reusing existing session: %s", session.GetID())
 		return session, nil
 	}
 
 	// Create new session
-	return m.CreateSession(worktree, agentName)
+	sessionID, instance := m.reserveInstance(generateWorktreeKey(worktree), agentName)
+	m.mu.Unlock()
+	return m.startSession(worktree, agentName, sessionID, instance)
 }
 
 // SwitchToSession activates the specified session
-func (m *Manager) SwitchToSession(worktreeKey string) (*Session, error) {
-	session, exists := m.sessions[worktreeKey]
+func (m *Manager) SwitchToSession(sessionID string) (*Session, error) {
+	m.mu.Lock()
+	session, exists := m.sessions[sessionID]
 	if !exists {
-		return nil, fmt.Errorf("session not found for worktree key: %s", worktreeKey)
+		m.mu.Unlock()
+		return nil, fmt.Errorf("session not found: %s", sessionID)
 	}
 
 	// Deactivate current session
+	changed := m.activeSession != session
 	if m.activeSession != nil {
 		m.activeSession.Deactivate()
 	}
@@ -133,42 +367,198 @@ func (m *Manager) SwitchToSession(worktreeKey string) (*Session, error) {
 	m.activeSession = session
 
 	// Persist active session change
-	if err := m.PersistSessions(); err != nil {
+	if err := m.persistSessions(); err != nil {
 		debug.DebugLog("Failed to persist active session change: %v", err)
 		// Don't fail session switch if persistence fails
 	}
+	m.mu.Unlock()
 
-	debug.DebugLog("Switched to session: %s", session.ID)
+	debug.DebugLog("Switched to session: %s", session.GetID())
+	if changed {
+		m.publish(EventSessionActivated, session, session.GetState())
+	}
 	return session, nil
 }
 
 // GetActiveSession returns the currently active session
 func (m *Manager) GetActiveSession() *Session {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	return m.activeSession
 }
 
-// GetSessionForWorktree returns the session associated with the given worktree
+// GetSession returns the session with the given ID
+func (m *Manager) GetSession(sessionID string) *Session {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.sessions[sessionID]
+}
+
+// GetSessionForWorktree returns the worktree's preferred session: the active
+// session if it belongs to the worktree, otherwise the most recently used one
 func (m *Manager) GetSessionForWorktree(worktree *git.WorktreeInfo) *Session {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.sessionForWorktree(worktree)
+}
+
+// sessionForWorktree does the work of GetSessionForWorktree; m.mu must be held
+func (m *Manager) sessionForWorktree(worktree *git.WorktreeInfo) *Session {
+	sessions := m.sessionsForWorktree(worktree)
+	if len(sessions) == 0 {
+		return nil
+	}
+
+	preferred := sessions[0]
+	for _, session := range sessions {
+		if session == m.activeSession {
+			return session
+		}
+		if session.GetLastAccessed().After(preferred.GetLastAccessed()) {
+			preferred = session
+		}
+	}
+	return preferred
+}
+
+// GetSessionsForWorktree returns every session in the worktree, oldest first
+func (m *Manager) GetSessionsForWorktree(worktree *git.WorktreeInfo) []*Session {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.sessionsForWorktree(worktree)
+}
+
+// sessionsForWorktree does the work of GetSessionsForWorktree; m.mu must be held
+func (m *Manager) sessionsForWorktree(worktree *git.WorktreeInfo) []*Session {
 	if worktree == nil {
 		return nil
 	}
 
 	worktreeKey := generateWorktreeKey(worktree)
-	return m.sessions[worktreeKey]
+	sessions := make([]*Session, 0)
+	for _, session := range m.sessions {
+		if session.WorktreeKey == worktreeKey {
+			sessions = append(sessions, session)
+		}
+	}
+	sortSessions(sessions)
+	return sessions
+}
+
+// GetSessionByTmuxName returns the session whose agent runs in the named tmux session
+func (m *Manager) GetSessionByTmuxName(name string) *Session {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, session := range m.sessions {
+		if tmuxSession := session.GetTmuxSession(); tmuxSession != nil && tmuxSession.GetSessionName() == name {
+			return session
+		}
+	}
+	return nil
 }
 
-// DeleteSession removes and cleans up a session
-func (m *Manager) DeleteSession(worktreeKey string) error {
-	session, exists := m.sessions[worktreeKey]
+// FindSession resolves a session given its ID, its tmux session name, or its
+// branch optionally followed by "/<agent>", with "-<n>" picking later instances
+func (m *Manager) FindSession(ref string) (*Session, error) {
+	if session := m.GetSession(ref); session != nil {
+		return session, nil
+	}
+	if session := m.GetSessionByTmuxName(ref); session != nil {
+		return session, nil
+	}
+
+	sessions := m.ListSessions()
+	branch, agentRef, byAgent := strings.Cut(ref, "/")
+	var matches []*Session
+	for _, session := range sessions {
+		if session.Worktree.Branch != branch {
+			continue
+		}
+		if byAgent && session.InstanceRef() != agentRef {
+			continue
+		}
+		matches = append(matches, session)
+	}
+	// Branch names may contain slashes themselves
+	if len(matches) == 0 && byAgent {
+		for _, session := range sessions {
+			if session.Worktree.Branch == ref {
+				matches = append(matches, session)
+			}
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no session matches %q", ref)
+	case 1:
+		return matches[0], nil
+	default:
+		names := make([]string, 0, len(matches))
+		for _, session := range matches {
+			names = append(names, session.GetTmuxSessionName())
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("%q matches several sessions, use one of: %s", ref, strings.Join(names, ", "))
+	}
+}
+
+// ApplyProbe feeds a probe of the named agent tmux session into its
+// session's lifecycle state machine. It returns the session, or nil if none
+// matches, and whether its state changed.
+func (m *Manager) ApplyProbe(tmuxName string, probe Probe) (*Session, bool) {
+	session := m.GetSessionByTmuxName(tmuxName)
+	if session == nil {
+		return nil, false
+	}
+
+	previous := session.GetState()
+	if !session.applyProbe(probe) {
+		return session, false
+	}
+	m.publish(EventStateChanged, session, previous)
+	m.deliverQueued(session)
+	return session, true
+}
+
+// MarkUnseen flags a background session as having activity the user hasn't
+// looked at and reports whether the flag changed
+func (m *Manager) MarkUnseen(session *Session) bool {
+	if !session.markUnseen() {
+		return false
+	}
+	state := session.GetState()
+	m.publish(EventStateChanged, session, state)
+	return true
+}
+
+// DeleteSession removes and cleans up a session. With removeWorktree, a
+// linked worktree and its branch are deleted along with its last session.
+func (m *Manager) DeleteSession(sessionID string, removeWorktree bool) error {
+	m.mu.Lock()
+	session, exists := m.sessions[sessionID]
 	if !exists {
-		return fmt.Errorf("session not found for worktree key: %s", worktreeKey)
+		m.mu.Unlock()
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	debug.DebugLog("Deleting session: %s", session.GetID())
+
+	// Remove from sessions map, noting whether it was the worktree's last session
+	delete(m.sessions, sessionID)
+	deleteWorktree := removeWorktree && m.worktreeMgr != nil && session.Worktree != nil && m.isLinkedWorktree(session) &&
+		len(m.sessionsForWorktree(session.Worktree)) == 0
+
+	// If this was the active session, clear it
+	if m.activeSession == session {
+		m.activeSession = nil
 	}
 
-	debug.DebugLog("Deleting session: %s", session.ID)
+	m.mu.Unlock()
 
 	// Kill tmux session
-	if session.TmuxSession != nil {
-		if err := session.TmuxSession.Kill(); err != nil {
+	if tmuxSession := session.GetTmuxSession(); tmuxSession != nil {
+		if err := tmuxSession.Kill(); err != nil {
 			debug.DebugLog("Failed to kill tmux session: %v", err)
 			// Continue with deletion even if tmux kill fails
 		}
@@ -182,36 +572,52 @@ func (m *Manager) DeleteSession(worktreeKey string) error {
 		}
 	}
 
-	// Delete worktree if we have a worktree manager
-	if m.worktreeMgr != nil && session.Worktree != nil {
+	// Other agate processes keep sessions whose tmux session still runs, so
+	// the mapping goes once it is killed
+	m.mu.RLock()
+	if err := m.persistSessions(sessionID); err != nil {
+		debug.DebugLog("Failed to persist sessions after deletion: %v", err)
+		// Don't fail deletion if persistence fails
+	}
+	m.mu.RUnlock()
+
+	// Delete the worktree once no other session uses it
+	worktreeDeleted := false
+	if deleteWorktree {
 		if err := m.worktreeMgr.DeleteWorktree(*session.Worktree); err != nil {
 			debug.DebugLog("Failed to delete worktree %s: %v", session.Worktree.Path, err)
 			// Continue with session cleanup even if worktree deletion fails
 		} else {
 			debug.DebugLog("Successfully deleted worktree: %s", session.Worktree.Path)
+			worktreeDeleted = true
 		}
 	}
 
-	// Remove from sessions map
-	delete(m.sessions, worktreeKey)
-
-	// If this was the active session, clear it
-	if m.activeSession == session {
-		m.activeSession = nil
+	debug.DebugLog("Successfully deleted session: %s", session.GetID())
+	m.publish(EventSessionDeleted, session, session.GetState())
+	if worktreeDeleted {
+		m.publish(EventWorktreeDeleted, session, session.GetState())
 	}
+	return nil
+}
 
-	// Persist changes to config
-	if err := m.PersistSessions(); err != nil {
-		debug.DebugLog("Failed to persist sessions after deletion: %v", err)
-		// Don't fail deletion if persistence fails
+// DeleteWorktree deletes a linked worktree and its branch
+func (m *Manager) DeleteWorktree(worktree *git.WorktreeInfo) error {
+	if m.worktreeMgr == nil || worktree == nil {
+		return fmt.Errorf("worktree manager or worktree not available")
+	}
+	if err := m.worktreeMgr.DeleteWorktree(*worktree); err != nil {
+		return err
 	}
 
-	debug.DebugLog("Successfully deleted session: %s", session.ID)
+	m.publishEvent(Event{Type: EventWorktreeDeleted, Worktree: worktree, At: time.Now()})
 	return nil
 }
 
 // ListSessions returns all sessions (both main and linked worktrees)
 func (m *Manager) ListSessions() []*Session {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	sessions := make([]*Session, 0, len(m.sessions))
 	for _, session := range m.sessions {
 		if session.Worktree != nil {
@@ -221,20 +627,42 @@ func (m *Manager) ListSessions() []*Session {
 	return sessions
 }
 
-// GetMainSession returns the main worktree session for a repository
-func (m *Manager) GetMainSession(repoName string) *Session {
+// GetMainSessions returns the main worktree sessions for a repository, oldest first
+func (m *Manager) GetMainSessions(repoName string) []*Session {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.mainSessions(repoName)
+}
+
+// mainSessions does the work of GetMainSessions; m.mu must be held
+func (m *Manager) mainSessions(repoName string) []*Session {
+	sessions := make([]*Session, 0)
 	for _, session := range m.sessions {
 		if session.Worktree != nil &&
 			session.Worktree.RepoName == repoName &&
 			!m.isLinkedWorktree(session) {
-			return session
+			sessions = append(sessions, session)
 		}
 	}
-	return nil
+	sortSessions(sessions)
+	return sessions
+}
+
+// GetMainSession returns the preferred main worktree session for a repository
+func (m *Manager) GetMainSession(repoName string) *Session {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	sessions := m.mainSessions(repoName)
+	if len(sessions) == 0 {
+		return nil
+	}
+	return m.sessionForWorktree(sessions[0].Worktree)
 }
 
 // GetLinkedSessions returns all linked worktree sessions for a repository
 func (m *Manager) GetLinkedSessions(repoName string) []*Session {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	sessions := make([]*Session, 0)
 	for _, session := range m.sessions {
 		if session.Worktree != nil &&
@@ -243,6 +671,7 @@ func (m *Manager) GetLinkedSessions(repoName string) []*Session {
 			sessions = append(sessions, session)
 		}
 	}
+	sortSessions(sessions)
 	return sessions
 }
 
@@ -261,23 +690,106 @@ func (m *Manager) RestoreSessions() error {
 	return m.LoadSessions()
 }
 
-// CleanupOrphanedSessions removes sessions for tmux sessions that no longer exist
+// CleanupOrphanedSessions marks sessions whose agent tmux session no longer
+// exists as stopped, and restarts shells that vanished under sessions that
+// are still running. Sessions are only dropped by an explicit delete.
 func (m *Manager) CleanupOrphanedSessions() {
-	for worktreeKey, session := range m.sessions {
-		if session.TmuxSession != nil {
+	for _, session := range m.ListSessions() {
+		if session.GetState() == StateStopped {
+			continue
+		}
+		if tmuxSession := session.GetTmuxSession(); tmuxSession != nil {
 			// Check if tmux session still exists
-			exists, err := session.TmuxSession.SessionExists()
-			if err != nil || !exists {
-				debug.DebugLog("Removing orphaned session: %s", session.ID)
-				delete(m.sessions, worktreeKey)
-				if m.activeSession == session {
-					m.activeSession = nil
+			exists, err := tmuxSession.SessionExists()
+			if err == nil && !exists {
+				debug.DebugLog("Marking orphaned session stopped: %s", session.GetID())
+				previous := session.GetState()
+				session.resetState(StateStopped)
+				m.publish(EventStateChanged, session, previous)
+				continue
+			}
+		}
+
+		if session.ShellTmuxSession != nil && session.Worktree != nil {
+			if exists, err := session.ShellTmuxSession.SessionExists(); err == nil && !exists {
+				debug.DebugLog("Restarting shell session for: %s", session.GetID())
+				if err := session.ShellTmuxSession.Start(session.Worktree.Path); err != nil {
+					debug.DebugLog("Failed to restart shell session: %v", err)
 				}
 			}
 		}
 	}
 }
 
+// ProbeSessions probes every running session's agent once, bringing their
+// states up to date without the background monitor
+func (m *Manager) ProbeSessions() {
+	for _, session := range m.ListSessions() {
+		tmuxSession := session.GetTmuxSession()
+		if tmuxSession == nil || session.GetState().IsFinal() {
+			continue
+		}
+		content, err := tmuxSession.CapturePaneContent()
+		if err != nil {
+			content = ""
+		}
+		m.ApplyProbe(tmuxSession.GetSessionName(), ProbeTmuxSession(tmuxSession, content, false))
+	}
+}
+
+// untrackedSessionPrefixes start the names of agate's agent and shell tmux
+// sessions, including shells of older versions
+var untrackedSessionPrefixes = []string{"agate_", "shell_agate_"}
+
+// KillUntrackedTmuxSessions kills agate's agent and shell tmux sessions that
+// no session refers to, e.g. ones left behind by a crash, and returns their
+// names. Sessions left on the user's default tmux server by older versions
+// are only included with includeDefault, as others may run there.
+func (m *Manager) KillUntrackedTmuxSessions(includeDefault bool) ([]string, error) {
+	tracked := make(map[string]bool)
+	for _, session := range m.ListSessions() {
+		for _, backend := range []tmux.Backend{session.GetTmuxSession(), session.ShellTmuxSession} {
+			if tmuxSession, ok := backend.(*tmux.TmuxSession); ok {
+				tracked[tmuxSession.Server().SocketFile()+":"+tmuxSession.GetSessionName()] = true
+			}
+		}
+	}
+
+	servers := []tmux.Server{tmux.CurrentServer()}
+	if includeDefault && !tmux.DefaultServer.Same(servers[0]) {
+		servers = append(servers, tmux.DefaultServer)
+	}
+
+	var killed []string
+	for _, server := range servers {
+		names, err := tmux.ListSessionNames(server)
+		if err != nil {
+			return killed, err
+		}
+		for _, name := range names {
+			if tracked[server.SocketFile()+":"+name] || !isAgateSessionName(name) {
+				continue
+			}
+			if err := tmux.KillSession(server, name); err != nil {
+				return killed, fmt.Errorf("failed to kill tmux session %s: %w", name, err)
+			}
+			debug.DebugLog("Killed untracked tmux session: %s", name)
+			killed = append(killed, name)
+		}
+	}
+	return killed, nil
+}
+
+// isAgateSessionName reports whether a tmux session name is one agate gives its sessions
+func isAgateSessionName(name string) bool {
+	for _, prefix := range untrackedSessionPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 // GetWorktreeManager returns the worktree manager
 func (m *Manager) GetWorktreeManager() *git.WorktreeManager {
 	return m.worktreeMgr
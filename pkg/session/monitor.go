@@ -0,0 +1,154 @@
+package session
+
+import (
+	"crypto/sha256"
+	"sort"
+	"sync"
+	"time"
+
+	"agate/pkg/tmux"
+)
+
+const (
+	// defaultMonitorInterval is the delay between background sweeps
+	defaultMonitorInterval = 500 * time.Millisecond
+	// defaultMonitorBudget is the maximum number of sessions probed per sweep
+	defaultMonitorBudget = 4
+)
+
+// Monitor polls the agent panes of background sessions so their lifecycle
+// state stays current while the user is looking at another session. Each
+// sweep probes at most Budget sessions, rotating through all of them.
+//
+// ProbeAll talks to tmux and is meant to run off the UI goroutine. NextBatch
+// and Apply keep the rotation cursor and should be called from one goroutine.
+type Monitor struct {
+	manager       *Manager
+	Interval      time.Duration // Delay between sweeps
+	Budget        int           // Sessions probed per sweep
+	IncludeActive bool          // Probe the active session too, while its preview isn't refreshed
+
+	cursor int                 // Position in the rotation
+	mu     sync.Mutex          // Guards hashes
+	hashes map[string][32]byte // Last content hash per tmux session name
+}
+
+// MonitorResult is the outcome of probing one session
+type MonitorResult struct {
+	SessionName string // tmux session name of the agent
+	Probe       Probe
+}
+
+// NewMonitor creates a background monitor for the manager's sessions
+func NewMonitor(manager *Manager) *Monitor {
+	return &Monitor{
+		manager:  manager,
+		Interval: defaultMonitorInterval,
+		Budget:   defaultMonitorBudget,
+		hashes:   make(map[string][32]byte),
+	}
+}
+
+// NextBatch returns the next tmux sessions to probe. The active session is
+// skipped because the preview loop already polls it, unless IncludeActive is set.
+func (m *Monitor) NextBatch() []tmux.Backend {
+	if m.manager == nil {
+		return nil
+	}
+
+	sessions := m.manager.ListSessions()
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].GetID() < sessions[j].GetID()
+	})
+
+	// Forget the active session's baseline so output the user already saw
+	// isn't reported as unseen once they switch away
+	active := m.manager.GetActiveSession()
+	if active != nil && !m.IncludeActive {
+		if tmuxSession := active.GetTmuxSession(); tmuxSession != nil {
+			m.forget(tmuxSession.GetSessionName())
+		}
+	}
+
+	candidates := make([]tmux.Backend, 0, len(sessions))
+	for _, sess := range sessions {
+		tmuxSession := sess.GetTmuxSession()
+		if tmuxSession == nil || (sess == active && !m.IncludeActive) || sess.GetState().IsFinal() {
+			continue
+		}
+		candidates = append(candidates, tmuxSession)
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	budget := m.Budget
+	if budget <= 0 || budget > len(candidates) {
+		budget = len(candidates)
+	}
+
+	batch := make([]tmux.Backend, 0, budget)
+	for i := 0; i < budget; i++ {
+		batch = append(batch, candidates[(m.cursor+i)%len(candidates)])
+	}
+	m.cursor = (m.cursor + budget) % len(candidates)
+	return batch
+}
+
+// ProbeAll captures each session's pane and probes its state
+func (m *Monitor) ProbeAll(batch []tmux.Backend) []MonitorResult {
+	results := make([]MonitorResult, 0, len(batch))
+	for _, tmuxSession := range batch {
+		name := tmuxSession.GetSessionName()
+
+		content, err := tmuxSession.CapturePaneContent()
+		if err != nil {
+			results = append(results, MonitorResult{SessionName: name, Probe: ProbeTmuxSession(tmuxSession, "", false)})
+			continue
+		}
+
+		// The first capture of a session only establishes a baseline
+		hash := sha256.Sum256([]byte(content))
+		m.mu.Lock()
+		previous, seen := m.hashes[name]
+		m.hashes[name] = hash
+		m.mu.Unlock()
+		updated := seen && previous != hash
+
+		results = append(results, MonitorResult{SessionName: name, Probe: ProbeTmuxSession(tmuxSession, content, updated)})
+	}
+	return results
+}
+
+// Apply feeds probe results into the sessions' state machines and flags
+// background sessions with unseen activity. Changes reach the UI as
+// manager events.
+func (m *Monitor) Apply(results []MonitorResult) {
+	if m.manager == nil {
+		return
+	}
+
+	for _, result := range results {
+		sess, stateChanged := m.manager.ApplyProbe(result.SessionName, result.Probe)
+		if sess == nil {
+			m.forget(result.SessionName)
+			continue
+		}
+
+		// The active session's output is shown once the preview resumes
+		if sess == m.manager.GetActiveSession() {
+			continue
+		}
+		state := sess.GetState()
+		if result.Probe.Updated || (stateChanged && (state.NeedsAttention() || state.IsFinal())) {
+			m.manager.MarkUnseen(sess)
+		}
+	}
+}
+
+// forget drops the content baseline of a tmux session
+func (m *Monitor) forget(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.hashes, name)
+}
@@ -0,0 +1,109 @@
+package session
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"time"
+)
+
+const (
+	// promptPollInterval is how often WaitForPrompt probes the agent
+	promptPollInterval = 250 * time.Millisecond
+	// promptSubmitDelay separates a pasted prompt from the Enter that submits
+	// it; agents treat an Enter arriving with the paste as part of the text
+	promptSubmitDelay = 200 * time.Millisecond
+)
+
+// WaitForPrompt polls a session's agent until it is idle at its input prompt
+// and its pane has stopped changing. It fails if the agent exits or ctx ends first.
+func (m *Manager) WaitForPrompt(ctx context.Context, sessionID string) error {
+	session := m.GetSession(sessionID)
+	if session == nil {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	if session.GetTmuxSession() == nil {
+		return fmt.Errorf("session %s has no agent tmux session", sessionID)
+	}
+
+	// Agents often draw their prompt before they finish starting up, so the
+	// pane also has to stay unchanged for a while
+	var previous [32]byte
+	seen := false
+	changedAt := time.Now()
+
+	ticker := time.NewTicker(promptPollInterval)
+	defer ticker.Stop()
+	for {
+		// Restarting the session replaces its agent's tmux session
+		tmuxSession := session.GetTmuxSession()
+		content, err := tmuxSession.CapturePaneContent()
+		updated := false
+		if err == nil {
+			hash := sha256.Sum256([]byte(content))
+			updated = seen && hash != previous
+			if updated || !seen {
+				changedAt = time.Now()
+			}
+			previous, seen = hash, true
+		}
+		m.ApplyProbe(tmuxSession.GetSessionName(), ProbeTmuxSession(tmuxSession, content, updated))
+
+		state := session.GetState()
+		if state == StateIdle && time.Since(changedAt) >= idleAfter {
+			return nil
+		}
+		if state.IsFinal() {
+			return fmt.Errorf("agent %s before reaching its prompt", state)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("agent is still %s: %w", state, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverPrompt submits text to a session's agent, first waiting for it to
+// reach its prompt when it is busy or asking for input. Prompts for sessions
+// with a queue go to the end of it, so they arrive after the queued ones.
+func (m *Manager) DeliverPrompt(ctx context.Context, sessionID, text string) error {
+	session := m.GetSession(sessionID)
+	if session == nil {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	if m.deliversQueue() && len(session.GetQueue()) > 0 {
+		return m.Enqueue(sessionID, text)
+	}
+	if session.GetState() != StateIdle {
+		if err := m.WaitForPrompt(ctx, sessionID); err != nil {
+			return err
+		}
+	}
+
+	// A queued prompt may have been sent as the agent became idle
+	send, err := m.claimPrompt(session, text)
+	if !send {
+		return err
+	}
+	return m.SendPrompt(sessionID, text)
+}
+
+// SendPrompt types text into a session's agent and submits it
+func (m *Manager) SendPrompt(sessionID, text string) error {
+	session := m.GetSession(sessionID)
+	if session == nil {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	tmuxSession := session.GetTmuxSession()
+	if tmuxSession == nil {
+		return fmt.Errorf("session %s has no agent tmux session", sessionID)
+	}
+
+	if err := tmuxSession.PasteText(text); err != nil {
+		return err
+	}
+	time.Sleep(promptSubmitDelay)
+	return tmuxSession.TapEnter()
+}
@@ -0,0 +1,124 @@
+package session
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"agate/internal/debug"
+)
+
+// GetQueue returns the prompts waiting to be sent to the session's agent
+func (s *Session) GetQueue() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.Queue)
+}
+
+// SetQueue replaces the prompts queued for a session's agent. The first one
+// is sent right away if the agent is already waiting at its prompt.
+func (m *Manager) SetQueue(sessionID string, queue []string) error {
+	session := m.GetSession(sessionID)
+	if session == nil {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	session.mu.Lock()
+	session.Queue = slices.Clone(queue)
+	session.mu.Unlock()
+
+	err := m.queueChanged(session)
+	m.deliverQueued(session)
+	return err
+}
+
+// Enqueue adds a prompt to the end of a session's queue
+func (m *Manager) Enqueue(sessionID, text string) error {
+	session := m.GetSession(sessionID)
+	if session == nil {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	return m.SetQueue(sessionID, append(session.GetQueue(), text))
+}
+
+// EnableQueueDelivery makes the manager send queued prompts as agents become
+// idle. Only the long-running TUI does, as the subcommands exit before a
+// prompt sent in the background arrives.
+func (m *Manager) EnableQueueDelivery() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliverQueue = true
+}
+
+// deliversQueue reports whether the manager sends queued prompts
+func (m *Manager) deliversQueue() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.deliverQueue
+}
+
+// claimPrompt decides whether a prompt for a session's idle agent can be
+// sent now. When prompts are queued, or a queued one was already sent since
+// the agent became idle, the prompt joins the end of the queue instead.
+func (m *Manager) claimPrompt(session *Session, text string) (bool, error) {
+	if !m.deliversQueue() {
+		return true, nil
+	}
+
+	session.mu.Lock()
+	if len(session.Queue) == 0 && !session.lastQueuedAt.After(session.StateChangedAt) {
+		// Keep deliverQueued from sending while this prompt is on its way
+		session.lastQueuedAt = time.Now()
+		session.mu.Unlock()
+		return true, nil
+	}
+	session.Queue = append(session.Queue, text)
+	session.mu.Unlock()
+
+	debug.DebugLog("Queued prompt for %s behind earlier ones", session.GetID())
+	return false, m.queueChanged(session)
+}
+
+// deliverQueued sends the next queued prompt when the agent has reached its
+// prompt since the last one was sent. Sending happens in the background;
+// a prompt that fails to arrive goes back to the front of the queue.
+func (m *Manager) deliverQueued(session *Session) {
+	if !m.deliversQueue() {
+		return
+	}
+
+	session.mu.Lock()
+	if len(session.Queue) == 0 || session.State != StateIdle || !session.StateChangedAt.After(session.lastQueuedAt) {
+		session.mu.Unlock()
+		return
+	}
+	text := session.Queue[0]
+	session.Queue = session.Queue[1:]
+	session.lastQueuedAt = time.Now()
+	session.mu.Unlock()
+
+	if err := m.queueChanged(session); err != nil {
+		debug.DebugLog("Failed to persist the queue of %s: %v", session.GetID(), err)
+	}
+
+	go func() {
+		if err := m.SendPrompt(session.GetID(), text); err != nil {
+			debug.DebugLog("Failed to send queued prompt to %s: %v", session.GetID(), err)
+			session.mu.Lock()
+			session.Queue = append([]string{text}, session.Queue...)
+			session.mu.Unlock()
+			if err := m.queueChanged(session); err != nil {
+				debug.DebugLog("Failed to persist the queue of %s: %v", session.GetID(), err)
+			}
+			return
+		}
+		debug.DebugLog("Sent queued prompt to %s", session.GetID())
+	}()
+}
+
+// queueChanged persists a session's queue and announces the change
+func (m *Manager) queueChanged(session *Session) error {
+	err := m.PersistSessions()
+	m.publish(EventQueueChanged, session, session.GetState())
+	return err
+}
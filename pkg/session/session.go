@@ -1,6 +1,9 @@
 package session
 
 import (
+	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	"agate/pkg/app"
@@ -11,39 +14,190 @@ import (
 // Session represents a complete workspace: worktree + tmux session + agent
 type Session struct {
 	// Identification
-	ID          string `json:"id"`
-	Name        string `json:"name"`         // Internal name for session
 	WorktreeKey string `json:"worktree_key"` // Stable key for worktree identification
 
 	// Session-specific resources
-	TmuxSession      *tmux.TmuxSession `json:"-"`        // Agent tmux session - not persisted
-	ShellTmuxSession *tmux.TmuxSession `json:"-"`        // Shell tmux session - not persisted
+	ShellTmuxSession tmux.Backend      `json:"-"`        // Shell tmux session - not persisted
 	Worktree         *git.WorktreeInfo `json:"worktree"` // Worktree information
-	Agent            app.AgentConfig   `json:"agent"`    // This session's agent configuration
 
-	// State tracking
-	CreatedAt    time.Time `json:"created_at"`
-	LastAccessed time.Time `json:"last_accessed"`
-	IsActive     bool      `json:"is_active"`
+	// State tracking, guarded by mu
+	mu             sync.RWMutex
+	CreatedAt      time.Time `json:"created_at"`
+	LastAccessed   time.Time `json:"last_accessed"`
+	IsActive       bool      `json:"is_active"`
+	State          State     `json:"state"`            // Agent lifecycle state
+	StateChangedAt time.Time `json:"state_changed_at"` // When State last changed
+	Unseen         bool      `json:"unseen"`           // Activity happened while the session was in the background
+	Queue          []string  `json:"queue"`            // Prompts sent one by one whenever the agent gets back to its prompt
+	lastOutputAt   time.Time // When the agent pane last produced new output
+	lastQueuedAt   time.Time // When the last queued prompt was sent
+
+	// The agent and what identifies it, guarded by mu as restarting the
+	// session with another agent replaces them
+	id          string
+	name        string          // tmux session name of the agent
+	instance    int             // Distinguishes several sessions of one agent in a worktree
+	tmuxSession tmux.Backend    // Agent tmux session - not persisted
+	agent       app.AgentConfig // This session's agent configuration
+}
+
+// GetID returns the session's ID
+func (s *Session) GetID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.id
+}
+
+// GetName returns the name of the agent's tmux session
+func (s *Session) GetName() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.name
+}
+
+// GetInstance returns the number distinguishing the session from others
+// running the same agent in its worktree
+func (s *Session) GetInstance() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.instance
+}
+
+// GetTmuxSession returns the agent's tmux session
+func (s *Session) GetTmuxSession() tmux.Backend {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tmuxSession
+}
+
+// GetAgent returns the session's agent configuration
+func (s *Session) GetAgent() app.AgentConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.agent
+}
+
+// replaceAgent swaps in a freshly launched agent, re-keying the session
+func (s *Session) replaceAgent(id, name string, instance int, tmuxSession tmux.Backend, agent app.AgentConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.id = id
+	s.name = name
+	s.instance = instance
+	s.tmuxSession = tmuxSession
+	s.agent = agent
 }
 
 // Update refreshes the session's last accessed time and sets it as active
 func (s *Session) Update() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.LastAccessed = time.Now()
 	s.IsActive = true
+	s.Unseen = false
 }
 
 // Deactivate marks the session as inactive
 func (s *Session) Deactivate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.IsActive = false
 }
 
+// IsUnseen reports whether the session has activity the user hasn't looked at yet
+func (s *Session) IsUnseen() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Unseen
+}
+
+// GetLastAccessed returns when the session was last activated
+func (s *Session) GetLastAccessed() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.LastAccessed
+}
+
+// markUnseen flags a background session as having unseen activity and
+// reports whether the flag changed
+func (s *Session) markUnseen() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.IsActive || s.Unseen {
+		return false
+	}
+	s.Unseen = true
+	return true
+}
+
 // GetTmuxSessionName returns the stable tmux session name for this session
 func (s *Session) GetTmuxSessionName() string {
-	if s.TmuxSession != nil {
-		return s.TmuxSession.GetSessionName()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.tmuxSession != nil {
+		return s.tmuxSession.GetSessionName()
+	}
+	return generateTmuxSessionName(s.Worktree, instanceName(s.agent.Name, s.instance))
+}
+
+// AgentLabel returns the agent's display name, numbered when the worktree
+// runs more than one instance of it
+func (s *Session) AgentLabel() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.instance > 1 {
+		return fmt.Sprintf("%s %d", s.agent.CompanyName, s.instance)
+	}
+	return s.agent.CompanyName
+}
+
+// AgentName returns the name the agent was launched as. Ad-hoc commands
+// share the default agent configuration, so their program is used instead.
+func (s *Session) AgentName() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.agentName()
+}
+
+// agentName does the work of AgentName; s.mu must be held
+func (s *Session) agentName() string {
+	if s.agent.Name == app.DefaultAgent.Name && s.tmuxSession != nil {
+		return s.tmuxSession.GetProgram()
 	}
-	return generateTmuxSessionName(s.Worktree, s.Agent.Name)
+	return s.agent.Name
+}
+
+// InstanceRef names the session's agent along with its instance number for
+// every instance after the first, e.g. "claude-2"
+func (s *Session) InstanceRef() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return instanceName(s.agentName(), s.instance)
+}
+
+// generateSessionID creates the manager key for a session. The first
+// instance keeps the original <worktree>_<agent> form.
+func generateSessionID(worktreeKey, agentName string, instance int) string {
+	return worktreeKey + "_" + instanceName(agentName, instance)
+}
+
+// instanceName appends the instance number to the agent name for every
+// instance after the first
+func instanceName(agentName string, instance int) string {
+	if instance > 1 {
+		return fmt.Sprintf("%s-%d", agentName, instance)
+	}
+	return agentName
+}
+
+// sortSessions orders sessions by creation time, then ID
+func sortSessions(sessions []*Session) {
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].CreatedAt.Equal(sessions[j].CreatedAt) {
+			return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+		}
+		return sessions[i].GetID() < sessions[j].GetID()
+	})
 }
 
 // generateTmuxSessionName creates a stable, unique tmux session name
@@ -1,6 +1,9 @@
 package session
 
 import (
+	"strings"
+	"time"
+
 	"agate/internal/debug"
 	"agate/pkg/app"
 	"agate/pkg/config"
@@ -10,39 +13,107 @@ import (
 
 // PersistSessions saves all sessions to config
 func (m *Manager) PersistSessions() error {
-	for worktreeKey, session := range m.sessions {
-		persistedSession := config.PersistedSession{
-			ID:           session.ID,
-			WorktreeKey:  session.WorktreeKey,
-			TmuxName:     session.GetTmuxSessionName(),
-			AgentName:    session.Agent.Name,
-			CreatedAt:    session.CreatedAt,
-			LastAccessed: session.LastAccessed,
-		}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.persistSessions()
+}
 
-		if session.Worktree != nil {
-			persistedSession.WorktreePath = session.Worktree.Path
-			persistedSession.Branch = session.Worktree.Branch
-			persistedSession.RepoName = session.Worktree.RepoName
-		}
+// persistSessions does the work of PersistSessions, also removing the
+// mappings of the given sessions, in one update of the state file. Mappings
+// that another agate process removed since this one saw them stay removed.
+// m.mu must be held.
+func (m *Manager) persistSessions(removed ...string) error {
+	mappings := make(map[string]config.PersistedSession, len(m.sessions))
+	for sessionID, session := range m.sessions {
+		mappings[sessionID] = persistedSessionOf(session)
+	}
+	var activeSession string
+	if m.activeSession != nil {
+		activeSession = m.activeSession.GetID()
+	}
 
-		if err := config.SaveSessionMapping(worktreeKey, persistedSession); err != nil {
-			debug.DebugLog("Failed to persist session %s: %v", session.ID, err)
-			return err
+	m.persistMu.Lock()
+	defer m.persistMu.Unlock()
+	err := config.UpdateState(func(state *config.AppState) error {
+		for _, sessionID := range removed {
+			delete(state.Sessions.SessionMappings, sessionID)
+		}
+		for sessionID, persistedSession := range mappings {
+			if _, exists := state.Sessions.SessionMappings[sessionID]; !exists && m.persisted[sessionID] {
+				debug.DebugLog("Not persisting session %s, deleted by another agate", sessionID)
+				delete(mappings, sessionID)
+				continue
+			}
+			state.Sessions.SessionMappings[sessionID] = persistedSession
+		}
+		if activeSession != "" {
+			state.Sessions.ActiveSession = activeSession
 		}
+		return nil
+	})
+	if err != nil {
+		debug.DebugLog("Failed to persist sessions: %v", err)
+		return err
+	}
 
-		debug.DebugLog("Persisted session: %s (tmux: %s, agent: %s)",
-			persistedSession.ID, persistedSession.TmuxName, persistedSession.AgentName)
+	for _, sessionID := range removed {
+		delete(m.persisted, sessionID)
 	}
+	for sessionID := range mappings {
+		m.persisted[sessionID] = true
+	}
+	debug.DebugLog("Persisted %d sessions", len(mappings))
+	return nil
+}
 
-	// Update active session
-	if m.activeSession != nil {
-		if err := config.SetActiveSession(m.activeSession.WorktreeKey); err != nil {
-			debug.DebugLog("Failed to persist active session: %v", err)
+// markPersisted records sessions whose mappings were read from the state
+// file, or forgets them when they aren't there anymore
+func (m *Manager) markPersisted(persisted bool, sessionIDs ...string) {
+	m.persistMu.Lock()
+	defer m.persistMu.Unlock()
+	for _, sessionID := range sessionIDs {
+		if persisted {
+			m.persisted[sessionID] = true
+		} else {
+			delete(m.persisted, sessionID)
 		}
 	}
+}
 
-	return nil
+// persistedSessionOf describes a session as it is saved in the state file
+func persistedSessionOf(session *Session) config.PersistedSession {
+	agent := session.GetAgent()
+	persistedSession := config.PersistedSession{
+		ID:           session.GetID(),
+		WorktreeKey:  session.WorktreeKey,
+		Instance:     session.GetInstance(),
+		TmuxName:     session.GetTmuxSessionName(),
+		AgentName:    agent.Name,
+		AgentArgs:    agent.Args,
+		AgentWorkDir: agent.WorkDir,
+		CreatedAt:    session.CreatedAt,
+		LastAccessed: session.GetLastAccessed(),
+		Queue:        session.GetQueue(),
+	}
+
+	if tmuxSession := session.GetTmuxSession(); tmuxSession != nil {
+		persistedSession.AgentProgram = tmuxSession.GetProgram()
+		persistedSession.TmuxSocket = serverSocket(tmuxSession)
+		persistedSession.Backend = string(tmux.KindOf(tmuxSession))
+	}
+
+	if session.ShellTmuxSession != nil {
+		persistedSession.ShellTmuxName = session.ShellTmuxSession.GetSessionName()
+		persistedSession.ShellProgram = session.ShellTmuxSession.GetProgram()
+		persistedSession.ShellSocket = serverSocket(session.ShellTmuxSession)
+	}
+
+	if session.Worktree != nil {
+		persistedSession.WorktreePath = session.Worktree.Path
+		persistedSession.Branch = session.Worktree.Branch
+		persistedSession.RepoName = session.Worktree.RepoName
+	}
+	return persistedSession
 }
 
 // LoadSessions restores sessions from config
@@ -55,51 +126,311 @@ func (m *Manager) LoadSessions() error {
 
 	debug.DebugLog("Loading %d persisted sessions", len(sessionMappings))
 
-	for worktreeKey, persistedSession := range sessionMappings {
-		// Check if the tmux session still exists
-		exists, err := m.checkTmuxSessionExists(persistedSession.TmuxName)
-		if err != nil || !exists {
-			debug.DebugLog("Tmux session %s no longer exists, removing mapping", persistedSession.TmuxName)
-			config.RemoveSessionMapping(worktreeKey)
-			continue
+	for mappingKey, persistedSession := range sessionMappings {
+		// Older versions keyed mappings by worktree; move them to the session ID
+		if mappingKey != persistedSession.ID {
+			if err := config.RemoveSessionMapping(mappingKey); err != nil {
+				debug.DebugLog("Failed to remove legacy session mapping %s: %v", mappingKey, err)
+			}
+			if err := config.SaveSessionMapping(persistedSession.ID, persistedSession); err != nil {
+				debug.DebugLog("Failed to migrate session mapping %s: %v", persistedSession.ID, err)
+			}
 		}
 
 		// Recreate the session object (without creating a new tmux session)
-		session, err := m.restoreSessionFromPersisted(persistedSession)
+		session, err := m.sessionFromPersisted(persistedSession)
 		if err != nil {
 			debug.DebugLog("Failed to restore session %s: %v", persistedSession.ID, err)
 			continue
 		}
 
 		// Store in sessions map
-		m.sessions[worktreeKey] = session
+		m.mu.Lock()
+		m.sessions[session.GetID()] = session
+		m.mu.Unlock()
+		m.markPersisted(true, session.GetID())
 		debug.DebugLog("Restored session: %s (tmux: %s, agent: %s)",
-			session.ID, session.GetTmuxSessionName(), session.Agent.Name)
+			session.GetID(), session.GetTmuxSessionName(), session.GetAgent().Name)
+	}
+
+	m.restoreActiveSession()
+	return nil
+}
+
+// InspectSessions loads the persisted sessions without changing anything, for
+// commands that only report on them: mappings aren't migrated, and tmux
+// sessions are neither attached to nor started. Sessions whose tmux session
+// runs start out as starting until they are probed.
+func (m *Manager) InspectSessions() error {
+	sessionMappings, err := config.GetSessionMappings()
+	if err != nil {
+		return err
+	}
+
+	for _, persistedSession := range sessionMappings {
+		agentConfig, worktree, tmuxSession := persistedSessionParts(persistedSession)
+		state := StateStopped
+		if tmux.BackendKind(persistedSession.Backend) != tmux.BackendPty {
+			setServer(tmuxSession, tmux.ServerAt(persistedSession.TmuxSocket))
+			if exists, err := tmuxSession.SessionExists(); err == nil && exists {
+				state = StateStarting
+			}
+		}
+
+		program := persistedSession.ShellProgram
+		if program == "" {
+			program = defaultShell()
+		}
+		shellTmuxSession := tmux.NewBackend(persistedShellNames(persistedSession)[0], program)
+		setServer(shellTmuxSession, persistedShellServer(persistedSession))
+
+		m.mu.Lock()
+		m.sessions[persistedSession.ID] = &Session{
+			id:               persistedSession.ID,
+			name:             persistedSession.TmuxName,
+			WorktreeKey:      persistedSession.WorktreeKey,
+			instance:         persistedSession.Instance,
+			tmuxSession:      tmuxSession,
+			ShellTmuxSession: shellTmuxSession,
+			Worktree:         worktree,
+			agent:            agentConfig,
+			CreatedAt:        persistedSession.CreatedAt,
+			LastAccessed:     persistedSession.LastAccessed,
+			State:            state,
+			StateChangedAt:   time.Now(),
+			Queue:            persistedSession.Queue,
+		}
+		m.mu.Unlock()
 	}
 
-	// Restore active session
+	m.restoreActiveSession()
+	return nil
+}
+
+// restoreActiveSession makes the persisted active session active again
+func (m *Manager) restoreActiveSession() {
 	activeSessionKey, err := config.GetActiveSession()
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if err == nil && activeSessionKey != "" {
-		if session, exists := m.sessions[activeSessionKey]; exists {
+		session, exists := m.sessions[activeSessionKey]
+		if !exists {
+			// Older versions stored the active worktree key instead of the session ID
+			for _, candidate := range m.sessions {
+				if candidate.WorktreeKey == activeSessionKey {
+					session, exists = candidate, true
+					break
+				}
+			}
+		}
+		if exists {
 			m.activeSession = session
-			debug.DebugLog("Restored active session: %s", session.ID)
+			debug.DebugLog("Restored active session: %s", session.GetID())
+		}
+	}
+}
+
+// SyncPersistedSessions picks up sessions that other agate processes, such
+// as `agate run`, persisted or deleted since the sessions were loaded.
+// Sessions are only dropped once their tmux session is gone too.
+func (m *Manager) SyncPersistedSessions() error {
+	sessionMappings, err := config.GetSessionMappings()
+	if err != nil {
+		return err
+	}
+
+	// Note what is known before talking to tmux, which happens outside the lock
+	m.mu.RLock()
+	var missing []config.PersistedSession
+	for sessionID, persistedSession := range sessionMappings {
+		if _, exists := m.sessions[sessionID]; exists || m.starting[sessionID] || sessionID != persistedSession.ID {
+			continue
+		}
+		missing = append(missing, persistedSession)
+	}
+	unmapped := make(map[string]*Session)
+	for sessionID, session := range m.sessions {
+		if _, exists := sessionMappings[sessionID]; !exists {
+			unmapped[sessionID] = session
+		}
+	}
+	m.mu.RUnlock()
+
+	var restored []*Session
+	for _, persistedSession := range missing {
+		session, err := m.sessionFromPersisted(persistedSession)
+		if err != nil {
+			debug.DebugLog("Failed to restore session %s: %v", persistedSession.ID, err)
+			continue
+		}
+		restored = append(restored, session)
+	}
+	for sessionID, session := range unmapped {
+		// Sessions whose mapping went missing while they still run are kept
+		// and persisted again
+		tmuxSession := session.GetTmuxSession()
+		if tmuxSession == nil {
+			continue
+		}
+		if exists, err := tmuxSession.SessionExists(); err != nil || exists {
+			m.markPersisted(false, sessionID)
+			delete(unmapped, sessionID)
+		}
+	}
+
+	var created, deleted []*Session
+	m.mu.Lock()
+	for _, session := range restored {
+		// Skip sessions this process created or restored in the meantime
+		if _, exists := m.sessions[session.GetID()]; exists {
+			continue
+		}
+		m.sessions[session.GetID()] = session
+		created = append(created, session)
+		debug.DebugLog("Picked up session: %s", session.GetID())
+	}
+	for sessionID, session := range unmapped {
+		// Sessions may have been deleted or restarted in the meantime
+		if m.sessions[sessionID] != session {
+			continue
+		}
+		delete(m.sessions, sessionID)
+		if m.activeSession == session {
+			m.activeSession = nil
 		}
+		deleted = append(deleted, session)
+		debug.DebugLog("Dropped session deleted elsewhere: %s", sessionID)
 	}
+	m.mu.Unlock()
 
+	for _, session := range created {
+		m.markPersisted(true, session.GetID())
+		event := newEvent(EventSessionCreated, session, session.GetState())
+		event.Elsewhere = true
+		m.publishEvent(event)
+	}
+	for _, session := range deleted {
+		m.markPersisted(false, session.GetID())
+		event := newEvent(EventSessionDeleted, session, session.GetState())
+		event.Elsewhere = true
+		m.publishEvent(event)
+	}
 	return nil
 }
 
-// checkTmuxSessionExists checks if a tmux session with the given name exists
-func (m *Manager) checkTmuxSessionExists(sessionName string) (bool, error) {
+// sessionFromPersisted recreates a persisted session, reconnecting to its
+// tmux session on the server it was started on. Sessions whose tmux session
+// is gone are kept as stopped so they can be resumed, which moves them to the
+// current server.
+func (m *Manager) sessionFromPersisted(persistedSession config.PersistedSession) (*Session, error) {
+	// PTY sessions end with the agate process that started them
+	if tmux.BackendKind(persistedSession.Backend) == tmux.BackendPty {
+		debug.DebugLog("PTY session %s ended with the previous agate, marking session stopped", persistedSession.TmuxName)
+		return m.stoppedSessionFromPersisted(persistedSession), nil
+	}
+
+	server := tmux.ServerAt(persistedSession.TmuxSocket)
+	exists, err := m.checkTmuxSessionExists(server, persistedSession.TmuxName)
+	if err != nil {
+		debug.DebugLog("Failed to check tmux session %s: %v", persistedSession.TmuxName, err)
+	}
+	if !exists {
+		debug.DebugLog("Tmux session %s no longer exists, marking session stopped", persistedSession.TmuxName)
+		return m.stoppedSessionFromPersisted(persistedSession), nil
+	}
+	return m.restoreSessionFromPersisted(persistedSession, server)
+}
+
+// checkTmuxSessionExists checks if a tmux session with the given name exists on a server
+func (m *Manager) checkTmuxSessionExists(server tmux.Server, sessionName string) (bool, error) {
 	// Create a temporary tmux session object to check existence
 	tempSession := tmux.NewTmuxSession(sessionName, "dummy")
+	tempSession.SetServer(server)
 	return tempSession.SessionExists()
 }
 
 // restoreSessionFromPersisted recreates a session object from persisted data
-func (m *Manager) restoreSessionFromPersisted(persistedSession config.PersistedSession) (*Session, error) {
-	// Get agent configuration
+func (m *Manager) restoreSessionFromPersisted(persistedSession config.PersistedSession, server tmux.Server) (*Session, error) {
+	agentConfig, worktree, tmuxSession := persistedSessionParts(persistedSession)
+	setServer(tmuxSession, server)
+	err := tmuxSession.Restore() // Connect to existing session
+	if err != nil {
+		return nil, err
+	}
+
+	// Reconnect to the shell session, starting a new one if it's gone
+	shellTmuxSession, err := m.restoreShellSession(persistedSession, worktree)
+	if err != nil {
+		debug.DebugLog("Failed to restore shell session for %s: %v", persistedSession.ID, err)
+	}
+
+	// Recreate session object
+	session := &Session{
+		id:               persistedSession.ID,
+		name:             persistedSession.TmuxName,
+		WorktreeKey:      persistedSession.WorktreeKey,
+		instance:         persistedSession.Instance,
+		tmuxSession:      tmuxSession,
+		ShellTmuxSession: shellTmuxSession,
+		Worktree:         worktree,
+		agent:            agentConfig,
+		CreatedAt:        persistedSession.CreatedAt,
+		LastAccessed:     persistedSession.LastAccessed,
+		IsActive:         false, // Will be set during activation
+		State:            StateStarting,
+		StateChangedAt:   time.Now(),
+		Queue:            persistedSession.Queue,
+	}
+
+	return session, nil
+}
+
+// stoppedSessionFromPersisted recreates a session whose tmux session is gone.
+// Its tmux sessions aren't started until the session is resumed.
+func (m *Manager) stoppedSessionFromPersisted(persistedSession config.PersistedSession) *Session {
+	agentConfig, worktree, tmuxSession := persistedSessionParts(persistedSession)
+
+	// The shell may have outlived the agent, in which case keep using it
+	program := persistedSession.ShellProgram
+	if program == "" {
+		program = defaultShell()
+	}
+	shellTmuxSession := tmux.NewBackend(persistedShellNames(persistedSession)[0], program)
+	setServer(shellTmuxSession, persistedShellServer(persistedSession))
+	if exists, err := persistedShellExists(persistedSession, shellTmuxSession); err == nil && exists {
+		if err := shellTmuxSession.Restore(); err != nil {
+			debug.DebugLog("Failed to reconnect shell session for %s: %v", persistedSession.ID, err)
+		}
+	} else {
+		// Resuming starts a new shell on the current server
+		setServer(shellTmuxSession, tmux.CurrentServer())
+	}
+
+	return &Session{
+		id:               persistedSession.ID,
+		name:             persistedSession.TmuxName,
+		WorktreeKey:      persistedSession.WorktreeKey,
+		instance:         persistedSession.Instance,
+		tmuxSession:      tmuxSession,
+		ShellTmuxSession: shellTmuxSession,
+		Worktree:         worktree,
+		agent:            agentConfig,
+		CreatedAt:        persistedSession.CreatedAt,
+		LastAccessed:     persistedSession.LastAccessed,
+		State:            StateStopped,
+		StateChangedAt:   time.Now(),
+		Queue:            persistedSession.Queue,
+	}
+}
+
+// persistedSessionParts rebuilds the agent configuration, worktree info and
+// agent tmux session object described by persisted data
+func persistedSessionParts(persistedSession config.PersistedSession) (app.AgentConfig, *git.WorktreeInfo, tmux.Backend) {
+	// Get agent configuration, keeping the options the agent was launched
+	// with. The environment isn't persisted and comes from config.json.
 	agentConfig := app.GetAgentConfig(persistedSession.AgentName)
+	agentConfig.Args = persistedSession.AgentArgs
+	agentConfig.WorkDir = persistedSession.AgentWorkDir
 
 	// Recreate worktree info
 	worktree := &git.WorktreeInfo{
@@ -109,25 +440,102 @@ func (m *Manager) restoreSessionFromPersisted(persistedSession config.PersistedS
 		RepoName: persistedSession.RepoName,
 	}
 
-	// Create tmux session object (connecting to existing session)
-	tmuxSession := tmux.NewTmuxSession(persistedSession.TmuxName, persistedSession.AgentName)
-	err := tmuxSession.Restore() // Connect to existing session
-	if err != nil {
-		return nil, err
+	// Create tmux session object, able to relaunch the agent if needed
+	program := persistedSession.AgentProgram
+	if program == "" {
+		program = agentConfig.LaunchCommand(persistedSession.AgentName)
 	}
+	tmuxSession := tmux.NewBackend(persistedSession.TmuxName, program)
+	tmuxSession.SetArgs(agentConfig.Args)
+	tmuxSession.SetEnv(agentConfig.Env)
+	tmuxSession.SetRemainOnExit(true)
+	if err := tmuxSession.SetDetectionRules(agentConfig.Detection); err != nil {
+		debug.DebugLog("Invalid detection rules for agent %s: %v", agentConfig.Name, err)
+	}
+	return agentConfig, worktree, tmuxSession
+}
 
-	// Recreate session object
-	session := &Session{
-		ID:           persistedSession.ID,
-		Name:         persistedSession.TmuxName,
-		WorktreeKey:  persistedSession.WorktreeKey,
-		TmuxSession:  tmuxSession,
-		Worktree:     worktree,
-		Agent:        agentConfig,
-		CreatedAt:    persistedSession.CreatedAt,
-		LastAccessed: persistedSession.LastAccessed,
-		IsActive:     false, // Will be set during activation
+// persistedShellNames returns the names the session's shell may be running
+// under, most likely first
+func persistedShellNames(persistedSession config.PersistedSession) []string {
+	// Mappings from older versions don't record the shell, so try the names it used to get
+	if persistedSession.ShellTmuxName == "" {
+		return legacyShellSessionNames(persistedSession.TmuxName)
 	}
+	return []string{persistedSession.ShellTmuxName}
+}
 
-	return session, nil
+// persistedShellServer returns the tmux server a persisted session's shell was started on
+func persistedShellServer(persistedSession config.PersistedSession) tmux.Server {
+	// Mappings from before the shell's server was recorded kept it beside the agent
+	if persistedSession.ShellSocket == "" {
+		return tmux.ServerAt(persistedSession.TmuxSocket)
+	}
+	return tmux.ServerAt(persistedSession.ShellSocket)
+}
+
+// persistedShellExists reports whether a persisted session's shell still
+// runs. Shells on a PTY ended with the agate that started them.
+func persistedShellExists(persistedSession config.PersistedSession, shellTmuxSession tmux.Backend) (bool, error) {
+	if tmux.BackendKind(persistedSession.Backend) == tmux.BackendPty {
+		return false, nil
+	}
+	return shellTmuxSession.SessionExists()
+}
+
+// setServer points a tmux session at a server; other backends don't have one
+func setServer(backend tmux.Backend, server tmux.Server) {
+	if tmuxSession, ok := backend.(*tmux.TmuxSession); ok {
+		tmuxSession.SetServer(server)
+	}
+}
+
+// serverSocket returns the socket of the tmux server a session runs on, or
+// an empty string for backends without one
+func serverSocket(backend tmux.Backend) string {
+	if tmuxSession, ok := backend.(*tmux.TmuxSession); ok {
+		return tmuxSession.Server().SocketFile()
+	}
+	return ""
+}
+
+// restoreShellSession reconnects to a persisted session's shell, or starts a
+// new one in the worktree when the tmux session no longer exists
+func (m *Manager) restoreShellSession(persistedSession config.PersistedSession, worktree *git.WorktreeInfo) (tmux.Backend, error) {
+	program := persistedSession.ShellProgram
+	if program == "" {
+		program = defaultShell()
+	}
+
+	names := persistedShellNames(persistedSession)
+	for _, name := range names {
+		shellTmuxSession := tmux.NewBackend(name, program)
+		setServer(shellTmuxSession, persistedShellServer(persistedSession))
+		if exists, err := shellTmuxSession.SessionExists(); err != nil || !exists {
+			continue
+		}
+		if err := shellTmuxSession.Restore(); err != nil {
+			return nil, err
+		}
+		return shellTmuxSession, nil
+	}
+
+	debug.DebugLog("Shell session for %s vanished, starting a new one", persistedSession.ID)
+	shellTmuxSession := tmux.NewBackend(names[0], program)
+	if err := shellTmuxSession.Start(worktree.Path); err != nil {
+		return nil, err
+	}
+	return shellTmuxSession, nil
+}
+
+// legacyShellSessionNames returns the shell session names older versions
+// derived from the agent's tmux name. Their agent names were sanitized twice,
+// while the shell name was built from the once-sanitized form.
+func legacyShellSessionNames(tmuxName string) []string {
+	names := []string{"shell_" + tmuxName}
+	inner := strings.TrimPrefix(tmuxName, "agate_")
+	if idx := strings.LastIndex(inner, "_"); idx > 0 && inner != tmuxName {
+		names = append(names, "shell_"+inner[:idx])
+	}
+	return names
 }
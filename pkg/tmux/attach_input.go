@@ -0,0 +1,379 @@
+package tmux
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"agate/internal/debug"
+
+	"github.com/muesli/cancelreader"
+)
+
+// DefaultDetachKey detaches from an attached session unless config.json sets another
+const DefaultDetachKey = "C-q"
+
+// maxPendingInput caps how much of an unfinished escape sequence is held back
+// waiting for its end; terminal replies are far shorter
+const maxPendingInput = 4096
+
+// Control bytes, the introducers that follow ESC, and the bracketed paste delimiters
+const (
+	esc       = 0x1b
+	csiIntro  = '['
+	ss3Intro  = 'O'
+	oscIntro  = ']'
+	dcsIntro  = 'P'
+	apcIntro  = '_'
+	bel       = 0x07
+	stFinal   = '\\'
+	pasteOpen = "\x1b[200~"
+	pasteEnd  = "\x1b[201~"
+)
+
+// DetachKey is a key, or a sequence of keys such as a prefix key followed by
+// another, that detaches from an attached session
+type DetachKey struct {
+	Names []string // In tmux's notation, e.g. "C-q" or "C-a", "d"
+	bytes []byte
+}
+
+var (
+	detachKeyMu sync.RWMutex
+	detachKey   = mustParseDetachKey(DefaultDetachKey)
+)
+
+// ParseDetachKey parses space-separated keys in tmux's notation, e.g. "C-q"
+// or "C-a d" for Ctrl+A followed by d
+func ParseDetachKey(spec string) (DetachKey, error) {
+	names := strings.Fields(spec)
+	if len(names) == 0 {
+		return DetachKey{}, fmt.Errorf("the detach key is empty")
+	}
+	key := DetachKey{Names: names}
+	for _, name := range names {
+		sequence, err := keySequence(name, false)
+		if err != nil {
+			return DetachKey{}, fmt.Errorf("invalid detach key %q: %w", spec, err)
+		}
+		key.bytes = append(key.bytes, sequence...)
+	}
+	return key, nil
+}
+
+// mustParseDetachKey parses a detach key known to be valid
+func mustParseDetachKey(spec string) DetachKey {
+	key, err := ParseDetachKey(spec)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// SetDetachKey selects the key that detaches from attached sessions
+func SetDetachKey(spec string) error {
+	key, err := ParseDetachKey(spec)
+	if err != nil {
+		return err
+	}
+	detachKeyMu.Lock()
+	defer detachKeyMu.Unlock()
+	detachKey = key
+	return nil
+}
+
+// CurrentDetachKey returns the key that detaches from attached sessions
+func CurrentDetachKey() DetachKey {
+	detachKeyMu.RLock()
+	defer detachKeyMu.RUnlock()
+	return detachKey
+}
+
+// keyHelpNames are how named keys are shown in help texts
+var keyHelpNames = map[string]string{
+	"BTab":   "shift+tab",
+	"BSpace": "backspace",
+	"Escape": "esc",
+	"Space":  "space",
+	"PPage":  "pgup",
+	"NPage":  "pgdown",
+	"IC":     "insert",
+	"DC":     "delete",
+}
+
+// Help returns the detach key as help texts show keys, e.g. "ctrl+q" or "ctrl+a d"
+func (k DetachKey) Help() string {
+	keys := make([]string, len(k.Names))
+	for i, name := range k.Names {
+		var modifiers string
+		for len(name) > 2 && name[1] == '-' {
+			switch name[0] {
+			case 'C':
+				modifiers += "ctrl+"
+			case 'M':
+				modifiers += "alt+"
+			case 'S':
+				modifiers += "shift+"
+			}
+			name = name[2:]
+		}
+		if help, ok := keyHelpNames[name]; ok {
+			name = help
+		} else if len(name) > 1 {
+			name = strings.ToLower(name)
+		}
+		keys[i] = modifiers + name
+	}
+	return strings.Join(keys, " ")
+}
+
+// Matches reports whether keys typed so far are the whole detach key, or
+// could still become it
+func (k DetachKey) Matches(keys []Key) (complete, prefix bool) {
+	var typed []byte
+	for _, key := range keys {
+		if key.Text != "" {
+			typed = append(typed, key.Text...)
+			continue
+		}
+		sequence, err := keySequence(key.Name, false)
+		if err != nil {
+			return false, false
+		}
+		typed = append(typed, sequence...)
+	}
+	if bytes.Equal(typed, k.bytes) {
+		return true, false
+	}
+	return false, len(typed) > 0 && bytes.HasPrefix(k.bytes, typed)
+}
+
+// attachInput sorts what is read from the terminal while attached into keys
+// for the program and the detach key. Until the first key, replies to the
+// terminal queries agate made before attaching, such as device attributes
+// (DA) and OSC color reports, are dropped; after it they belong to queries
+// of the attached program and are passed on.
+type attachInput struct {
+	detach   DetachKey
+	pending  []byte // Unfinished escape sequence from the previous read
+	held     []byte // Keys typed so far that may be the start of the detach key
+	typed    bool   // Whether a key has been read, so replies are no longer dropped
+	pasting  bool   // Inside a bracketed paste, where the detach key is text
+	forward  []byte
+	detached bool
+}
+
+// newAttachInput starts reading the terminal with the current detach key
+func newAttachInput() *attachInput {
+	return &attachInput{detach: CurrentDetachKey()}
+}
+
+// forwardAttachInput passes what is typed on in on to write until the detach
+// key is typed, which it reports, done is closed or reading fails. Reading is
+// cancelled when done is closed rather than waiting for another key, which
+// then goes to agate instead of being swallowed.
+func forwardAttachInput(in *os.File, done <-chan struct{}, write func([]byte)) bool {
+	reader, err := cancelreader.NewReader(in)
+	if err != nil {
+		debug.DebugLog("Failed to read terminal input: %v", err)
+		return false
+	}
+	defer reader.Close()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-done:
+			reader.Cancel()
+		case <-stop:
+		}
+	}()
+
+	input := newAttachInput()
+	buf := make([]byte, 1024)
+	for {
+		nr, err := reader.Read(buf)
+		if nr > 0 {
+			forward, detached := input.feed(buf[:nr])
+			if len(forward) > 0 {
+				write(forward)
+			}
+			if detached {
+				return true
+			}
+		}
+		if err != nil {
+			// Retrying a failed read would only fail again
+			if !errors.Is(err, cancelreader.ErrCanceled) && !errors.Is(err, io.EOF) {
+				debug.DebugLog("Failed to read terminal input: %v", err)
+			}
+			return false
+		}
+	}
+}
+
+// feed takes one read from the terminal and returns the bytes to pass on to
+// the program, and whether the detach key was typed
+func (in *attachInput) feed(data []byte) ([]byte, bool) {
+	in.forward = nil
+	buf := append(in.pending, data...)
+	in.pending = nil
+
+	for len(buf) > 0 && !in.detached {
+		n, complete := sequenceLength(buf)
+		if !complete {
+			if len(buf) > maxPendingInput {
+				// Not an escape sequence after all
+				n = 1
+			} else {
+				in.pending = append([]byte{}, buf...)
+				break
+			}
+		}
+		token := buf[:n]
+		buf = buf[n:]
+
+		if isTerminalReply(token) {
+			if in.typed {
+				in.forward = append(in.forward, token...)
+			}
+			continue
+		}
+		in.typed = true
+		in.key(token)
+	}
+	return in.forward, in.detached
+}
+
+// key handles one keystroke, or a paste delimiter
+func (in *attachInput) key(token []byte) {
+	switch string(token) {
+	case pasteOpen:
+		in.pasting = true
+	case pasteEnd:
+		in.pasting = false
+	}
+	if in.pasting || string(token) == pasteEnd {
+		in.flushHeld()
+		in.forward = append(in.forward, token...)
+		return
+	}
+
+	keys := append(append([]byte{}, in.held...), token...)
+	switch {
+	case bytes.Equal(keys, in.detach.bytes):
+		in.held = nil
+		in.detached = true
+	case bytes.HasPrefix(in.detach.bytes, keys):
+		in.held = keys
+	case len(in.held) > 0:
+		// Not the detach key after all; this key may start it afresh
+		in.flushHeld()
+		in.key(token)
+	default:
+		in.forward = append(in.forward, token...)
+	}
+}
+
+// flushHeld passes on keys held back as the possible start of the detach key
+func (in *attachInput) flushHeld() {
+	in.forward = append(in.forward, in.held...)
+	in.held = nil
+}
+
+// sequenceLength returns the length of the keystroke or escape sequence at
+// the start of buf, and false if it continues past the end of buf. ESC, or
+// ESC and an introducer, alone at the end of a read are keys typed by the
+// user, e.g. Escape and Alt+[, as terminals write their replies at once.
+func sequenceLength(buf []byte) (int, bool) {
+	if buf[0] != esc || len(buf) == 1 {
+		return 1, true
+	}
+	if len(buf) == 2 {
+		return 2, true
+	}
+
+	switch buf[1] {
+	case csiIntro:
+		// Parameter and intermediate bytes, then a final byte
+		for i := 2; i < len(buf); i++ {
+			switch c := buf[i]; {
+			case c >= 0x20 && c <= 0x3f:
+			case c >= 0x40 && c <= 0x7e:
+				return i + 1, true
+			default:
+				// Malformed; pass ESC [ on as typed
+				return 2, true
+			}
+		}
+		return 0, false
+	case ss3Intro:
+		return 3, true
+	case oscIntro, dcsIntro, apcIntro:
+		if !isStringReplyStart(buf[1], buf[2]) {
+			return 2, true
+		}
+		// Terminated by BEL (OSC only) or ST, ESC \
+		for i := 2; i < len(buf); i++ {
+			if buf[1] == oscIntro && buf[i] == bel {
+				return i + 1, true
+			}
+			if buf[i] == esc && i+1 < len(buf) && buf[i+1] == stFinal {
+				return i + 2, true
+			}
+		}
+		return 0, false
+	}
+	// Alt and a key
+	return 2, true
+}
+
+// isStringReplyStart reports whether an OSC, DCS or APC introducer followed
+// by c starts a terminal reply rather than being Alt+], Alt+P or Alt+_
+func isStringReplyStart(intro, c byte) bool {
+	switch intro {
+	case oscIntro:
+		// e.g. OSC 11;rgb:1e1e/1e1e/1e1e ST
+		return c >= '0' && c <= '9'
+	case dcsIntro:
+		// e.g. DCS 1 $ r ... ST (DECRQSS) or DCS > | xterm(390) ST (XTVERSION)
+		return (c >= '0' && c <= '9') || c == '>' || c == '!'
+	case apcIntro:
+		// Kitty graphics protocol replies
+		return c == 'G'
+	}
+	return false
+}
+
+// isTerminalReply reports whether a sequence is the terminal answering a query
+// rather than a key: device attributes (CSI ? ... c, CSI > ... c, CSI = ... c),
+// mode reports (CSI ? ... $ y), kitty keyboard flags (CSI ? ... u) and the
+// OSC, DCS and APC string replies
+func isTerminalReply(token []byte) bool {
+	if len(token) < 3 || token[0] != esc {
+		return false
+	}
+	switch token[1] {
+	case oscIntro, dcsIntro, apcIntro:
+		return isStringReplyStart(token[1], token[2])
+	case csiIntro:
+		if len(token) < 4 {
+			return false
+		}
+		private, final := token[2], token[len(token)-1]
+		switch {
+		case (private == '?' || private == '>' || private == '=') && final == 'c':
+			return true
+		case private == '?' && final == 'y' && token[len(token)-2] == '$':
+			return true
+		case private == '?' && final == 'u':
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package tmux
+
+import (
+	"os/exec"
+	"sync"
+)
+
+// Backend runs a program in a terminal that agate can preview, type into and
+// attach to. TmuxSession runs it in a tmux session; PtySession runs it on a
+// PTY of agate's own, for when tmux isn't available.
+type Backend interface {
+	// Configuration, applied by Start
+	SetArgs(args []string)
+	SetEnv(env map[string]string)
+	SetRemainOnExit(remain bool)
+	SetDetectionRules(rules DetectionRules) error
+	GetProgram() string
+	GetSessionName() string
+
+	// Lifecycle
+	Start(workDir string) error   // Launches the program unless it already runs
+	Restore() error               // Reconnects to a program that already runs
+	SessionExists() (bool, error) // Whether the program's terminal is still around
+	PaneStatus() (PaneStatus, error)
+	Kill() error
+
+	// Output
+	CapturePaneContent() (string, error) // Visible screen, with ANSI escape sequences
+	// Lines from startLine to endLine, where 0 is the top of the visible screen
+	// and negative lines are scrollback
+	CapturePaneContentWithOptions(startLine, endLine int) (string, error)
+	HistorySize() (int, error) // Lines of scrollback above the visible screen
+	HasContentUpdated(content string) (updated bool, hasPrompt bool)
+	Classify(content string) PaneState
+
+	// Input
+	SendKeys(keys string) error
+	SendKey(key Key) error // One keystroke, as typed on a terminal
+	PasteText(text string) error
+	TapEnter() error
+
+	// Attach takes over the terminal until the user detaches, which closes the returned channel
+	Attach() (chan struct{}, error)
+	SetDetachedSize(width, height int) error
+}
+
+// BackendKind names a Backend implementation
+type BackendKind string
+
+// Backend implementations
+const (
+	BackendTmux BackendKind = "tmux"
+	BackendPty  BackendKind = "pty"
+)
+
+var (
+	backendKindMu sync.RWMutex
+	backendKind   = BackendTmux
+)
+
+// SetBackendKind selects the backend NewBackend creates
+func SetBackendKind(kind BackendKind) {
+	backendKindMu.Lock()
+	defer backendKindMu.Unlock()
+	backendKind = kind
+}
+
+// CurrentBackendKind returns the backend NewBackend creates
+func CurrentBackendKind() BackendKind {
+	backendKindMu.RLock()
+	defer backendKindMu.RUnlock()
+	return backendKind
+}
+
+// NewBackend creates a backend of the current kind for a program
+func NewBackend(name, program string) Backend {
+	if CurrentBackendKind() == BackendPty {
+		return NewPtySession(name, program)
+	}
+	return NewTmuxSession(name, program)
+}
+
+// KindOf returns the kind of a backend
+func KindOf(backend Backend) BackendKind {
+	if _, ok := backend.(*PtySession); ok {
+		return BackendPty
+	}
+	return BackendTmux
+}
+
+// Available reports whether the tmux executable can be found
+func Available() bool {
+	_, err := exec.LookPath("tmux")
+	return err == nil
+}
@@ -0,0 +1,297 @@
+package tmux
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+
+	"agate/internal/debug"
+)
+
+// controlRetryDelay is how long a session whose control client failed is
+// polled before control mode is tried again
+const controlRetryDelay = 5 * time.Second
+
+// controlCloseTimeout is how long a control client gets to exit after its
+// input is closed before it is killed
+const controlCloseTimeout = time.Second
+
+// changeNotifications are the control-mode notifications after which the
+// pane's content may differ from its last capture
+var changeNotifications = map[string]bool{
+	"%output":                 true,
+	"%extended-output":        true,
+	"%layout-change":          true,
+	"%window-pane-changed":    true,
+	"%session-window-changed": true,
+	"%window-add":             true,
+	"%window-close":           true,
+	"%unlinked-window-close":  true,
+}
+
+// ControlClient follows a tmux session through a control-mode client
+// (tmux -C) and announces when its panes produce output
+type ControlClient struct {
+	server      Server
+	sessionName string
+	cmd         *exec.Cmd
+	stdin       io.WriteCloser
+
+	changes chan struct{} // Holds one pending change; further changes coalesce into it
+	done    chan struct{} // Closed once the client has exited
+
+	mu  sync.Mutex
+	err error // Why the client exited
+}
+
+// NewControlClient attaches a control-mode client to the named session on a
+// server. The client doesn't take part in sizing the session's windows.
+func NewControlClient(server Server, sessionName string) (*ControlClient, error) {
+	cmd := server.Command("-C", "attach-session", "-t", sessionName, "-f", "ignore-size,read-only")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error starting control client for %s: %w", sessionName, err)
+	}
+
+	c := &ControlClient{
+		server:      server,
+		sessionName: sessionName,
+		cmd:         cmd,
+		stdin:       stdin,
+		changes:     make(chan struct{}, 1),
+		done:        make(chan struct{}),
+	}
+	go c.readLoop(stdout)
+	return c, nil
+}
+
+// SessionName returns the name of the session the client follows
+func (c *ControlClient) SessionName() string {
+	return c.sessionName
+}
+
+// follows reports whether the client follows the tmux session
+func (c *ControlClient) follows(t *TmuxSession) bool {
+	return c.sessionName == t.GetSessionName() && c.server.Same(t.Server())
+}
+
+// Changes delivers a value when the session's output changed since the last one was received
+func (c *ControlClient) Changes() <-chan struct{} {
+	return c.changes
+}
+
+// Done is closed when the client exits, e.g. because its session was killed
+func (c *ControlClient) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the client exited, or nil while it runs
+func (c *ControlClient) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close detaches the client
+func (c *ControlClient) Close() {
+	// A control client exits once its input ends
+	_ = c.stdin.Close()
+	select {
+	case <-c.done:
+	case <-time.After(controlCloseTimeout):
+		_ = c.cmd.Process.Kill()
+		<-c.done
+	}
+}
+
+// readLoop reads notifications until the client exits
+func (c *ControlClient) readLoop(stdout io.Reader) {
+	defer close(c.done)
+
+	reader := bufio.NewReader(stdout)
+	inBlock := false // Inside the output of a command, between %begin and %end
+	for {
+		line, isPrefix, err := reader.ReadLine()
+		if err != nil {
+			break
+		}
+		name := notificationName(line)
+		reason := ""
+		if name == "%exit" {
+			reason = string(bytes.TrimSpace(line[len(name):]))
+		}
+		// Skip the rest of lines too long for the buffer; their start is enough
+		for isPrefix && err == nil {
+			_, isPrefix, err = reader.ReadLine()
+		}
+
+		switch {
+		case name == "%begin":
+			inBlock = true
+		case name == "%end" || name == "%error":
+			inBlock = false
+		case inBlock:
+		case name == "%exit":
+			debug.DebugLog("Control client for %s exited: %s", c.sessionName, reason)
+		case changeNotifications[name]:
+			c.notify()
+		}
+	}
+
+	err := c.cmd.Wait()
+	c.mu.Lock()
+	if err == nil {
+		err = io.EOF
+	}
+	c.err = err
+	c.mu.Unlock()
+}
+
+// notify records a change without blocking on an unread one
+func (c *ControlClient) notify() {
+	select {
+	case c.changes <- struct{}{}:
+	default:
+	}
+}
+
+// notificationName returns the leading %word of a control-mode line
+func notificationName(line []byte) string {
+	if len(line) == 0 || line[0] != '%' {
+		return ""
+	}
+	if i := bytes.IndexByte(line, ' '); i >= 0 {
+		return string(line[:i])
+	}
+	return string(line)
+}
+
+// OutputWatcher follows the output of one tmux session at a time, switching
+// its control client as the watched session changes. It is safe for concurrent use.
+type OutputWatcher struct {
+	changes chan struct{} // Changes of whichever session is watched, coalesced like ControlClient's
+
+	mu         sync.Mutex
+	client     *ControlClient
+	retryAfter map[string]time.Time // Sessions whose control client failed, until when to poll them
+}
+
+// NewOutputWatcher creates a watcher that follows no session yet
+func NewOutputWatcher() *OutputWatcher {
+	return &OutputWatcher{
+		changes:    make(chan struct{}, 1),
+		retryAfter: make(map[string]time.Time),
+	}
+}
+
+// Watch makes the watcher follow a tmux session. It returns false when
+// control mode is unavailable for the session, in which case callers poll.
+func (w *OutputWatcher) Watch(t *TmuxSession) bool {
+	sessionName := t.GetSessionName()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.client != nil {
+		select {
+		case <-w.client.Done():
+			w.client = nil
+		default:
+			if w.client.follows(t) {
+				return true
+			}
+			go w.client.Close()
+			w.client = nil
+		}
+	}
+
+	if time.Now().Before(w.retryAfter[sessionName]) {
+		return false
+	}
+	client, err := NewControlClient(t.Server(), sessionName)
+	if err != nil {
+		debug.DebugLog("Polling %s: %v", sessionName, err)
+		w.retryAfter[sessionName] = time.Now().Add(controlRetryDelay)
+		return false
+	}
+	delete(w.retryAfter, sessionName)
+	w.client = client
+	go w.forward(client)
+	return true
+}
+
+// Changes delivers a value when the watched session's output may have
+// changed. A control client that exits counts as a change, so that callers
+// capture the session again and notice why.
+func (w *OutputWatcher) Changes() <-chan struct{} {
+	return w.changes
+}
+
+// forward passes a client's changes on until it exits. Clients replaced by
+// another session's are no longer watched, so their changes and exit are
+// dropped.
+func (w *OutputWatcher) forward(client *ControlClient) {
+	for {
+		select {
+		case <-client.Changes():
+			if w.watching(client) {
+				w.notify()
+			}
+		case <-client.Done():
+			if w.forget(client) {
+				w.notify()
+			}
+			return
+		}
+	}
+}
+
+// watching reports whether client is the watcher's current client
+func (w *OutputWatcher) watching(client *ControlClient) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.client == client
+}
+
+// notify records a change without blocking on an unread one
+func (w *OutputWatcher) notify() {
+	select {
+	case w.changes <- struct{}{}:
+	default:
+	}
+}
+
+// forget drops a client that exited and polls its session for a while. It
+// returns false if the client had already been replaced.
+func (w *OutputWatcher) forget(client *ControlClient) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.client != client {
+		return false
+	}
+	w.client = nil
+	w.retryAfter[client.SessionName()] = time.Now().Add(controlRetryDelay)
+	debug.DebugLog("Control client for %s ended: %v", client.SessionName(), client.Err())
+	return true
+}
+
+// Close detaches the watcher's control client
+func (w *OutputWatcher) Close() {
+	w.mu.Lock()
+	client := w.client
+	w.client = nil
+	w.mu.Unlock()
+	if client != nil {
+		client.Close()
+	}
+}
@@ -0,0 +1,180 @@
+package tmux
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PaneState describes what an agent appears to be doing based on its pane content
+type PaneState int
+
+const (
+	// PaneStateUnknown means no detection rule matched
+	PaneStateUnknown PaneState = iota
+	// PaneStateWorking means the agent is busy producing output
+	PaneStateWorking
+	// PaneStateWaitingForApproval means the agent is asking the user to confirm an action
+	PaneStateWaitingForApproval
+	// PaneStateIdle means the agent is sitting at its input prompt
+	PaneStateIdle
+	// PaneStateErrored means the agent reported an error
+	PaneStateErrored
+)
+
+// String returns a human-readable name for the state
+func (s PaneState) String() string {
+	switch s {
+	case PaneStateWorking:
+		return "working"
+	case PaneStateWaitingForApproval:
+		return "waiting for approval"
+	case PaneStateIdle:
+		return "idle"
+	case PaneStateErrored:
+		return "errored"
+	default:
+		return "unknown"
+	}
+}
+
+// DetectionRules lists regular expressions that identify each pane state.
+// Patterns are matched against the visible pane content with ANSI escape
+// sequences removed; use (?m) for line anchors.
+type DetectionRules struct {
+	WaitingForApproval []string
+	Idle               []string
+	Working            []string
+	Errored            []string
+}
+
+// IsEmpty reports whether no patterns are defined
+func (r DetectionRules) IsEmpty() bool {
+	return len(r.WaitingForApproval) == 0 && len(r.Idle) == 0 &&
+		len(r.Working) == 0 && len(r.Errored) == 0
+}
+
+// Merge returns a copy of r where every non-empty list in override replaces
+// the corresponding list in r
+func (r DetectionRules) Merge(override DetectionRules) DetectionRules {
+	merged := r.Clone()
+	if len(override.WaitingForApproval) > 0 {
+		merged.WaitingForApproval = append([]string{}, override.WaitingForApproval...)
+	}
+	if len(override.Idle) > 0 {
+		merged.Idle = append([]string{}, override.Idle...)
+	}
+	if len(override.Working) > 0 {
+		merged.Working = append([]string{}, override.Working...)
+	}
+	if len(override.Errored) > 0 {
+		merged.Errored = append([]string{}, override.Errored...)
+	}
+	return merged
+}
+
+// Clone returns a deep copy of the rules
+func (r DetectionRules) Clone() DetectionRules {
+	return DetectionRules{
+		WaitingForApproval: cloneStrings(r.WaitingForApproval),
+		Idle:               cloneStrings(r.Idle),
+		Working:            cloneStrings(r.Working),
+		Errored:            cloneStrings(r.Errored),
+	}
+}
+
+// Validate checks that every pattern compiles
+func (r DetectionRules) Validate() error {
+	_, err := NewDetector(r)
+	return err
+}
+
+// GenericDetectionRules are used for agents without rules of their own
+var GenericDetectionRules = DetectionRules{
+	WaitingForApproval: []string{`(?i)\[y/n\]`, `(?i)\(y/n\)`, `\(Y\)es/\(N\)o`},
+	Idle:               []string{`[>$:]\s*\z`},
+}
+
+// Detector classifies pane content using compiled detection rules
+type Detector struct {
+	rules []detectionRule
+}
+
+// detectionRule pairs a state with the patterns that identify it
+type detectionRule struct {
+	state    PaneState
+	patterns []*regexp.Regexp
+}
+
+// NewDetector compiles the given rules. States are checked in order of
+// urgency: waiting for approval, errored, working, then idle.
+func NewDetector(rules DetectionRules) (*Detector, error) {
+	ordered := []struct {
+		state    PaneState
+		name     string
+		patterns []string
+	}{
+		{PaneStateWaitingForApproval, "waiting_for_approval", rules.WaitingForApproval},
+		{PaneStateErrored, "errored", rules.Errored},
+		{PaneStateWorking, "working", rules.Working},
+		{PaneStateIdle, "idle", rules.Idle},
+	}
+
+	detector := &Detector{}
+	var errs []error
+	for _, entry := range ordered {
+		rule := detectionRule{state: entry.state}
+		for _, pattern := range entry.patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s pattern %q: %w", entry.name, pattern, err))
+				continue
+			}
+			rule.patterns = append(rule.patterns, re)
+		}
+		if len(rule.patterns) > 0 {
+			detector.rules = append(detector.rules, rule)
+		}
+	}
+
+	return detector, errors.Join(errs...)
+}
+
+// Detect returns the most urgent state whose patterns match the content
+func (d *Detector) Detect(content string) PaneState {
+	if d == nil {
+		return PaneStateUnknown
+	}
+
+	plain := strings.TrimRight(StripANSI(content), " \t\r\n")
+	for _, rule := range d.rules {
+		for _, re := range rule.patterns {
+			if re.MatchString(plain) {
+				return rule.state
+			}
+		}
+	}
+	return PaneStateUnknown
+}
+
+// ansiSequenceRegex matches CSI, OSC and charset escape sequences
+var ansiSequenceRegex = regexp.MustCompile(`\x1b\[[0-9;?:=]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[\(\)][0-9A-Za-z]`)
+
+// StripANSI removes terminal escape sequences from captured pane content
+func StripANSI(content string) string {
+	return ansiSequenceRegex.ReplaceAllString(content, "")
+}
+
+// ANSISequenceIndexes returns the byte ranges of the escape sequences in content
+func ANSISequenceIndexes(content string) [][]int {
+	return ansiSequenceRegex.FindAllStringIndex(content, -1)
+}
+
+// cloneStrings copies a string slice, preserving nil
+func cloneStrings(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	return append([]string{}, values...)
+}
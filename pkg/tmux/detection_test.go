@@ -0,0 +1,215 @@
+package tmux_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"agate/pkg/app"
+	"agate/pkg/config"
+	"agate/pkg/tmux"
+)
+
+// readPane loads a pane captured with escape sequences from testdata/panes
+func readPane(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "panes", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestDetectCapturedPanes(t *testing.T) {
+	tests := []struct {
+		pane  string
+		rules tmux.DetectionRules
+		want  tmux.PaneState
+	}{
+		{"claude_idle.txt", app.ClaudeAgent.Detection, tmux.PaneStateIdle},
+		{"claude_working.txt", app.ClaudeAgent.Detection, tmux.PaneStateWorking},
+		{"claude_approval.txt", app.ClaudeAgent.Detection, tmux.PaneStateWaitingForApproval},
+		{"claude_errored.txt", app.ClaudeAgent.Detection, tmux.PaneStateErrored},
+		{"codex_idle.txt", app.CodexAgent.Detection, tmux.PaneStateIdle},
+		{"codex_working.txt", app.CodexAgent.Detection, tmux.PaneStateWorking},
+		{"codex_approval.txt", app.CodexAgent.Detection, tmux.PaneStateWaitingForApproval},
+		{"codex_errored.txt", app.CodexAgent.Detection, tmux.PaneStateErrored},
+		{"gemini_idle.txt", app.GeminiAgent.Detection, tmux.PaneStateIdle},
+		{"gemini_working.txt", app.GeminiAgent.Detection, tmux.PaneStateWorking},
+		{"gemini_approval.txt", app.GeminiAgent.Detection, tmux.PaneStateWaitingForApproval},
+		{"generic_idle.txt", tmux.GenericDetectionRules, tmux.PaneStateIdle},
+		{"generic_approval.txt", tmux.GenericDetectionRules, tmux.PaneStateWaitingForApproval},
+		{"generic_unknown.txt", tmux.GenericDetectionRules, tmux.PaneStateUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pane, func(t *testing.T) {
+			detector, err := tmux.NewDetector(tt.rules)
+			if err != nil {
+				t.Fatalf("NewDetector: %v", err)
+			}
+			if got := detector.Detect(readPane(t, tt.pane)); got != tt.want {
+				t.Errorf("Detect = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectPrecedence(t *testing.T) {
+	// A pane showing every state's marker at once
+	content := "APPROVE\nERROR\nWORKING\nIDLE\n"
+	tests := []struct {
+		name  string
+		rules tmux.DetectionRules
+		want  tmux.PaneState
+	}{
+		{"approval over errored", tmux.DetectionRules{
+			WaitingForApproval: []string{"APPROVE"}, Errored: []string{"ERROR"},
+			Working: []string{"WORKING"}, Idle: []string{"IDLE"},
+		}, tmux.PaneStateWaitingForApproval},
+		{"errored over working", tmux.DetectionRules{
+			Errored: []string{"ERROR"}, Working: []string{"WORKING"}, Idle: []string{"IDLE"},
+		}, tmux.PaneStateErrored},
+		{"working over idle", tmux.DetectionRules{
+			Working: []string{"WORKING"}, Idle: []string{"IDLE"},
+		}, tmux.PaneStateWorking},
+		{"idle", tmux.DetectionRules{Idle: []string{"IDLE"}}, tmux.PaneStateIdle},
+		{"no match", tmux.DetectionRules{Idle: []string{"PROMPT"}}, tmux.PaneStateUnknown},
+		{"no rules", tmux.DetectionRules{}, tmux.PaneStateUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detector, err := tmux.NewDetector(tt.rules)
+			if err != nil {
+				t.Fatalf("NewDetector: %v", err)
+			}
+			if got := detector.Detect(content); got != tt.want {
+				t.Errorf("Detect = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	var detector *tmux.Detector
+	if got := detector.Detect(content); got != tmux.PaneStateUnknown {
+		t.Errorf("nil Detector.Detect = %v, want %v", got, tmux.PaneStateUnknown)
+	}
+}
+
+func TestStripANSI(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"plain", "no escapes here", "no escapes here"},
+		{"SGR", "\x1b[1;38;2;215;119;87mbold\x1b[0m text", "bold text"},
+		{"private mode", "\x1b[?25lhidden cursor\x1b[?25h", "hidden cursor"},
+		{"cursor movement", "a\x1b[2Kb\x1b[10;4Hc", "abc"},
+		{"OSC ended by BEL", "\x1b]0;user@host: ~\x07$ ", "$ "},
+		{"OSC ended by ST", "\x1b]8;;https://agate.sh\x1b\\link\x1b]8;;\x1b\\", "link"},
+		{"charset", "\x1b(Bline\x1b)0", "line"},
+		{"unicode kept", "\x1b[2m⏎ send\x1b[22m", "⏎ send"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tmux.StripANSI(tt.content); got != tt.want {
+				t.Errorf("StripANSI(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectionRulesMerge(t *testing.T) {
+	base := app.CodexAgent.Detection
+	override := tmux.DetectionRules{
+		Idle:    []string{`ready>`},
+		Errored: []string{`(?i)fatal`},
+	}
+
+	merged := base.Merge(override)
+	want := tmux.DetectionRules{
+		WaitingForApproval: base.WaitingForApproval,
+		Idle:               []string{`ready>`},
+		Working:            base.Working,
+		Errored:            []string{`(?i)fatal`},
+	}
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("Merge = %+v, want %+v", merged, want)
+	}
+
+	// The merged rules must not share storage with either input
+	merged.Idle[0] = "changed"
+	merged.Working[0] = "changed"
+	if override.Idle[0] != `ready>` || base.Working[0] == "changed" {
+		t.Error("Merge result aliases its inputs")
+	}
+
+	if got := base.Merge(tmux.DetectionRules{}); !reflect.DeepEqual(got, base) {
+		t.Errorf("Merge with empty override = %+v, want %+v", got, base)
+	}
+}
+
+func TestDetectionRulesValidate(t *testing.T) {
+	if err := app.ClaudeAgent.Detection.Validate(); err != nil {
+		t.Errorf("built-in claude rules: %v", err)
+	}
+
+	rules := tmux.DetectionRules{
+		Idle:    []string{`ok>`, `(unclosed`},
+		Working: []string{`[bad`},
+	}
+	err := rules.Validate()
+	if err == nil {
+		t.Fatal("Validate accepted invalid patterns")
+	}
+	for _, want := range []string{`invalid idle pattern "(unclosed"`, `invalid working pattern "[bad"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate error %q does not mention %s", err, want)
+		}
+	}
+}
+
+func TestDetectionOverridesFromConfig(t *testing.T) {
+	var settings config.Settings
+	err := json.Unmarshal([]byte(`{
+		"agents": [
+			{"name": "codex", "detection": {"idle": ["READY>"]}},
+			{"name": "gemini", "detection": {"working": ["(broken"]}}
+		]
+	}`), &settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = app.RegisterAgents(nil) })
+	err = app.RegisterAgents(settings.Agents)
+	if err == nil || !strings.Contains(err.Error(), `agent "gemini" detection rules`) {
+		t.Errorf("RegisterAgents error = %v, want the invalid gemini override reported", err)
+	}
+
+	// The valid override replaces only codex's idle patterns
+	codex := app.GetAgentConfig("codex").Detection
+	detector, err := tmux.NewDetector(codex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := detector.Detect(readPane(t, "codex_idle.txt")); got != tmux.PaneStateUnknown {
+		t.Errorf("codex idle pane with overridden idle rules = %v, want %v", got, tmux.PaneStateUnknown)
+	}
+	if got := detector.Detect("READY>"); got != tmux.PaneStateIdle {
+		t.Errorf("overridden idle pattern = %v, want %v", got, tmux.PaneStateIdle)
+	}
+	if got := detector.Detect(readPane(t, "codex_working.txt")); got != tmux.PaneStateWorking {
+		t.Errorf("codex working pane = %v, want %v", got, tmux.PaneStateWorking)
+	}
+
+	// The invalid override is dropped, keeping gemini's built-in rules
+	if got := app.GetAgentConfig("gemini").Detection; !reflect.DeepEqual(got, app.GeminiAgent.Detection) {
+		t.Errorf("gemini rules = %+v, want the built-in %+v", got, app.GeminiAgent.Detection)
+	}
+}
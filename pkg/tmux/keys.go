@@ -0,0 +1,146 @@
+package tmux
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Key is a keystroke for a program: literal text, or a key named in tmux's
+// notation, e.g. "Enter", "C-c" or "M-Up"
+type Key struct {
+	Text string // Typed as it is when set
+	Name string
+}
+
+// cursorKeys are the final bytes of the cursor keys' escape sequences
+var cursorKeys = map[string]byte{
+	"Up":    'A',
+	"Down":  'B',
+	"Right": 'C',
+	"Left":  'D',
+	"Home":  'H',
+	"End":   'F',
+}
+
+// tildeKeys are the numbers of the keys sent as CSI <number> ~
+var tildeKeys = map[string]int{
+	"IC":    2,
+	"DC":    3,
+	"PPage": 5,
+	"NPage": 6,
+	"F5":    15,
+	"F6":    17,
+	"F7":    18,
+	"F8":    19,
+	"F9":    20,
+	"F10":   21,
+	"F11":   23,
+	"F12":   24,
+}
+
+// plainKeys are the bytes of named keys without modifiers
+var plainKeys = map[string]string{
+	"Enter":  "\r",
+	"Tab":    "\t",
+	"BTab":   "\x1b[Z",
+	"BSpace": "\x7f",
+	"Escape": "\x1b",
+	"Space":  " ",
+	"F1":     "\x1bOP",
+	"F2":     "\x1bOQ",
+	"F3":     "\x1bOR",
+	"F4":     "\x1bOS",
+}
+
+// keySequence translates a key in tmux's notation into the bytes an xterm
+// sends for it. appCursor selects the cursor keys' application mode.
+func keySequence(name string, appCursor bool) ([]byte, error) {
+	// Strip the modifiers; a name may also end in a dash, as in "M--"
+	ctrl, meta, shift := false, false, false
+	base := name
+	for len(base) > 2 && base[1] == '-' {
+		switch base[0] {
+		case 'C':
+			ctrl = true
+		case 'M':
+			meta = true
+		case 'S':
+			shift = true
+		default:
+			return nil, fmt.Errorf("unknown key %q", name)
+		}
+		base = base[2:]
+	}
+
+	// xterm's modifier parameter for keys that carry modifiers in their sequence
+	modifier := 1
+	if shift {
+		modifier++
+	}
+	if meta {
+		modifier += 2
+	}
+	if ctrl {
+		modifier += 4
+	}
+
+	if final, ok := cursorKeys[base]; ok {
+		switch {
+		case modifier > 1:
+			return fmt.Appendf(nil, "\x1b[1;%d%c", modifier, final), nil
+		case appCursor:
+			return []byte{0x1b, 'O', final}, nil
+		}
+		return []byte{0x1b, '[', final}, nil
+	}
+	if number, ok := tildeKeys[base]; ok {
+		if modifier > 1 {
+			return fmt.Appendf(nil, "\x1b[%d;%d~", number, modifier), nil
+		}
+		return fmt.Appendf(nil, "\x1b[%d~", number), nil
+	}
+
+	var sequence string
+	if plain, ok := plainKeys[base]; ok {
+		sequence = plain
+		if shift && base == "Tab" {
+			sequence = plainKeys["BTab"]
+		}
+		if ctrl && base == "Space" {
+			sequence = "\x00"
+		}
+	} else if r, size := utf8.DecodeRuneInString(base); size == len(base) && r != utf8.RuneError {
+		if shift {
+			r = []rune(strings.ToUpper(string(r)))[0]
+		}
+		sequence = string(r)
+		if ctrl {
+			control, ok := controlByte(r)
+			if !ok {
+				return nil, fmt.Errorf("unknown key %q", name)
+			}
+			sequence = string([]byte{control})
+		}
+	} else {
+		return nil, fmt.Errorf("unknown key %q", name)
+	}
+
+	if meta {
+		sequence = "\x1b" + sequence
+	}
+	return []byte(sequence), nil
+}
+
+// controlByte returns the byte Ctrl plus a character sends
+func controlByte(r rune) (byte, bool) {
+	switch {
+	case r >= 'a' && r <= 'z':
+		return byte(r - 'a' + 1), true
+	case r >= '@' && r <= '_':
+		return byte(r - '@'), true
+	case r == '?':
+		return 0x7f, true
+	}
+	return 0, false
+}
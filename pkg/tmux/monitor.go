@@ -4,20 +4,20 @@ package tmux
 
 import (
 	"crypto/sha256"
-	"strings"
 )
 
 // StatusMonitor tracks changes in tmux session output
 type StatusMonitor struct {
 	prevOutputHash []byte
-	program        string
+	detector       *Detector
+	state          PaneState
 }
 
-// newStatusMonitor creates a new status monitor for a tmux session
-func newStatusMonitor(program string) *StatusMonitor {
+// newStatusMonitor creates a new status monitor using the given detector
+func newStatusMonitor(detector *Detector) *StatusMonitor {
 	return &StatusMonitor{
 		prevOutputHash: make([]byte, 0),
-		program:        program,
+		detector:       detector,
 	}
 }
 
@@ -29,21 +29,8 @@ func (m *StatusMonitor) hash(content string) []byte {
 
 // HasUpdated checks if the content has changed and if there's a prompt waiting
 func (m *StatusMonitor) HasUpdated(content string) (updated bool, hasPrompt bool) {
-	// Check for prompts specific to different AI programs
-	switch m.program {
-	case "claude":
-		hasPrompt = strings.Contains(content, "No, and tell Claude what to do differently")
-	case "aider":
-		hasPrompt = strings.Contains(content, "(Y)es/(N)o/(D)on't ask again")
-	case "codex":
-		// Add specific prompt detection for codex if needed
-		hasPrompt = false
-	default:
-		// Generic prompt detection - look for common patterns
-		hasPrompt = strings.HasSuffix(strings.TrimSpace(content), ">") ||
-			strings.HasSuffix(strings.TrimSpace(content), "$") ||
-			strings.HasSuffix(strings.TrimSpace(content), ":")
-	}
+	m.state = m.detector.Detect(content)
+	hasPrompt = m.state == PaneStateWaitingForApproval
 
 	// Check if content has changed
 	currentHash := m.hash(content)
@@ -55,6 +42,11 @@ func (m *StatusMonitor) HasUpdated(content string) (updated bool, hasPrompt bool
 	return false, hasPrompt
 }
 
+// State returns the pane state detected by the last HasUpdated call
+func (m *StatusMonitor) State() PaneState {
+	return m.state
+}
+
 // bytesEqual compares two byte slices for equality
 func bytesEqual(a, b []byte) bool {
 	if len(a) != len(b) {
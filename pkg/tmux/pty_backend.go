@@ -0,0 +1,616 @@
+package tmux
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"agate/internal/debug"
+
+	"github.com/creack/pty"
+	"github.com/hinshun/vt10x"
+	"golang.org/x/term"
+)
+
+// Size of a PTY session's terminal until the preview sets one
+const (
+	defaultPtyCols = 80
+	defaultPtyRows = 24
+)
+
+// ptyTerm is the TERM PTY sessions advertise; the emulator understands xterm's sequences
+const ptyTerm = "xterm-256color"
+
+// Bracketed paste mode, which the emulator doesn't track itself
+var (
+	bracketedPasteOn  = []byte("\x1b[?2004h")
+	bracketedPasteOff = []byte("\x1b[?2004l")
+)
+
+// Glyph attributes, mirroring the bits vt10x keeps unexported
+const (
+	glyphReverse   = 1 << 0
+	glyphUnderline = 1 << 1
+	glyphBold      = 1 << 2
+	glyphItalic    = 1 << 4
+	glyphBlink     = 1 << 5
+
+	glyphStyle = glyphReverse | glyphUnderline | glyphBold | glyphItalic | glyphBlink
+)
+
+// PtySession runs a program directly on a PTY of agate's own, with an
+// in-process terminal emulator in place of tmux. The program doesn't outlive
+// agate, and there is no scrollback beyond the visible screen.
+type PtySession struct {
+	// Session identification
+	name          string
+	sanitizedName string
+	program       string
+	args          []string          // Extra arguments appended to program
+	env           map[string]string // Environment variables set for the program
+	remainOnExit  bool              // Keep the screen around after the program exits
+
+	ptyFactory PtyFactory
+
+	// Status monitoring
+	monitor  *StatusMonitor
+	detector *Detector
+
+	changes chan struct{} // Holds one pending change; further changes coalesce into it
+
+	mu             sync.Mutex
+	cmd            *exec.Cmd
+	ptmx           *os.File
+	vt             vt10x.Terminal
+	exited         chan struct{} // Closed once the program has exited
+	exitStatus     int           // Exit status once exited, -1 if it was killed by a signal
+	killed         bool
+	bracketedPaste bool          // The program asked for bracketed paste
+	attachCh       chan struct{} // Closed on detach; nil while detached
+
+	// Terminal dimensions while detached
+	width  int
+	height int
+}
+
+// NewPtySession creates a PTY session for a program
+func NewPtySession(name, program string) *PtySession {
+	detector, _ := NewDetector(GenericDetectionRules)
+	return &PtySession{
+		name:          name,
+		sanitizedName: SanitizeName(name),
+		program:       program,
+		ptyFactory:    NewPtyFactory(),
+		monitor:       newStatusMonitor(detector),
+		detector:      detector,
+		changes:       make(chan struct{}, 1),
+	}
+}
+
+// SetPtyFactory sets a custom PTY factory (useful for testing)
+func (p *PtySession) SetPtyFactory(factory PtyFactory) {
+	p.ptyFactory = factory
+}
+
+// SetArgs sets extra arguments passed to the program when the session starts
+func (p *PtySession) SetArgs(args []string) {
+	p.args = append([]string{}, args...)
+}
+
+// SetEnv sets environment variables applied to the program when it starts
+func (p *PtySession) SetEnv(env map[string]string) {
+	p.env = make(map[string]string, len(env))
+	for key, value := range env {
+		p.env[key] = value
+	}
+}
+
+// SetDetectionRules replaces the rules used to detect the agent's state.
+// Invalid patterns are reported and skipped; the remaining ones still apply.
+func (p *PtySession) SetDetectionRules(rules DetectionRules) error {
+	if rules.IsEmpty() {
+		rules = GenericDetectionRules
+	}
+	detector, err := NewDetector(rules)
+	p.detector = detector
+	p.monitor = newStatusMonitor(detector)
+	return err
+}
+
+// SetRemainOnExit keeps the screen after the program exits so its exit
+// status can be inspected with PaneStatus
+func (p *PtySession) SetRemainOnExit(remain bool) {
+	p.remainOnExit = remain
+}
+
+// GetProgram returns the program run inside the session
+func (p *PtySession) GetProgram() string {
+	return p.program
+}
+
+// GetSessionName returns the sanitized session name
+func (p *PtySession) GetSessionName() string {
+	return p.sanitizedName
+}
+
+// Changes delivers a value when the session's screen changed since the last one was received
+func (p *PtySession) Changes() <-chan struct{} {
+	return p.changes
+}
+
+// notify records a change without blocking on an unread one
+func (p *PtySession) notify() {
+	select {
+	case p.changes <- struct{}{}:
+	default:
+	}
+}
+
+// running reports whether the program runs; p.mu must be held
+func (p *PtySession) running() bool {
+	return p.vt != nil && !p.killed && !isClosed(p.exited)
+}
+
+// isClosed reports whether a channel has been closed, without blocking
+func isClosed(ch chan struct{}) bool {
+	if ch == nil {
+		return false
+	}
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// Start runs the program on a new PTY unless it already runs
+func (p *PtySession) Start(workDir string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running() {
+		return nil
+	}
+
+	// env(1) and the quoting in commandLine assume a POSIX shell, whatever the user's is
+	cmd := exec.Command("sh", "-c", commandLine(p.program, p.args, p.env))
+	cmd.Dir = workDir
+	cmd.Env = append(os.Environ(), "TERM="+ptyTerm)
+
+	ptmx, err := p.ptyFactory.Start(cmd)
+	if err != nil {
+		return fmt.Errorf("error starting PTY session %s: %w", p.sanitizedName, err)
+	}
+
+	cols, rows := p.width, p.height
+	if cols <= 0 || rows <= 0 {
+		cols, rows = defaultPtyCols, defaultPtyRows
+	}
+	if err := pty.Setsize(ptmx, &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)}); err != nil {
+		debug.DebugLog("Failed to size PTY for %s: %v", p.sanitizedName, err)
+	}
+
+	p.cmd = cmd
+	p.ptmx = ptmx
+	p.vt = vt10x.New(vt10x.WithSize(cols, rows), vt10x.WithWriter(ptmx))
+	p.exited = make(chan struct{})
+	p.exitStatus = 0
+	p.killed = false
+	p.bracketedPaste = false
+
+	go p.readLoop(ptmx, p.vt)
+	go p.waitLoop(cmd, p.exited)
+	return nil
+}
+
+// readLoop feeds the program's output to the emulator, and to the terminal
+// while attached, until the PTY closes
+func (p *PtySession) readLoop(ptmx *os.File, vt vt10x.Terminal) {
+	buf := make([]byte, 32*1024)
+	var pending []byte // Start of a UTF-8 sequence split across reads
+	for {
+		n, err := ptmx.Read(buf)
+		if n > 0 {
+			data := append(pending, buf[:n]...)
+			p.mu.Lock()
+			written, _ := vt.Write(data)
+			if p.vt == vt {
+				if on, off := bytes.LastIndex(data, bracketedPasteOn), bytes.LastIndex(data, bracketedPasteOff); on != off {
+					p.bracketedPaste = on > off
+				}
+				if p.attachCh != nil {
+					_, _ = os.Stdout.Write(data[:written])
+				}
+			}
+			p.mu.Unlock()
+			pending = append([]byte(nil), data[written:]...)
+			p.notify()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// waitLoop records the program's exit status once it exits and detaches the
+// terminal from it
+func (p *PtySession) waitLoop(cmd *exec.Cmd, exited chan struct{}) {
+	status := 0
+	if err := cmd.Wait(); err != nil {
+		status = -1
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() >= 0 {
+			status = exitErr.ExitCode()
+		}
+	}
+
+	p.mu.Lock()
+	current := p.cmd == cmd
+	if current {
+		p.exitStatus = status
+	}
+	p.mu.Unlock()
+	close(exited)
+
+	if current {
+		debug.DebugLog("Program in PTY session %s exited with status %d", p.sanitizedName, status)
+		p.Detach()
+		p.notify()
+	}
+}
+
+// Restore succeeds only while the program still runs, as a PTY session
+// doesn't outlive the agate process that started it
+func (p *PtySession) Restore() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.running() {
+		return fmt.Errorf("PTY session %s is not running", p.sanitizedName)
+	}
+	return nil
+}
+
+// SessionExists reports whether the program runs, or exited and left its
+// screen behind for remain-on-exit
+func (p *PtySession) SessionExists() (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.vt == nil || p.killed {
+		return false, nil
+	}
+	return p.remainOnExit || !isClosed(p.exited), nil
+}
+
+// PaneStatus reports whether the program has exited
+func (p *PtySession) PaneStatus() (PaneStatus, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.vt == nil || p.killed {
+		return PaneStatus{}, fmt.Errorf("PTY session %s is not running", p.sanitizedName)
+	}
+	if !isClosed(p.exited) {
+		return PaneStatus{}, nil
+	}
+	return PaneStatus{Dead: true, ExitStatus: p.exitStatus}, nil
+}
+
+// Kill hangs up on the program and closes its PTY
+func (p *PtySession) Kill() error {
+	p.Detach()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.vt == nil || p.killed {
+		return nil
+	}
+	p.killed = true
+	if !isClosed(p.exited) {
+		killProcessGroup(p.cmd)
+	}
+	if err := p.ptmx.Close(); err != nil {
+		debug.DebugLog("Failed to close PTY during session kill: %v", err)
+	}
+	return nil
+}
+
+// CapturePaneContent renders the emulated screen with ANSI escape sequences,
+// one line per row like tmux capture-pane
+func (p *PtySession) CapturePaneContent() (string, error) {
+	p.mu.Lock()
+	vt := p.vt
+	p.mu.Unlock()
+	if vt == nil {
+		return "", fmt.Errorf("error capturing pane content: PTY session %s has not started", p.sanitizedName)
+	}
+
+	lines, _, _ := renderScreen(vt)
+	return joinLines(lines), nil
+}
+
+// CapturePaneContentWithOptions captures the visible lines between startLine
+// and endLine; there is no scrollback to capture from
+func (p *PtySession) CapturePaneContentWithOptions(startLine, endLine int) (string, error) {
+	p.mu.Lock()
+	vt := p.vt
+	p.mu.Unlock()
+	if vt == nil {
+		return "", fmt.Errorf("error capturing pane content: PTY session %s has not started", p.sanitizedName)
+	}
+
+	lines, _, _ := renderScreen(vt)
+	startLine = max(startLine, 0)
+	endLine = min(endLine, len(lines)-1)
+	if startLine > endLine {
+		return "", nil
+	}
+	return joinLines(lines[startLine : endLine+1]), nil
+}
+
+// HistorySize is always 0; the emulator keeps no scrollback
+func (p *PtySession) HistorySize() (int, error) {
+	return 0, nil
+}
+
+// joinLines terminates each line with a newline like tmux capture-pane
+func joinLines(lines []string) string {
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// HasContentUpdated checks if already captured pane content differs from the last check
+func (p *PtySession) HasContentUpdated(content string) (updated bool, hasPrompt bool) {
+	return p.monitor.HasUpdated(content)
+}
+
+// Classify detects the agent's state from captured pane content
+func (p *PtySession) Classify(content string) PaneState {
+	return p.detector.Detect(content)
+}
+
+// write sends input to the program
+func (p *PtySession) write(data []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.running() {
+		return fmt.Errorf("PTY session %s is not running", p.sanitizedName)
+	}
+	_, err := p.ptmx.Write(data)
+	return err
+}
+
+// SendKeys types text into the program. Unlike tmux send-keys, key names
+// aren't translated; keys are sent as they are.
+func (p *PtySession) SendKeys(keys string) error {
+	return p.write([]byte(keys))
+}
+
+// SendKey types one keystroke into the program, encoded like xterm does
+func (p *PtySession) SendKey(key Key) error {
+	if key.Text != "" {
+		return p.write([]byte(key.Text))
+	}
+
+	p.mu.Lock()
+	vt := p.vt
+	p.mu.Unlock()
+	appCursor := false
+	if vt != nil {
+		vt.Lock()
+		appCursor = vt.Mode()&vt10x.ModeAppCursor != 0
+		vt.Unlock()
+	}
+
+	sequence, err := keySequence(key.Name, appCursor)
+	if err != nil {
+		return err
+	}
+	return p.write(sequence)
+}
+
+// PasteText pastes text into the program. Bracketed paste is used when the
+// program asks for it, so newlines in the text don't submit it early.
+func (p *PtySession) PasteText(text string) error {
+	p.mu.Lock()
+	bracketed := p.bracketedPaste
+	p.mu.Unlock()
+	if bracketed {
+		text = "\x1b[200~" + text + "\x1b[201~"
+	}
+	return p.write([]byte(text))
+}
+
+// TapEnter sends an Enter key to the program
+func (p *PtySession) TapEnter() error {
+	return p.SendKeys("\r")
+}
+
+// SetDetachedSize sets the size for detached mode
+func (p *PtySession) SetDetachedSize(width, height int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.width, p.height = width, height
+	if p.attachCh != nil {
+		return nil
+	}
+	return p.resize(width, height)
+}
+
+// resize resizes the PTY and the emulated screen; p.mu must be held
+func (p *PtySession) resize(cols, rows int) error {
+	if cols <= 0 || rows <= 0 || !p.running() {
+		return nil
+	}
+	p.vt.Resize(cols, rows)
+	return pty.Setsize(p.ptmx, &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)})
+}
+
+// Attach hands the terminal to the program until the detach key detaches or
+// the program exits
+func (p *PtySession) Attach() (chan struct{}, error) {
+	p.mu.Lock()
+	if !p.running() {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("PTY session %s is not running", p.sanitizedName)
+	}
+	if p.attachCh != nil {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("PTY session %s is already attached", p.sanitizedName)
+	}
+	attachCh := make(chan struct{})
+	p.attachCh = attachCh
+	p.mu.Unlock()
+
+	p.fitTerminal()
+	go p.forwardInput(attachCh)
+	go watchResize(attachCh, p.fitTerminal)
+	return attachCh, nil
+}
+
+// fitTerminal resizes the session to the terminal while attached and redraws it
+func (p *PtySession) fitTerminal() {
+	cols, rows, err := term.GetSize(int(os.Stdin.Fd()))
+	if err != nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.attachCh == nil {
+		return
+	}
+	if err := p.resize(cols, rows); err != nil {
+		debug.DebugLog("Failed to resize PTY session %s: %v", p.sanitizedName, err)
+	}
+
+	lines, cursor, cursorVisible := renderScreen(p.vt)
+	var b strings.Builder
+	b.WriteString("\x1b[0m\x1b[H\x1b[2J")
+	b.WriteString(strings.Join(lines, "\r\n"))
+	fmt.Fprintf(&b, "\x1b[%d;%dH", cursor.Y+1, cursor.X+1)
+	if cursorVisible {
+		b.WriteString("\x1b[?25h")
+	} else {
+		b.WriteString("\x1b[?25l")
+	}
+	_, _ = os.Stdout.WriteString(b.String())
+}
+
+// forwardInput passes stdin on to the program until the detach key detaches
+// or the program exits
+func (p *PtySession) forwardInput(attachCh chan struct{}) {
+	detached := forwardAttachInput(os.Stdin, attachCh, func(data []byte) {
+		_ = p.write(data)
+	})
+	if detached {
+		p.Detach()
+	}
+}
+
+// Detach hands the terminal back to agate and restores the preview's size
+func (p *PtySession) Detach() {
+	p.mu.Lock()
+	attachCh := p.attachCh
+	p.attachCh = nil
+	if attachCh != nil {
+		if err := p.resize(p.width, p.height); err != nil {
+			debug.DebugLog("Failed to resize PTY session %s: %v", p.sanitizedName, err)
+		}
+	}
+	p.mu.Unlock()
+
+	if attachCh != nil {
+		close(attachCh)
+	}
+}
+
+// renderScreen renders each row of the emulated screen with SGR sequences
+// for its colors and attributes, and returns where the cursor is
+func renderScreen(vt vt10x.Terminal) (lines []string, cursor vt10x.Cursor, cursorVisible bool) {
+	vt.Lock()
+	defer vt.Unlock()
+
+	cols, rows := vt.Size()
+	lines = make([]string, rows)
+	for y := 0; y < rows; y++ {
+		lines[y] = renderRow(vt, y, cols)
+	}
+	return lines, vt.Cursor(), vt.CursorVisible()
+}
+
+// renderRow renders one row, leaving out trailing blanks like tmux does
+func renderRow(vt vt10x.Terminal, y, cols int) string {
+	end := cols
+	for end > 0 && isBlank(vt.Cell(end-1, y)) {
+		end--
+	}
+
+	var b strings.Builder
+	style := vt10x.Glyph{FG: vt10x.DefaultFG, BG: vt10x.DefaultBG}
+	for x := 0; x < end; x++ {
+		cell := vt.Cell(x, y)
+		cell.Mode &= glyphStyle
+		if cell.Mode != style.Mode || cell.FG != style.FG || cell.BG != style.BG {
+			b.WriteString(sgr(cell))
+			style = cell
+		}
+		if cell.Char == 0 {
+			cell.Char = ' '
+		}
+		b.WriteRune(cell.Char)
+	}
+	if style.Mode != 0 || style.FG != vt10x.DefaultFG || style.BG != vt10x.DefaultBG {
+		b.WriteString("\x1b[0m")
+	}
+	return b.String()
+}
+
+// isBlank reports whether a cell shows nothing
+func isBlank(cell vt10x.Glyph) bool {
+	return (cell.Char == ' ' || cell.Char == 0) && cell.BG == vt10x.DefaultBG && cell.Mode&(glyphReverse|glyphUnderline) == 0
+}
+
+// sgr returns the escape sequence selecting a cell's colors and attributes
+func sgr(cell vt10x.Glyph) string {
+	params := []string{"0"}
+	if cell.Mode&glyphBold != 0 {
+		params = append(params, "1")
+	}
+	if cell.Mode&glyphItalic != 0 {
+		params = append(params, "3")
+	}
+	if cell.Mode&glyphUnderline != 0 {
+		params = append(params, "4")
+	}
+	if cell.Mode&glyphBlink != 0 {
+		params = append(params, "5")
+	}
+	if cell.Mode&glyphReverse != 0 {
+		params = append(params, "7")
+	}
+	params = appendColor(params, cell.FG, 30)
+	params = appendColor(params, cell.BG, 40)
+	return "\x1b[" + strings.Join(params, ";") + "m"
+}
+
+// appendColor appends the SGR parameters for a color; base is 30 for the
+// foreground and 40 for the background
+func appendColor(params []string, color vt10x.Color, base int) []string {
+	switch {
+	case color < 8:
+		return append(params, fmt.Sprint(base+int(color)))
+	case color < 16:
+		return append(params, fmt.Sprint(base+60+int(color)-8))
+	case color < 256:
+		return append(params, fmt.Sprintf("%d;5;%d", base+8, color))
+	case color < 1<<24:
+		return append(params, fmt.Sprintf("%d;2;%d;%d;%d", base+8, color>>16&0xff, color>>8&0xff, color&0xff))
+	}
+	// Default colors
+	return params
+}
@@ -0,0 +1,302 @@
+package tmux
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/creack/pty"
+)
+
+// startPty starts a program on a PTY session that is killed when the test ends
+func startPty(t *testing.T, program string, args ...string) *PtySession {
+	t.Helper()
+	session := NewPtySession("test-"+t.Name(), program)
+	session.SetArgs(args)
+	session.SetRemainOnExit(true)
+	if err := session.Start(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = session.Kill() })
+	return session
+}
+
+// waitForScreen waits until the session's screen, without escape sequences,
+// satisfies ok and returns it as captured with them
+func waitForScreen(t *testing.T, session *PtySession, what string, ok func(screen string) bool) string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		content, err := session.CapturePaneContent()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok(StripANSI(content)) {
+			return content
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("screen never showed %s:\n%s", what, StripANSI(content))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// waitForText waits until the session's screen shows text
+func waitForText(t *testing.T, session *PtySession, text string) string {
+	t.Helper()
+	return waitForScreen(t, session, fmt.Sprintf("%q", text), func(screen string) bool {
+		return strings.Contains(screen, text)
+	})
+}
+
+// waitForExit waits for the program to exit and returns its status
+func waitForExit(t *testing.T, session *PtySession) PaneStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, err := session.PaneStatus()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.Dead {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("program never exited")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPtySessionScreen(t *testing.T) {
+	session := startPty(t, "sh", "-c", `printf 'plain \033[1mbold\033[0m \033[31mred\033[0m\r\nsecond line\r\n\033[5;3Hplaced'; sleep 10`)
+
+	content := waitForText(t, session, "placed")
+	lines := strings.Split(StripANSI(content), "\n")
+	if len(lines) != defaultPtyRows+1 {
+		t.Fatalf("captured %d lines, want %d rows", len(lines)-1, defaultPtyRows)
+	}
+	want := map[int]string{0: "plain bold red", 1: "second line", 4: "  placed"}
+	for row, text := range want {
+		if got := strings.TrimRight(lines[row], " "); got != text {
+			t.Errorf("row %d = %q, want %q", row, got, text)
+		}
+	}
+
+	// Attributes and colors are rendered as SGR sequences
+	if row := strings.Split(content, "\n")[0]; !strings.HasPrefix(row, "plain \x1b[0;1mbold\x1b[0m \x1b[0;31mred\x1b[0m") {
+		t.Errorf("first row = %q, want bold and red text", row)
+	}
+
+	if err := session.SetDetachedSize(40, 10); err != nil {
+		t.Fatal(err)
+	}
+	content, err := session.CapturePaneContent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows := strings.Count(content, "\n"); rows != 10 {
+		t.Errorf("captured %d rows after resizing, want 10", rows)
+	}
+}
+
+func TestPtySessionInput(t *testing.T) {
+	// cat -v shows the escape sequences it gets, so keys can be told apart
+	session := startPty(t, "sh", "-c", `stty -echo; printf 'ready\r\n'; exec cat -v`)
+	waitForText(t, session, "ready")
+
+	for _, key := range []Key{{Text: "typed"}, {Name: "Up"}, {Name: "C-Left"}, {Name: "Enter"}} {
+		if err := session.SendKey(key); err != nil {
+			t.Fatalf("SendKey(%+v): %v", key, err)
+		}
+	}
+	waitForText(t, session, "typed^[[A^[[1;5D")
+
+	// Without bracketed paste the text arrives as typed
+	if err := session.PasteText("pasted"); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.TapEnter(); err != nil {
+		t.Fatal(err)
+	}
+	waitForText(t, session, "\npasted")
+
+	if err := session.SendKey(Key{Name: "NoSuchKey"}); err == nil {
+		t.Error("SendKey accepted an unknown key name")
+	}
+}
+
+func TestPtySessionBracketedPaste(t *testing.T) {
+	session := startPty(t, "sh", "-c", `stty -echo; printf '\033[?2004hready\r\n'; exec cat -v`)
+	waitForText(t, session, "ready")
+
+	if err := session.PasteText("multi\nline"); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.TapEnter(); err != nil {
+		t.Fatal(err)
+	}
+	waitForText(t, session, "^[[200~multi")
+	waitForText(t, session, "line^[[201~")
+}
+
+func TestPtySessionExit(t *testing.T) {
+	session := startPty(t, "sh", "-c", `printf 'goodbye'; exit 3`)
+	waitForText(t, session, "goodbye")
+
+	if status := waitForExit(t, session); status.ExitStatus != 3 {
+		t.Errorf("exit status = %d, want 3", status.ExitStatus)
+	}
+
+	// The screen is kept for remain-on-exit, but the program takes no input
+	if exists, err := session.SessionExists(); err != nil || !exists {
+		t.Errorf("SessionExists = %v, %v, want true", exists, err)
+	}
+	if err := session.Restore(); err == nil {
+		t.Error("Restore succeeded for an exited program")
+	}
+	if err := session.SendKey(Key{Text: "x"}); err == nil {
+		t.Error("SendKey succeeded for an exited program")
+	}
+	waitForText(t, session, "goodbye")
+
+	if err := session.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := session.SessionExists(); err != nil || exists {
+		t.Errorf("SessionExists after Kill = %v, %v, want false", exists, err)
+	}
+	if _, err := session.PaneStatus(); err == nil {
+		t.Error("PaneStatus succeeded after Kill")
+	}
+}
+
+func TestPtySessionKill(t *testing.T) {
+	session := NewPtySession("test-kill", "sleep")
+	session.SetArgs([]string{"10"})
+	if err := session.Start(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := session.SessionExists(); err != nil || !exists {
+		t.Fatalf("SessionExists = %v, %v, want true", exists, err)
+	}
+
+	if err := session.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := session.SessionExists(); err != nil || exists {
+		t.Errorf("SessionExists after Kill = %v, %v, want false", exists, err)
+	}
+	// Killing again is harmless
+	if err := session.Kill(); err != nil {
+		t.Errorf("second Kill: %v", err)
+	}
+}
+
+func TestForwardAttachInputStopsWhenDone(t *testing.T) {
+	in, typed, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	defer typed.Close()
+
+	done := make(chan struct{})
+	forwarded := make(chan string, 10)
+	result := make(chan bool)
+	go func() {
+		result <- forwardAttachInput(in, done, func(data []byte) { forwarded <- string(data) })
+	}()
+
+	if _, err := typed.WriteString("abc"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case data := <-forwarded:
+		if data != "abc" {
+			t.Errorf("forwarded %q, want %q", data, "abc")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("input was not forwarded")
+	}
+
+	// The program exited; reading stops without waiting for another key
+	close(done)
+	select {
+	case detached := <-result:
+		if detached {
+			t.Error("reported the detach key when done was closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("still reading after done was closed")
+	}
+
+	// The next key is left for agate
+	if _, err := typed.WriteString("q"); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 8)
+	n, err := in.Read(buf)
+	if err != nil || string(buf[:n]) != "q" {
+		t.Errorf("next read = %q, %v, want %q", buf[:n], err, "q")
+	}
+	if len(forwarded) > 0 {
+		t.Errorf("forwarded %q after done was closed", <-forwarded)
+	}
+}
+
+func TestForwardAttachInputDetaches(t *testing.T) {
+	in, typed, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	defer typed.Close()
+
+	var forwarded strings.Builder
+	result := make(chan bool)
+	go func() {
+		result <- forwardAttachInput(in, make(chan struct{}), func(data []byte) { forwarded.Write(data) })
+	}()
+
+	detach := CurrentDetachKey()
+	if _, err := typed.Write(append([]byte("ls"), detach.bytes...)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case detached := <-result:
+		if !detached {
+			t.Error("did not report the detach key")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the detach key did not detach")
+	}
+	if forwarded.String() != "ls" {
+		t.Errorf("forwarded %q, want %q", forwarded.String(), "ls")
+	}
+}
+
+func TestForwardAttachInputStopsOnReadError(t *testing.T) {
+	// Once its terminal hangs up, reading a PTY fails with EIO rather than EOF
+	in, tty, err := pty.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	tty.Close()
+
+	result := make(chan bool)
+	go func() {
+		result <- forwardAttachInput(in, make(chan struct{}), func([]byte) {})
+	}()
+	select {
+	case detached := <-result:
+		if detached {
+			t.Error("reported the detach key after a read error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("still reading after a read error")
+	}
+}
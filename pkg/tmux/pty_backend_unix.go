@@ -0,0 +1,35 @@
+//go:build !windows
+
+package tmux
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+)
+
+// watchResize calls resize whenever the terminal is resized, until stop is closed
+func watchResize(stop <-chan struct{}, resize func()) {
+	winchChan := make(chan os.Signal, 1)
+	signal.Notify(winchChan, syscall.SIGWINCH)
+	defer signal.Stop(winchChan)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-winchChan:
+			resize()
+		}
+	}
+}
+
+// killProcessGroup hangs up on a program started on a PTY and everything it
+// started, the way closing a terminal does
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	// pty.Start makes the program a session leader, so its pid is the group's id
+	_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGHUP)
+}
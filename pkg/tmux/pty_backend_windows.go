@@ -0,0 +1,17 @@
+//go:build windows
+
+package tmux
+
+import "os/exec"
+
+// watchResize is a no-op on Windows
+func watchResize(stop <-chan struct{}, resize func()) {
+	// Windows doesn't have SIGWINCH, so we don't follow window size changes
+}
+
+// killProcessGroup kills a program started on a PTY
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		_ = cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,193 @@
+package tmux
+
+import (
+	"sync"
+	"time"
+)
+
+// Refresh pacing for the previewed pane
+const (
+	MinRefreshInterval = 33 * time.Millisecond // Right after output or a keystroke
+	MaxRefreshInterval = 2 * time.Second       // Once the pane has been quiet for a while
+
+	// refreshBackoff divides the time since the last activity into the interval
+	refreshBackoff = 4
+	// rateWindow is the period the effective refresh rate is measured over
+	rateWindow = 5 * time.Second
+)
+
+// RefreshStats describes how often a RefreshScheduler refreshes
+type RefreshStats struct {
+	Interval time.Duration // Current interval between refreshes, zero while paused
+	Rate     float64       // Refreshes per second over the last few seconds
+	Paused   bool
+}
+
+// RefreshScheduler paces captures of the previewed pane. It refreshes fast
+// right after output or a keystroke, backs off while the pane stays quiet and
+// stops while paused, e.g. when the terminal loses focus. It is safe for
+// concurrent use.
+type RefreshScheduler struct {
+	wake chan struct{} // Interrupts Wait when the interval may have shrunk
+
+	mu           sync.Mutex
+	lastActivity time.Time
+	lastRefresh  time.Time
+	paused       bool
+	waiting      bool        // A Wait is scheduled or in progress
+	refreshes    []time.Time // Refreshes within rateWindow, oldest first
+}
+
+// NewRefreshScheduler creates a scheduler that starts out refreshing fast
+func NewRefreshScheduler() *RefreshScheduler {
+	return &RefreshScheduler{
+		wake:         make(chan struct{}, 1),
+		lastActivity: time.Now(),
+	}
+}
+
+// Activity records output or a keystroke, speeding refreshes up again
+func (s *RefreshScheduler) Activity() {
+	s.mu.Lock()
+	s.lastActivity = time.Now()
+	s.mu.Unlock()
+	s.interrupt()
+}
+
+// Input records a keystroke or mouse event. The user is evidently looking,
+// so a paused scheduler resumes even if the terminal never reported focus.
+func (s *RefreshScheduler) Input() {
+	s.SetPaused(false)
+}
+
+// SetPaused stops refreshes while the preview can't be seen. Resuming counts
+// as activity, so the preview catches up at once.
+func (s *RefreshScheduler) SetPaused(paused bool) {
+	s.mu.Lock()
+	s.paused = paused
+	if !paused {
+		s.lastActivity = time.Now()
+	}
+	s.mu.Unlock()
+	s.interrupt()
+}
+
+// interrupt wakes a pending Wait without blocking
+func (s *RefreshScheduler) interrupt() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// interval returns the time between refreshes while not paused; s.mu must be held
+func (s *RefreshScheduler) interval(now time.Time) time.Duration {
+	interval := now.Sub(s.lastActivity) / refreshBackoff
+	if interval < MinRefreshInterval {
+		return MinRefreshInterval
+	}
+	if interval > MaxRefreshInterval {
+		return MaxRefreshInterval
+	}
+	return interval
+}
+
+// Schedule claims the next Wait. It returns false while another one is
+// pending, so that only one capture loop follows the preview.
+func (s *RefreshScheduler) Schedule() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.waiting {
+		return false
+	}
+	s.waiting = true
+	return true
+}
+
+// Wait blocks until the next refresh is due and reports whether it was
+// brought forward by a value on changes, which announces new output. A nil
+// changes channel makes the scheduler poll. While paused nothing is due until
+// the scheduler resumes. It ends the claim taken by Schedule.
+func (s *RefreshScheduler) Wait(changes <-chan struct{}) bool {
+	defer func() {
+		s.mu.Lock()
+		s.waiting = false
+		s.mu.Unlock()
+	}()
+
+	for {
+		s.mu.Lock()
+		paused := s.paused
+		delay := time.Until(s.lastRefresh.Add(s.interval(time.Now())))
+		s.mu.Unlock()
+		if paused {
+			// Output is caught up with on resuming, so changes aren't taken
+			<-s.wake
+			continue
+		}
+		if delay <= 0 {
+			s.recordRefresh()
+			return false
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-changes:
+			timer.Stop()
+			s.mu.Lock()
+			s.lastActivity = time.Now()
+			paused := s.paused
+			delay = time.Until(s.lastRefresh.Add(MinRefreshInterval))
+			s.mu.Unlock()
+			if paused {
+				continue
+			}
+			// Output lands in bursts; keep to the fastest rate
+			if delay > 0 {
+				time.Sleep(delay)
+			}
+			s.recordRefresh()
+			return true
+		case <-s.wake:
+			timer.Stop()
+		case <-timer.C:
+			s.recordRefresh()
+			return false
+		}
+	}
+}
+
+// recordRefresh notes a refresh for pacing and the effective rate
+func (s *RefreshScheduler) recordRefresh() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.lastRefresh = now
+	s.refreshes = append(s.dropStaleRefreshes(now), now)
+}
+
+// dropStaleRefreshes returns the refreshes within rateWindow of now; s.mu must be held
+func (s *RefreshScheduler) dropStaleRefreshes(now time.Time) []time.Time {
+	cutoff := now.Add(-rateWindow)
+	i := 0
+	for i < len(s.refreshes) && s.refreshes[i].Before(cutoff) {
+		i++
+	}
+	return s.refreshes[i:]
+}
+
+// Stats reports the scheduler's current interval and effective rate
+func (s *RefreshScheduler) Stats() RefreshStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.refreshes = s.dropStaleRefreshes(now)
+	stats := RefreshStats{
+		Rate:   float64(len(s.refreshes)) / rateWindow.Seconds(),
+		Paused: s.paused,
+	}
+	if !s.paused {
+		stats.Interval = s.interval(now)
+	}
+	return stats
+}
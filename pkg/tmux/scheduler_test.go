@@ -0,0 +1,61 @@
+package tmux
+
+import (
+	"testing"
+	"time"
+)
+
+// startWait runs Wait in the background and returns the channel its result arrives on
+func startWait(t *testing.T, s *RefreshScheduler, changes <-chan struct{}) <-chan bool {
+	t.Helper()
+	if !s.Schedule() {
+		t.Fatal("Schedule refused while no Wait was pending")
+	}
+	result := make(chan bool, 1)
+	go func() { result <- s.Wait(changes) }()
+	return result
+}
+
+func TestRefreshSchedulerStopsWhilePaused(t *testing.T) {
+	s := NewRefreshScheduler()
+	s.SetPaused(true)
+	changes := make(chan struct{}, 1)
+	result := startWait(t, s, changes)
+
+	// Neither time nor output brings a refresh while paused
+	changes <- struct{}{}
+	select {
+	case <-result:
+		t.Fatal("refreshed while paused")
+	case <-time.After(MaxRefreshInterval + 500*time.Millisecond):
+	}
+	if stats := s.Stats(); !stats.Paused || stats.Interval != 0 {
+		t.Errorf("Stats = %+v, want paused without an interval", stats)
+	}
+	if s.Schedule() {
+		t.Error("Schedule allowed a second Wait while one is pending")
+	}
+
+	s.SetPaused(false)
+	select {
+	case <-result:
+	case <-time.After(time.Second):
+		t.Fatal("no refresh after resuming")
+	}
+}
+
+func TestRefreshSchedulerResumesOnInput(t *testing.T) {
+	s := NewRefreshScheduler()
+	s.SetPaused(true)
+	result := startWait(t, s, nil)
+
+	s.Input()
+	select {
+	case <-result:
+	case <-time.After(time.Second):
+		t.Fatal("no refresh after input")
+	}
+	if stats := s.Stats(); stats.Paused || stats.Interval != MinRefreshInterval {
+		t.Errorf("Stats = %+v, want refreshing fast", stats)
+	}
+}
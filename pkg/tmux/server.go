@@ -0,0 +1,114 @@
+package tmux
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// AgateSocketName is the socket agate's own tmux server listens on unless configured otherwise
+const AgateSocketName = "agate"
+
+// Server identifies a tmux server by its socket
+type Server struct {
+	SocketName string // Passed as -L, a socket in tmux's socket directory
+	SocketPath string // Passed as -S, takes precedence over SocketName
+	ConfigFile string // Passed as -f, read when the server starts
+}
+
+// DefaultServer is the user's own tmux server, which agate used before it
+// had a server of its own
+var DefaultServer = Server{}
+
+var (
+	currentServerMu sync.RWMutex
+	currentServer   = Server{SocketName: AgateSocketName, ConfigFile: os.DevNull}
+)
+
+// SetServer selects the server new sessions are started on and package-level
+// functions act on
+func SetServer(server Server) {
+	currentServerMu.Lock()
+	defer currentServerMu.Unlock()
+	currentServer = server
+}
+
+// CurrentServer returns the server new sessions are started on
+func CurrentServer() Server {
+	currentServerMu.RLock()
+	defer currentServerMu.RUnlock()
+	return currentServer
+}
+
+// Args returns the tmux options that select the server
+func (s Server) Args() []string {
+	var args []string
+	switch {
+	case s.SocketPath != "":
+		args = append(args, "-S", s.SocketPath)
+	case s.SocketName != "":
+		args = append(args, "-L", s.SocketName)
+	}
+	if s.ConfigFile != "" {
+		args = append(args, "-f", s.ConfigFile)
+	}
+	return args
+}
+
+// Command returns a tmux command run against the server
+func (s Server) Command(args ...string) *exec.Cmd {
+	return exec.Command("tmux", append(s.Args(), args...)...)
+}
+
+// SocketFile returns the path of the server's socket, resolved the way tmux does
+func (s Server) SocketFile() string {
+	if s.SocketPath != "" {
+		return s.SocketPath
+	}
+	name := s.SocketName
+	if name == "" {
+		// Without a socket option, tmux follows the client it runs in
+		if socket := insideSocket(); socket != "" {
+			return socket
+		}
+		name = "default"
+	}
+	dir := os.Getenv("TMUX_TMPDIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, fmt.Sprintf("tmux-%d", os.Getuid()), name)
+}
+
+// Same reports whether both identify the same server
+func (s Server) Same(other Server) bool {
+	return s.SocketFile() == other.SocketFile()
+}
+
+// IsInside reports whether agate runs inside a tmux client of the server
+func (s Server) IsInside() bool {
+	socket := insideSocket()
+	return socket != "" && socket == s.SocketFile()
+}
+
+// insideSocket returns the socket of the tmux server whose client agate runs in, if any
+func insideSocket() string {
+	socket, _, _ := strings.Cut(os.Getenv("TMUX"), ",")
+	return socket
+}
+
+// ServerAt returns the server listening on a socket recorded by SocketFile.
+// An empty path means the default server.
+func ServerAt(socketFile string) Server {
+	if socketFile == "" {
+		return DefaultServer
+	}
+	server := CurrentServer()
+	if server.SocketFile() == socketFile {
+		return server
+	}
+	return Server{SocketPath: socketFile}
+}
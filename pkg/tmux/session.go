@@ -8,8 +8,12 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/creack/pty"
@@ -21,13 +25,19 @@ type TmuxSession struct {
 	name          string
 	sanitizedName string
 	program       string
+	args          []string          // Extra arguments appended to program
+	env           map[string]string // Environment variables set on the session
+	remainOnExit  bool              // Keep the pane around after the program exits
+	serverMu      sync.RWMutex
+	server        Server // tmux server the session runs on, guarded by serverMu
 
 	// PTY management
 	ptyFactory PtyFactory
 	ptmx       *os.File
 
 	// Status monitoring
-	monitor *StatusMonitor
+	monitor  *StatusMonitor
+	detector *Detector
 
 	// Attachment state
 	attachCh chan struct{}
@@ -43,12 +53,15 @@ type TmuxSession struct {
 // NewTmuxSession creates a new tmux session manager
 func NewTmuxSession(name, program string) *TmuxSession {
 	sanitizedName := SanitizeName(name)
+	detector, _ := NewDetector(GenericDetectionRules)
 	return &TmuxSession{
 		name:          name,
 		sanitizedName: sanitizedName,
 		program:       program,
+		server:        CurrentServer(),
 		ptyFactory:    NewPtyFactory(),
-		monitor:       newStatusMonitor(program),
+		monitor:       newStatusMonitor(detector),
+		detector:      detector,
 	}
 }
 
@@ -57,12 +70,75 @@ func (t *TmuxSession) SetPtyFactory(factory PtyFactory) {
 	t.ptyFactory = factory
 }
 
-// SanitizeName creates a valid tmux session name
+// SetServer moves the session object to another tmux server, e.g. the one a
+// persisted session was started on
+func (t *TmuxSession) SetServer(server Server) {
+	t.serverMu.Lock()
+	defer t.serverMu.Unlock()
+	t.server = server
+}
+
+// Server returns the tmux server the session runs on
+func (t *TmuxSession) Server() Server {
+	t.serverMu.RLock()
+	defer t.serverMu.RUnlock()
+	return t.server
+}
+
+// command returns a tmux command run against the session's server
+func (t *TmuxSession) command(args ...string) *exec.Cmd {
+	return t.Server().Command(args...)
+}
+
+// SetArgs sets extra arguments passed to the program when the session starts
+func (t *TmuxSession) SetArgs(args []string) {
+	t.args = append([]string{}, args...)
+}
+
+// SetEnv sets environment variables applied to the session when it starts
+func (t *TmuxSession) SetEnv(env map[string]string) {
+	t.env = make(map[string]string, len(env))
+	for key, value := range env {
+		t.env[key] = value
+	}
+}
+
+// SetDetectionRules replaces the rules used to detect the agent's state.
+// Invalid patterns are reported and skipped; the remaining ones still apply.
+func (t *TmuxSession) SetDetectionRules(rules DetectionRules) error {
+	if rules.IsEmpty() {
+		rules = GenericDetectionRules
+	}
+	detector, err := NewDetector(rules)
+	t.detector = detector
+	t.monitor = newStatusMonitor(detector)
+	return err
+}
+
+// SetRemainOnExit keeps the pane open after the program exits so its exit
+// status can be inspected with PaneStatus
+func (t *TmuxSession) SetRemainOnExit(remain bool) {
+	t.remainOnExit = remain
+}
+
+// GetProgram returns the program run inside the session
+func (t *TmuxSession) GetProgram() string {
+	return t.program
+}
+
+// sanitizedNameRegex matches names SanitizeName has already produced
+var sanitizedNameRegex = regexp.MustCompile(`^agate_[A-Za-z0-9_.-]+_[0-9a-f]{8}$`)
+
+// SanitizeName creates a valid tmux session name. Names it has already
+// produced are returned unchanged so persisted names resolve to the same session.
 func SanitizeName(name string) string {
 	original := strings.TrimSpace(name)
 	if original == "" {
 		original = "default"
 	}
+	if sanitizedNameRegex.MatchString(original) {
+		return original
+	}
 
 	// Replace unsupported characters with underscores to keep tmux happy.
 	sanitized := strings.Map(func(r rune) rune {
@@ -106,13 +182,13 @@ func (t *TmuxSession) Start(workDir string) error {
 
 	if !exists {
 		// Create new tmux session using PTY like Claude Squad
-		cmd := exec.Command("tmux", "new-session", "-d", "-s", t.sanitizedName, "-c", workDir, t.program)
+		cmd := t.command(t.newSessionArgs(workDir)...)
 
 		ptmx, err := t.ptyFactory.Start(cmd)
 		if err != nil {
 			// Cleanup any partially created session if any exists.
 			if exists, _ := t.SessionExists(); exists {
-				cleanupCmd := exec.Command("tmux", "kill-session", "-t", t.sanitizedName)
+				cleanupCmd := t.command("kill-session", "-t", t.sanitizedName)
 				if cleanupErr := cleanupCmd.Run(); cleanupErr != nil {
 					err = fmt.Errorf("%v (cleanup error: %v)", err, cleanupErr)
 				}
@@ -146,18 +222,82 @@ func (t *TmuxSession) Start(workDir string) error {
 		}
 
 		// Set history limit to enable scrollback (default is 2000, we'll use 10000 for more history)
-		historyCmd := exec.Command("tmux", "set-option", "-t", t.sanitizedName, "history-limit", "10000")
+		historyCmd := t.command("set-option", "-t", t.sanitizedName, "history-limit", "10000")
 		_ = historyCmd.Run() // Log warning but don't fail
 
 		// Enable mouse scrolling for the session
-		mouseCmd := exec.Command("tmux", "set-option", "-t", t.sanitizedName, "mouse", "on")
+		mouseCmd := t.command("set-option", "-t", t.sanitizedName, "mouse", "on")
 		_ = mouseCmd.Run() // Log warning but don't fail
+
+		if t.remainOnExit {
+			remainCmd := t.command("set-option", "-t", t.sanitizedName, "remain-on-exit", "on")
+			if err := remainCmd.Run(); err != nil {
+				debug.DebugLog("Failed to set remain-on-exit for %s: %v", t.sanitizedName, err)
+			}
+		}
 	}
 
 	// Attach to the session in detached mode
 	return t.Restore()
 }
 
+// newSessionArgs builds the tmux new-session arguments for the session
+func (t *TmuxSession) newSessionArgs(workDir string) []string {
+	return []string{"new-session", "-d", "-s", t.sanitizedName, "-c", workDir, t.commandLine()}
+}
+
+// commandLine returns the shell command tmux runs for the session
+func (t *TmuxSession) commandLine() string {
+	return commandLine(t.program, t.args, t.env)
+}
+
+// envKeyRegex matches the names a shell accepts for environment variables
+var envKeyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidEnvKey reports whether key can name an environment variable
+func ValidEnvKey(key string) bool {
+	return envKeyRegex.MatchString(key)
+}
+
+// commandLine builds the shell command that runs a program. Environment
+// variables are passed through env(1) so they work regardless of the user's
+// shell and don't depend on new-session -e support. Variables with invalid
+// names are skipped, as the shell would run them as commands.
+func commandLine(program string, args []string, env map[string]string) string {
+	// Sort keys so the command line is stable across runs
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		if ValidEnvKey(key) {
+			keys = append(keys, key)
+		}
+	}
+	if len(args) == 0 && len(keys) == 0 {
+		return program
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys)+len(args)+2)
+	if len(keys) > 0 {
+		parts = append(parts, "env")
+	}
+	for _, key := range keys {
+		parts = append(parts, key+"="+shellQuote(env[key]))
+	}
+	parts = append(parts, program)
+	for _, arg := range args {
+		parts = append(parts, shellQuote(arg))
+	}
+	return strings.Join(parts, " ")
+}
+
+// shellQuote quotes a single argument for /bin/sh
+func shellQuote(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
 // Restore sets up monitoring for an existing tmux session without attaching
 func (t *TmuxSession) Restore() error {
 	// Close existing PTY if any
@@ -169,7 +309,7 @@ func (t *TmuxSession) Restore() error {
 	}
 
 	// Create a PTY connected to tmux attach-session (like Claude Squad)
-	cmd := exec.Command("tmux", "attach-session", "-t", t.sanitizedName)
+	cmd := t.command("attach-session", "-t", t.sanitizedName)
 	ptmx, err := t.ptyFactory.Start(cmd)
 	if err != nil {
 		return fmt.Errorf("error opening PTY for session %s: %w", t.sanitizedName, err)
@@ -178,7 +318,7 @@ func (t *TmuxSession) Restore() error {
 
 	// Initialize status monitor if needed
 	if t.monitor == nil {
-		t.monitor = newStatusMonitor(t.program)
+		t.monitor = newStatusMonitor(t.detector)
 	}
 
 	return nil
@@ -186,7 +326,7 @@ func (t *TmuxSession) Restore() error {
 
 // SessionExists checks if a tmux session exists
 func (t *TmuxSession) SessionExists() (bool, error) {
-	cmd := exec.Command("tmux", "has-session", "-t", t.sanitizedName)
+	cmd := t.command("has-session", "-t", t.sanitizedName)
 	err := cmd.Run()
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
@@ -200,13 +340,38 @@ func (t *TmuxSession) SessionExists() (bool, error) {
 	return true, nil
 }
 
+// ListSessionNames returns the names of all sessions on a tmux server
+func ListSessionNames(server Server) ([]string, error) {
+	output, err := server.Command("list-sessions", "-F", "#{session_name}").Output()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			// No server running means no sessions
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var names []string
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if line != "" {
+			names = append(names, line)
+		}
+	}
+	return names, nil
+}
+
+// KillSession kills the tmux session on a server with the given name as is, without sanitizing it
+func KillSession(server Server, name string) error {
+	return server.Command("kill-session", "-t", name).Run()
+}
+
 // AttachCommand returns an exec.Cmd to attach to the tmux session
 // This is used with tea.ExecProcess for proper terminal handoff
 func (t *TmuxSession) AttachCommand() *exec.Cmd {
 	if t.sanitizedName == "" {
 		return nil
 	}
-	return exec.Command("tmux", "attach-session", "-t", t.sanitizedName)
+	return t.command("attach-session", "-t", t.sanitizedName)
 }
 
 // Attach attaches to the tmux session for interactive use
@@ -240,53 +405,21 @@ func (t *TmuxSession) Attach() (chan struct{}, error) {
 			default:
 				// If context is not done, it was likely an abnormal termination (Ctrl-D)
 				// Print warning message
-				fmt.Fprintf(os.Stderr, "\n\033[31mError: Session terminated without detaching. Use Ctrl-Q to properly detach from tmux sessions.\033[0m\n")
+				fmt.Fprintf(os.Stderr, "\n\033[31mError: Session terminated without detaching. Use %s to properly detach from tmux sessions.\033[0m\n", CurrentDetachKey().Help())
 			}
 		}
 	}()
 
+	attachCh := t.attachCh
 	go func() {
-		// Close the channel after 50ms
-		timeoutCh := make(chan struct{})
-		go func() {
-			time.Sleep(50 * time.Millisecond)
-			close(timeoutCh)
-		}()
-
-		// Read input from stdin and check for Ctrl+q
-		buf := make([]byte, 32)
-		for {
-			nr, err := os.Stdin.Read(buf)
-			if err != nil {
-				if err == io.EOF {
-					break
-				}
-				continue
-			}
-
-			// Nuke the first bytes of stdin, up to 64, to prevent tmux from reading it.
-			// When we attach, there tends to be terminal control sequences like ?[?62c0;95;0c or
-			// ]10;rgb:f8f8f8. The control sequences depend on the terminal (warp vs iterm). We should use regex ideally
-			// but this works well for now. Log this for debugging.
-			//
-			// There seems to always be control characters, but I think it's possible for there not to be. The heuristic
-			// here can be: if there's characters within 50ms, then assume they are control characters and nuke them.
-			select {
-			case <-timeoutCh:
-			default:
-				// For now, skip logging since we don't have a logger setup
-				continue
-			}
-
-			// Check for Ctrl+q (ASCII 17)
-			if nr == 1 && buf[0] == 17 {
-				// Detach from the session
-				t.Detach()
-				return
-			}
-
+		// Read input from stdin and check for the detach key
+		detached := forwardAttachInput(os.Stdin, attachCh, func(data []byte) {
 			// Forward other input to tmux
-			_, _ = t.ptmx.Write(buf[:nr])
+			_, _ = t.ptmx.Write(data)
+		})
+		if detached {
+			// Detach from the session
+			t.Detach()
 		}
 	}()
 
@@ -354,7 +487,7 @@ func (t *TmuxSession) updateWindowSize(cols, rows int) error {
 		})
 	}
 	// In detached mode, resize the tmux session directly
-	cmd := exec.Command("tmux", "resize-window", "-t", t.sanitizedName, "-x", fmt.Sprintf("%d", cols), "-y", fmt.Sprintf("%d", rows))
+	cmd := t.command("resize-window", "-t", t.sanitizedName, "-x", fmt.Sprintf("%d", cols), "-y", fmt.Sprintf("%d", rows))
 	return cmd.Run()
 }
 
@@ -368,7 +501,7 @@ func (t *TmuxSession) CapturePaneContent() (string, error) {
 	// -e preserves escape sequences (ANSI colors)
 	// -J joins wrapped lines
 	// -p prints to stdout
-	cmd := exec.Command("tmux", "capture-pane", "-p", "-e", "-J", "-t", t.sanitizedName)
+	cmd := t.command("capture-pane", "-p", "-e", "-J", "-t", t.sanitizedName)
 	output, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("error capturing pane content: %w", err)
@@ -378,7 +511,7 @@ func (t *TmuxSession) CapturePaneContent() (string, error) {
 
 // CapturePaneContentWithOptions captures specific lines from the tmux pane
 func (t *TmuxSession) CapturePaneContentWithOptions(startLine, endLine int) (string, error) {
-	cmd := exec.Command("tmux", "capture-pane", "-p", "-e", "-J", "-t", t.sanitizedName,
+	cmd := t.command("capture-pane", "-p", "-e", "-J", "-t", t.sanitizedName,
 		"-S", fmt.Sprintf("%d", startLine), "-E", fmt.Sprintf("%d", endLine))
 	output, err := cmd.Output()
 	if err != nil {
@@ -387,6 +520,15 @@ func (t *TmuxSession) CapturePaneContentWithOptions(startLine, endLine int) (str
 	return string(output), nil
 }
 
+// HistorySize returns the number of scrollback lines above the visible pane
+func (t *TmuxSession) HistorySize() (int, error) {
+	output, err := t.command("display-message", "-p", "-t", t.sanitizedName, "#{history_size}").Output()
+	if err != nil {
+		return 0, fmt.Errorf("error reading history size: %w", err)
+	}
+	return strconv.Atoi(strings.TrimSpace(string(output)))
+}
+
 // HasUpdated checks if the tmux pane content has changed
 func (t *TmuxSession) HasUpdated() (updated bool, hasPrompt bool) {
 	content, err := t.CapturePaneContent()
@@ -394,16 +536,102 @@ func (t *TmuxSession) HasUpdated() (updated bool, hasPrompt bool) {
 		return false, false
 	}
 
+	return t.HasContentUpdated(content)
+}
+
+// HasContentUpdated checks if already captured pane content differs from the last check
+func (t *TmuxSession) HasContentUpdated(content string) (updated bool, hasPrompt bool) {
 	return t.monitor.HasUpdated(content)
 }
 
+// Classify detects the agent's state from captured pane content
+func (t *TmuxSession) Classify(content string) PaneState {
+	return t.detector.Detect(content)
+}
+
+// PaneStatus describes whether the pane's program is still running
+type PaneStatus struct {
+	Dead       bool // The program exited and the pane was kept by remain-on-exit
+	ExitStatus int  // Exit status of the program when Dead, -1 if it was killed by a signal
+}
+
+// PaneStatus reports whether the program in the session's pane has exited
+func (t *TmuxSession) PaneStatus() (PaneStatus, error) {
+	cmd := t.command("display-message", "-p", "-t", t.sanitizedName, "#{pane_dead} #{pane_dead_status}")
+	output, err := cmd.Output()
+	if err != nil {
+		return PaneStatus{}, fmt.Errorf("error reading pane status: %w", err)
+	}
+
+	fields := strings.Fields(string(output))
+	status := PaneStatus{}
+	if len(fields) > 0 {
+		status.Dead = fields[0] == "1"
+	}
+	if status.Dead {
+		status.ExitStatus = -1
+		if len(fields) > 1 {
+			if code, err := strconv.Atoi(fields[1]); err == nil {
+				status.ExitStatus = code
+			}
+		}
+	}
+	return status, nil
+}
+
 // SendKeys sends keystrokes to the tmux session
 func (t *TmuxSession) SendKeys(keys string) error {
 	// Use tmux send-keys command for detached sessions
-	cmd := exec.Command("tmux", "send-keys", "-t", t.sanitizedName, keys)
+	cmd := t.command("send-keys", "-t", t.sanitizedName, keys)
 	return cmd.Run()
 }
 
+// SendKey sends one keystroke to the pane. tmux encodes named keys the way
+// the pane's program expects them.
+func (t *TmuxSession) SendKey(key Key) error {
+	args := []string{"send-keys", "-t", t.sanitizedName}
+	if key.Text != "" {
+		args = append(args, "-l", "--", literalKeysArg(key.Text))
+	} else {
+		args = append(args, key.Name)
+	}
+	if output, err := t.command(args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("error sending key to session %s: %w: %s", t.sanitizedName, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// literalKeysArg escapes text for send-keys -l. tmux takes an argument
+// ending in ";" as a command separator, and "\;" as a literal ";".
+func literalKeysArg(text string) string {
+	if rest, ok := strings.CutSuffix(text, ";"); ok {
+		return rest + `\;`
+	}
+	return text
+}
+
+// pasteBuffers numbers the tmux buffers of pastes made by this process
+var pasteBuffers atomic.Uint64
+
+// PasteText pastes text into the pane through a tmux buffer. Bracketed paste
+// is used when the program asks for it, so newlines in the text don't submit it early.
+// Each paste loads a buffer of its own, so pastes made at the same time, by
+// this or another agate, can't overwrite each other's text.
+func (t *TmuxSession) PasteText(text string) error {
+	buffer := fmt.Sprintf("agate_paste_%s_%d_%d", t.sanitizedName, os.Getpid(), pasteBuffers.Add(1))
+	load := t.command("load-buffer", "-b", buffer, "-")
+	load.Stdin = strings.NewReader(text)
+	if err := load.Run(); err != nil {
+		return fmt.Errorf("error loading paste buffer: %w", err)
+	}
+
+	cmd := t.command("paste-buffer", "-d", "-p", "-b", buffer, "-t", t.sanitizedName)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error pasting into session %s: %w", t.sanitizedName, err)
+	}
+	return nil
+}
+
 // TapEnter sends an Enter key to the tmux session
 func (t *TmuxSession) TapEnter() error {
 	return t.SendKeys("\r")
@@ -412,19 +640,19 @@ func (t *TmuxSession) TapEnter() error {
 // SendScrollUp sends scroll up command to tmux session
 func (t *TmuxSession) SendScrollUp() error {
 	// Use tmux copy-mode with scroll up
-	cmd := exec.Command("tmux", "copy-mode", "-t", t.sanitizedName)
+	cmd := t.command("copy-mode", "-t", t.sanitizedName)
 	if err := cmd.Run(); err != nil {
 		return err
 	}
 	// Send multiple up arrows for smoother scrolling (3 lines up)
-	cmd = exec.Command("tmux", "send-keys", "-t", t.sanitizedName, "Up", "Up", "Up")
+	cmd = t.command("send-keys", "-t", t.sanitizedName, "Up", "Up", "Up")
 	return cmd.Run()
 }
 
 // SendScrollDown sends scroll down command to tmux session
 func (t *TmuxSession) SendScrollDown() error {
 	// Try to scroll down - if at bottom, this will exit copy mode automatically
-	cmd := exec.Command("tmux", "send-keys", "-t", t.sanitizedName, "Down", "Down", "Down")
+	cmd := t.command("send-keys", "-t", t.sanitizedName, "Down", "Down", "Down")
 	return cmd.Run()
 }
 
@@ -444,7 +672,7 @@ func (t *TmuxSession) Kill() error {
 	}
 
 	// Kill the tmux session
-	cmd := exec.Command("tmux", "kill-session", "-t", t.sanitizedName)
+	cmd := t.command("kill-session", "-t", t.sanitizedName)
 	return cmd.Run()
 }
 
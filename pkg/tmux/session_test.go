@@ -0,0 +1,121 @@
+package tmux
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startTmux starts a program in a session on a tmux server of its own,
+// which is killed when the test ends
+func startTmux(t *testing.T, program string, args ...string) *TmuxSession {
+	t.Helper()
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux is not installed")
+	}
+	dir := t.TempDir()
+	server := Server{SocketPath: filepath.Join(dir, "tmux.sock"), ConfigFile: "/dev/null"}
+	t.Cleanup(func() { _ = server.Command("kill-server").Run() })
+
+	session := NewTmuxSession("test", program)
+	session.SetServer(server)
+	session.SetArgs(args)
+	if err := session.Start(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = session.Kill() })
+	return session
+}
+
+// waitForPane waits until the session's pane shows text
+func waitForPane(t *testing.T, session *TmuxSession, text string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		content, err := session.CapturePaneContent()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(StripANSI(content), text) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("pane never showed %q:\n%s", text, StripANSI(content))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTmuxSessionSendKeyLiteralText(t *testing.T) {
+	session := startTmux(t, "sh", "-c", `stty -echo; printf 'ready\n'; exec cat`)
+	waitForPane(t, session, "ready")
+
+	// Each line is typed key by key, as insert mode does
+	lines := []string{"a;b;", ";", "-x", `\;`, `x\`, "--"}
+	for _, line := range lines {
+		for _, r := range line {
+			if err := session.SendKey(Key{Text: string(r)}); err != nil {
+				t.Fatalf("SendKey(%q): %v", string(r), err)
+			}
+		}
+		if err := session.SendKey(Key{Name: "Enter"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Typed at once, e.g. when keys arrive together
+	for _, text := range []string{"-x;", `\;`} {
+		if err := session.SendKey(Key{Text: text}); err != nil {
+			t.Fatalf("SendKey(%q): %v", text, err)
+		}
+		if err := session.SendKey(Key{Name: "Enter"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitForPane(t, session, "ready\n"+strings.Join(append(lines, "-x;", `\;`), "\n"))
+}
+
+func TestTmuxSessionConcurrentPastes(t *testing.T) {
+	session := startTmux(t, "sh", "-c", `stty -echo; printf 'ready\n'; exec cat`)
+	waitForPane(t, session, "ready")
+
+	const pastes = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, pastes)
+	for i := range pastes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- session.PasteText(fmt.Sprintf("paste-%d\n", i))
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range pastes {
+		waitForPane(t, session, fmt.Sprintf("paste-%d\n", i))
+	}
+}
+
+func TestLiteralKeysArg(t *testing.T) {
+	tests := map[string]string{
+		"plain": "plain",
+		";":     `\;`,
+		"a;":    `a\;`,
+		`\;`:    `\\;`,
+		"a;b":   "a;b",
+		"-x":    "-x",
+	}
+	for text, want := range tests {
+		if got := literalKeysArg(text); got != want {
+			t.Errorf("literalKeysArg(%q) = %q, want %q", text, got, want)
+		}
+	}
+}